@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"ghc/internal/utils"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+var ErrInvalidColor = errors.New("invalid --color, expected auto, always or never")
+
+// setColorMode applies the "color" flag before any command runs:
+// "always" and "never" force colored output on or off, and "auto", the
+// default, only colors output written to a terminal, unless TERM is "dumb"
+// or the NO_COLOR environment variable is set to anything but "".
+//
+// Returns an error wrapping ErrInvalidColor for any other value.
+func setColorMode(ctx context.Context, c *cli.Command) (context.Context, error) {
+	switch mode := c.String("color"); mode {
+	case "auto", "":
+		color.NoColor = os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !utils.IsTerminal(c.Root().Writer)
+	case "always":
+		color.NoColor = false
+	case "never":
+		color.NoColor = true
+	default:
+		return ctx, fmt.Errorf("%w: %q", ErrInvalidColor, mode)
+	}
+	return ctx, nil
+}
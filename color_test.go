@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"ghc/internal/domain"
+	"ghc/internal/utils"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func TestSetColorMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		noColor    string // the NO_COLOR environment variable
+		terminal   bool
+		expectErr  error
+		expectANSI bool
+	}{
+		{name: "never", args: []string{"--color", "never"}, terminal: true},
+		{name: "always", args: []string{"--color", "always"}, expectANSI: true},
+		{name: "auto on a terminal", args: []string{"--color", "auto"}, terminal: true, expectANSI: true},
+		{name: "auto when piped", args: []string{"--color", "auto"}},
+		{name: "auto by default", terminal: true, expectANSI: true},
+		{name: "NO_COLOR", noColor: "1", terminal: true},
+		{name: "NO_COLOR with auto", args: []string{"--color", "auto"}, noColor: "true", terminal: true},
+		{name: "always overrides NO_COLOR", args: []string{"--color", "always"}, noColor: "1", expectANSI: true},
+		{name: "empty NO_COLOR", noColor: "", terminal: true, expectANSI: true},
+		{name: "invalid", args: []string{"--color", "sometimes"}, expectErr: ErrInvalidColor},
+	}
+
+	conf := &domain.Config{Organizations: []*domain.Organization{{Name: "org1", SSHKeyPath: "/path/to/key", IsDefault: true}}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TERM", "xterm")
+			t.Setenv("NO_COLOR", tt.noColor)
+			origNoColor, origTerminal := color.NoColor, utils.IsTerminal
+			utils.IsTerminal = func(any) bool { return tt.terminal }
+			t.Cleanup(func() { color.NoColor, utils.IsTerminal = origNoColor, origTerminal })
+
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "ghc",
+				Writer: &out,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "color", Value: "auto"},
+				},
+				Before: setColorMode,
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return formatTable(c.Root().Writer, conf, listOptions{})
+				},
+			}
+			err := cmd.Run(t.Context(), append([]string{"ghc"}, tt.args...))
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				return
+			}
+			if hasANSI := strings.Contains(out.String(), "\x1b["); hasANSI != tt.expectANSI {
+				t.Errorf("expected ANSI codes %v, got %q", tt.expectANSI, out.String())
+			}
+		})
+	}
+}
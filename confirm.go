@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"ghc/internal/configfile"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+var ErrKeyChangeDeclined = errors.New("replacing the SSH key declined")
+
+// confirmer asks the user whether to go ahead with a change. A destructive
+// change, one that removes something, is declined when nobody can answer.
+type confirmer interface {
+	Confirm(question string, destructive bool) bool
+}
+
+// promptConfirmer asks on w and reads the answer from r, see utils.Confirm.
+type promptConfirmer struct {
+	r io.Reader
+	w io.Writer
+}
+
+func (p promptConfirmer) Confirm(question string, destructive bool) bool {
+	return utils.Confirm(p.r, p.w, question, destructive)
+}
+
+// newConfirmer returns the confirmer for a command, reading answers from its
+// reader. This can be overridden in tests.
+var newConfirmer = func(c *cli.Command) confirmer {
+	return promptConfirmer{r: c.Root().Reader, w: c.Root().ErrWriter}
+}
+
+// confirmKeyChange asks before "org set" changes the SSH key of an organization
+// that already exists, showing the old and new key, unless the "yes" flag is set.
+// Nothing is asked for a new organization or an unchanged key. If replaced is
+// set, the file at sshKeyPath is about to get new content, so the key changes
+// even if the organization already uses that path.
+//
+// Returns an error wrapping ErrKeyChangeDeclined if the user says no.
+func confirmKeyChange(c *cli.Command, configPath, orgName, sshKeyPath string, replaced bool) error {
+	if c.Bool("yes") {
+		return nil
+	}
+	conf, err := configfile.LoadConfigFrom(configPath)
+	if errors.Is(err, configfile.ErrConfigNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	org, err := conf.GetOrganization(orgName)
+	if err != nil {
+		// a new organization
+		return nil
+	}
+	newKey := sshKeyPath
+	if org.SSHKeyPath != "" && utils.ExpandPath(org.SSHKeyPath) == sshKeyPath {
+		if !replaced {
+			return nil
+		}
+		newKey += " (new content)"
+	}
+
+	old := org.SSHKeyPath
+	if old == "" {
+		old = "host alias " + org.HostAlias
+	}
+	question := fmt.Sprintf("Organization '%s' already exists.\n  old key: %s\n  new key: %s\nReplace its SSH key?", orgName, old, newKey)
+	if !newConfirmer(c).Confirm(question, false) {
+		return fmt.Errorf("%w: %s", ErrKeyChangeDeclined, orgName)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+// fakeConfirmer answers every question with answer, recording the questions
+// and whether they were destructive.
+type fakeConfirmer struct {
+	answer      bool
+	questions   []string
+	destructive []bool
+}
+
+func (f *fakeConfirmer) Confirm(question string, destructive bool) bool {
+	f.questions = append(f.questions, question)
+	f.destructive = append(f.destructive, destructive)
+	return f.answer
+}
+
+func TestSetOrganizationConfirmOverwrite(t *testing.T) {
+	oldKey, _ := utils.GenerateTestSSHKey(t)
+	newKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		args      []string
+		answer    bool
+		expectAsk bool
+		expectErr error
+		expectKey string
+	}{
+		{name: "confirmed", args: []string{"set", "org1", newKey}, answer: true, expectAsk: true, expectKey: newKey},
+		{name: "declined", args: []string{"set", "org1", newKey}, expectAsk: true, expectErr: ErrKeyChangeDeclined, expectKey: oldKey},
+		{name: "yes", args: []string{"set", "--yes", "org1", newKey}, expectKey: newKey},
+		{name: "same key", args: []string{"set", "org1", oldKey}, expectKey: oldKey},
+		{name: "new organization", args: []string{"set", "org2", newKey}, expectKey: oldKey},
+		{name: "plan", args: []string{"set", "--plan", "org1", newKey}, expectKey: oldKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			conf := &domain.Config{
+				Organizations: []*domain.Organization{{Name: "org1", SSHKeyPath: oldKey, IsDefault: true}},
+			}
+			confBytes, err := conf.JSON()
+			if err != nil {
+				t.Fatalf("failed to marshal test config: %v", err)
+			}
+			utils.WriteConfigFileForTest(t, configPath, confBytes)
+			configfile.SetDefaultConfigPath(configPath)
+
+			fake := &fakeConfirmer{answer: tt.answer}
+			orig := newConfirmer
+			newConfirmer = func(*cli.Command) confirmer { return fake }
+			t.Cleanup(func() { newConfirmer = orig })
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "yes"},
+					&cli.BoolFlag{Name: "plan"},
+				},
+			}
+			err = cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			if !tt.expectAsk {
+				if len(fake.questions) != 0 {
+					t.Fatalf("expected no question, got %q", fake.questions)
+				}
+			} else {
+				if len(fake.questions) != 1 {
+					t.Fatalf("expected one question, got %q", fake.questions)
+				}
+				if q := fake.questions[0]; !strings.Contains(q, "old key: "+oldKey) || !strings.Contains(q, "new key: "+newKey) {
+					t.Errorf("expected the question to show both keys, got %q", q)
+				}
+			}
+
+			conf, err = configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			org, err := conf.GetOrganization("org1")
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if org.SSHKeyPath != tt.expectKey {
+				t.Errorf("expected key %s, got %s", tt.expectKey, org.SSHKeyPath)
+			}
+		})
+	}
+}
+
+func TestPromptConfirmer(t *testing.T) {
+	tests := []struct {
+		name        string
+		terminal    bool
+		destructive bool
+		input       string
+		expected    bool
+		asked       bool
+	}{
+		{name: "yes", terminal: true, input: "y\n", expected: true, asked: true},
+		{name: "full yes", terminal: true, input: "YES\n", expected: true, asked: true},
+		{name: "no", terminal: true, input: "n\n", expected: false, asked: true},
+		{name: "no answer", terminal: true, input: "", expected: false, asked: true},
+		{name: "destructive yes", terminal: true, destructive: true, input: "y\n", expected: true, asked: true},
+		{name: "not a terminal", input: "n\n", expected: true},
+		{name: "destructive not a terminal", destructive: true, input: "y\n", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := utils.IsTerminal
+			utils.IsTerminal = func(any) bool { return tt.terminal }
+			t.Cleanup(func() { utils.IsTerminal = orig })
+
+			var out bytes.Buffer
+			got := promptConfirmer{r: strings.NewReader(tt.input), w: &out}.Confirm("Go ahead?", tt.destructive)
+			if got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+			if asked := strings.Contains(out.String(), "Go ahead? [y/N]"); asked != tt.asked {
+				t.Errorf("expected asked %v, got output %q", tt.asked, out.String())
+			}
+		})
+	}
+}
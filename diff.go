@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+
+	"github.com/urfave/cli/v3"
+)
+
+// diffOrganizations prints how the organizations in a file differ from the
+// live configuration, without changing anything, such as to review a
+// configuration kept in version control before applying it.
+//
+// The file is either a configuration file, or a JSON array of organizations
+// as printed by "org ls --format json", which leaves the live config-wide
+// settings as they are. A missing live configuration is treated as empty.
+//
+// Returns an error if the arguments are invalid, or either file can't be read.
+func diffOrganizations(ctx context.Context, c *cli.Command) error {
+	const nargs = 1
+	if c.NArg() != nargs {
+		return fmt.Errorf("%w: expected %d, got %d", ErrNumArguments, nargs, c.NArg())
+	}
+
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+	live, err := configfile.LoadConfigChecked(configPath, c.Bool("strict-config-permissions"), c.Root().ErrWriter)
+	if errors.Is(err, configfile.ErrConfigNotFound) {
+		live = &domain.Config{Organizations: []*domain.Organization{}}
+	} else if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	want, err := parseOrganizationsFile(data, live)
+	if err != nil {
+		return err
+	}
+
+	changes := domain.Plan(live, want)
+	if len(changes) == 0 {
+		fmt.Fprintln(c.Root().Writer, "No differences")
+		return nil
+	}
+	for _, change := range changes {
+		fmt.Fprintln(c.Root().Writer, diffLine(change))
+	}
+	return nil
+}
+
+// parseOrganizationsFile parses a configuration file, or a JSON array of
+// organizations, which takes its config-wide settings from live.
+func parseOrganizationsFile(data []byte, live *domain.Config) (*domain.Config, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return configfile.ParseConfig(bytes.NewReader(data))
+	}
+	var orgs []*domain.Organization
+	if err := json.Unmarshal(data, &orgs); err != nil {
+		return nil, err
+	}
+	want := live.Clone()
+	want.Organizations = orgs
+	return want, nil
+}
+
+// diffLine formats a change like a diff: "+" for an added organization, "-"
+// for a removed one, and "~" for a changed setting or default.
+func diffLine(change domain.Change) string {
+	switch change.Action {
+	case domain.ChangeAdd:
+		return "+ " + change.Org
+	case domain.ChangeRemove:
+		return "- " + change.Org
+	case domain.ChangeDefault:
+		return fmt.Sprintf("~ default: %q -> %q", change.From, change.To)
+	}
+	if change.Org == "" {
+		return fmt.Sprintf("~ %s: %q -> %q", change.Field, change.From, change.To)
+	}
+	return fmt.Sprintf("~ %s %s: %q -> %q", change.Org, change.Field, change.From, change.To)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestDiffOrganizations(t *testing.T) {
+	live := &domain.Config{
+		Proxy: "socks5://127.0.0.1:1080",
+		Organizations: []*domain.Organization{
+			{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true},
+			{Name: "org2", SSHKeyPath: "/path/to/key2"},
+			{Name: "org3", SSHKeyPath: "/path/to/key3"},
+		},
+	}
+	const changed = "~ org2 key: \"/path/to/key2\" -> \"/path/to/new\"\n" +
+		"- org3\n" +
+		"+ org4\n" +
+		"~ default: \"org1\" -> \"org2\"\n"
+
+	tests := []struct {
+		name      string
+		file      string
+		noConfig  bool // there is no live configuration yet
+		expectErr error
+		expected  string
+	}{
+		{
+			name: "config file",
+			file: `{"proxy": "socks5://127.0.0.1:1080", "organizations": [
+				{"name": "org1", "ssh_key_path": "/path/to/key1"},
+				{"name": "org2", "ssh_key_path": "/path/to/new", "is_default": true},
+				{"name": "org4", "ssh_key_path": "/path/to/key4"}
+			]}`,
+			expected: changed,
+		},
+		{
+			name: "organizations array",
+			file: `[
+				{"name": "org1", "ssh_key_path": "/path/to/key1"},
+				{"name": "org2", "ssh_key_path": "/path/to/new", "is_default": true},
+				{"name": "org4", "ssh_key_path": "/path/to/key4"}
+			]`,
+			expected: changed,
+		},
+		{
+			name:     "config-wide setting",
+			file:     `{"organizations": [{"name": "org1", "ssh_key_path": "/path/to/key1", "is_default": true}, {"name": "org2", "ssh_key_path": "/path/to/key2"}, {"name": "org3", "ssh_key_path": "/path/to/key3"}]}`,
+			expected: "~ proxy: \"socks5://127.0.0.1:1080\" -> \"\"\n",
+		},
+		{
+			name:     "no differences",
+			file:     `[{"name": "org3", "ssh_key_path": "/path/to/key3"}, {"name": "org1", "ssh_key_path": "/path/to/key1", "is_default": true}, {"name": "org2", "ssh_key_path": "/path/to/key2"}]`,
+			expected: "No differences\n",
+		},
+		{
+			name:     "no live config",
+			file:     `[{"name": "org1", "ssh_key_path": "/path/to/key1", "is_default": true}]`,
+			noConfig: true,
+			expected: "+ org1\n~ default: \"\" -> \"org1\"\n",
+		},
+		{
+			name:      "missing file",
+			expectErr: os.ErrNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.json")
+			if !tt.noConfig {
+				if err := configfile.WriteConfigTo(live, configPath); err != nil {
+					t.Fatalf("failed to write config: %v", err)
+				}
+			}
+			configfile.SetDefaultConfigPath(configPath)
+			before, _ := os.ReadFile(configPath)
+
+			filePath := filepath.Join(dir, "orgs.json")
+			if tt.file != "" {
+				if err := os.WriteFile(filePath, []byte(tt.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:      "diff",
+				Action:    diffOrganizations,
+				Writer:    &out,
+				ErrWriter: io.Discard,
+			}
+			err := cmd.Run(t.Context(), []string{"diff", filePath})
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, out.String())
+			}
+
+			// nothing is applied
+			after, _ := os.ReadFile(configPath)
+			if !bytes.Equal(before, after) {
+				t.Errorf("expected the config to be unchanged, got %s", after)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+var ErrConfigProblems = errors.New("the configuration has problems")
+
+// runDoctor checks the configuration and prints every problem it finds, as
+// reported by domain.Lint. Warnings are printed, but only errors fail.
+//
+// With the "fix" flag, it first remediates what it can, printing each fix:
+// keys without 0600 permissions are restricted, organizations whose key is
+// missing are removed after asking, unless the "yes" flag is set, and an
+// organization is made the default if none is, or the only one if several
+// are. The problems left afterwards are printed as usual.
+//
+// Returns an error wrapping ErrConfigProblems if errors remain, or an error
+// if the configuration can't be read or fixed.
+func runDoctor(ctx context.Context, c *cli.Command) error {
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+	if c.Bool("fix") {
+		if err := fixConfig(c, configPath); err != nil {
+			return err
+		}
+	}
+
+	conf, err := configfile.LoadConfigChecked(configPath, c.Bool("strict-config-permissions"), c.Root().ErrWriter)
+	if err != nil {
+		return err
+	}
+	issues := conf.Lint()
+	if len(issues) == 0 {
+		fmt.Fprintln(c.Root().Writer, "No problems found")
+		return nil
+	}
+	errs := 0
+	for _, issue := range issues {
+		fmt.Fprintln(c.Root().Writer, issue)
+		if issue.Severity == domain.SeverityError {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%w: %d error(s)", ErrConfigProblems, errs)
+	}
+	return nil
+}
+
+// fixConfig remediates the problems "doctor --fix" can fix, printing each fix.
+// Removals are confirmed before the config lock is taken, so nobody is asked
+// a question while other ghc processes wait.
+func fixConfig(c *cli.Command, configPath string) error {
+	conf, err := configfile.LoadConfigFrom(configPath)
+	if err != nil {
+		return err
+	}
+	w := c.Root().Writer
+
+	for _, org := range conf.Organizations {
+		for _, keyPath := range keyPaths(org) {
+			fixed, err := fixKeyMode(keyPath)
+			if err != nil {
+				return err
+			}
+			if fixed {
+				fmt.Fprintf(w, "Fixed: %s: set the permissions of %s to 0600\n", org.Name, keyPath)
+			}
+		}
+	}
+
+	// offer to remove organizations whose key is gone; one with a host alias
+	// takes its key from the user's SSH config instead
+	missing := conf.Filter(func(org *domain.Organization) bool {
+		return org.HostAlias == "" && org.SSHKeyPath != "" && !org.KeyExists()
+	})
+	var remove []string
+	for _, org := range missing {
+		question := fmt.Sprintf("The SSH key %s of organization '%s' is missing. Remove the organization?", org.SSHKeyPath, org.Name)
+		if c.Bool("yes") || newConfirmer(c).Confirm(question, true) {
+			remove = append(remove, org.Name)
+		}
+	}
+
+	return configfile.UpdateConfigAt(configPath, func(conf *domain.Config) error {
+		for _, name := range remove {
+			org, err := conf.GetOrganization(name)
+			if err != nil {
+				// removed in the meantime
+				continue
+			}
+			// a default that goes away is replaced below
+			org.IsDefault = false
+			if err := conf.RemoveOrganization(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Fixed: removed %s, whose SSH key is missing\n", name)
+		}
+		if name := conf.RepairDefault(); name != "" {
+			fmt.Fprintf(w, "Fixed: made %s the default\n", name)
+		}
+		return nil
+	})
+}
+
+// keyPaths returns the paths of every key of an organization: its key path,
+// if it has one, and its labeled keys, sorted by label.
+func keyPaths(org *domain.Organization) []string {
+	var paths []string
+	if org.SSHKeyPath != "" {
+		paths = append(paths, org.SSHKeyPath)
+	}
+	for _, label := range slices.Sorted(maps.Keys(org.Keys)) {
+		paths = append(paths, org.Keys[label])
+	}
+	return paths
+}
+
+// fixKeyMode restricts the key at path to 0600, as ssh requires, and reports
+// whether it had to. A missing key, or anything but a regular file, is left
+// alone for the other checks to report.
+func fixKeyMode(path string) (bool, error) {
+	path = utils.ExpandPath(path)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm() == 0600 {
+		return false, nil
+	}
+	return true, os.Chmod(path, 0600)
+}
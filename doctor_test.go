@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestRunDoctor(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		missingKey    bool
+		answer        bool
+		expectErr     error
+		expectOrgs    []string
+		expectDefault string
+		expectMode    os.FileMode
+		expectOutput  []string
+	}{
+		{
+			name:         "problems are only reported",
+			args:         []string{"doctor"},
+			expectErr:    ErrConfigProblems,
+			expectOrgs:   []string{"org1", "org2"},
+			expectMode:   0644,
+			expectOutput: []string{"error: org1:", "warning: " + domain.ErrNoDefaultOrg.Error()},
+		},
+		{
+			name:          "a mis-permed key and a missing default are fixed",
+			args:          []string{"doctor", "--fix"},
+			expectOrgs:    []string{"org1", "org2"},
+			expectDefault: "org1",
+			expectMode:    0600,
+			expectOutput:  []string{"Fixed: org1: set the permissions", "Fixed: made org1 the default", "No problems found"},
+		},
+		{
+			name:          "an org with a missing key is removed",
+			args:          []string{"doctor", "--fix", "--yes"},
+			missingKey:    true,
+			expectOrgs:    []string{"org1"},
+			expectDefault: "org1",
+			expectMode:    0600,
+			expectOutput:  []string{"Fixed: removed org2, whose SSH key is missing", "No problems found"},
+		},
+		{
+			name:          "declining keeps an org with a missing key",
+			args:          []string{"doctor", "--fix"},
+			missingKey:    true,
+			expectErr:     ErrConfigProblems,
+			expectOrgs:    []string{"org1", "org2"},
+			expectDefault: "org1",
+			expectMode:    0600,
+			expectOutput:  []string{"error: org2:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key1, _ := utils.GenerateTestSSHKey(t)
+			key2, _ := utils.GenerateTestSSHKey(t)
+			if err := os.Chmod(key1, 0644); err != nil {
+				t.Fatalf("failed to set key permissions: %v", err)
+			}
+			if tt.missingKey {
+				key2 = filepath.Join(t.TempDir(), "missing_key")
+			}
+
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			conf := &domain.Config{
+				Organizations: []*domain.Organization{
+					{Name: "org1", SSHKeyPath: key1},
+					{Name: "org2", SSHKeyPath: key2},
+				},
+			}
+			confBytes, err := conf.JSON()
+			if err != nil {
+				t.Fatalf("failed to marshal test config: %v", err)
+			}
+			utils.WriteConfigFileForTest(t, configPath, confBytes)
+			configfile.SetDefaultConfigPath(configPath)
+
+			fake := &fakeConfirmer{answer: tt.answer}
+			orig := newConfirmer
+			newConfirmer = func(*cli.Command) confirmer { return fake }
+			t.Cleanup(func() { newConfirmer = orig })
+
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "doctor",
+				Action: runDoctor,
+				Writer: &out,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "fix"},
+					&cli.BoolFlag{Name: "yes"},
+				},
+			}
+			err = cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v\n%s", tt.expectErr, err, out.String())
+			}
+			for _, expected := range tt.expectOutput {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("expected output to contain %q, got:\n%s", expected, out.String())
+				}
+			}
+
+			info, err := os.Stat(key1)
+			if err != nil {
+				t.Fatalf("failed to stat key: %v", err)
+			}
+			if info.Mode().Perm() != tt.expectMode {
+				t.Errorf("expected key mode %v, got %v", tt.expectMode, info.Mode().Perm())
+			}
+
+			conf, err = configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			if names := conf.OrganizationNames(); strings.Join(names, ",") != strings.Join(tt.expectOrgs, ",") {
+				t.Errorf("expected organizations %v, got %v", tt.expectOrgs, names)
+			}
+			if defaults := strings.Join(conf.MarkedDefaults(), ","); defaults != tt.expectDefault {
+				t.Errorf("expected default %q, got %q", tt.expectDefault, defaults)
+			}
+			// removing an organization is destructive, so it is declined without a terminal
+			for i, destructive := range fake.destructive {
+				if !destructive {
+					t.Errorf("expected question %q to be destructive", fake.questions[i])
+				}
+			}
+		})
+	}
+}
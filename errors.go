@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"ghc/internal/clone"
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+	"ghc/internal/ghcli"
+	"ghc/internal/selfupdate"
+	"ghc/internal/sshagent"
+	"ghc/internal/sshconfig"
+	"ghc/internal/sshkey"
+)
+
+// unknownErrorKind is the kind of an error that matches none of errorKinds.
+const unknownErrorKind = "unknown"
+
+// errorKinds maps the sentinel errors to the stable kind reported by --json-errors.
+// They are checked in order with errors.Is, so more specific errors must come
+// before any error they wrap, and the generic os errors come last.
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	// organizations
+	{ErrBrokenOrganizations, "broken_orgs"},
+	{ErrNumArguments, "wrong_number_of_arguments"},
+	{ErrConflictingFlags, "conflicting_flags"},
+	{ErrConfigProblems, "config_problems"},
+	{ErrInvalidExpiry, "invalid_expiry"},
+	{ErrInvalidColor, "invalid_color"},
+	{ErrInvalidFormat, "invalid_format"},
+	{ErrInvalidKeyURL, "invalid_key_url"},
+	{ErrKeyChangeDeclined, "key_change_declined"},
+	{ErrKeyDestExists, "key_destination_exists"},
+	{ErrNoMigrateTarget, "no_migrate_target"},
+	{ErrInvalidSelection, "invalid_selection"},
+	{ErrKeyDownload, "key_download_failed"},
+	{ErrNoKeyToMove, "no_key_to_move"},
+	{ErrNotPrivateKey, "not_private_key"},
+	{ErrNotPublicKey, "not_public_key"},
+	{ErrPrivateKeyNotFound, "private_key_not_found"},
+
+	// configuration
+	{domain.ErrCantRemoveDefault, "cant_remove_default"},
+	{domain.ErrDefaultOverrideNotFound, "default_override_not_found"},
+	{domain.ErrDefaultRequired, "default_required"},
+	{domain.ErrDuplicateOrganization, "duplicate_org"},
+	{domain.ErrEmptyOrganizationName, "empty_org_name"},
+	{domain.ErrEmptySSHKeyPath, "empty_ssh_key_path"},
+	{domain.ErrInvalidHost, "invalid_host"},
+	{domain.ErrInvalidIdentityAgent, "invalid_identity_agent"},
+	{domain.ErrInvalidMoveDirection, "invalid_move_direction"},
+	{domain.ErrInvalidOrgName, "invalid_org_name"},
+	{domain.ErrInvalidSSHTimeout, "invalid_ssh_timeout"},
+	{domain.ErrKeyLabelNotFound, "key_label_not_found"},
+	{domain.ErrKnownHostsNotFound, "known_hosts_not_found"},
+	{domain.ErrMultipleDefaults, "multiple_defaults"},
+	{domain.ErrNoDefaultOrg, "no_default_org"},
+	{domain.ErrNoOrganizations, "no_orgs"},
+	{domain.ErrOrgExpired, "org_expired"},
+	{domain.ErrOrganizationNotFound, "org_not_found"},
+	{domain.ErrOrgNotFound, "org_not_found"},
+	{domain.ErrRSAKeyTooSmall, "rsa_key_too_small"},
+	{domain.ErrSharedSSHKey, "shared_ssh_key"},
+	{domain.ErrSSHKeyNotRegularFile, "ssh_key_not_regular_file"},
+	{configfile.ErrConfigExists, "config_exists"},
+	{configfile.ErrConfigNotFound, "config_not_found"},
+	{configfile.ErrConfigPermissions, "config_permissions"},
+	{configfile.ErrHomeDirNotFound, "home_dir_not_found"},
+	{configfile.ErrInvalidProfile, "invalid_profile"},
+	{configfile.ErrProfileWithConfig, "conflicting_flags"},
+
+	// importing
+	{ghcli.ErrHostsNotFound, "gh_hosts_not_found"},
+	{ghcli.ErrNoAccounts, "no_gh_accounts"},
+
+	// keys
+	{sshkey.ErrInvalidKeyType, "invalid_key_type"},
+	{sshkey.ErrKeyTypeMismatch, "key_type_mismatch"},
+	{sshkey.ErrPairMismatch, "key_pair_mismatch"},
+	{sshkey.ErrUnreadableKey, "unreadable_key"},
+	{sshagent.ErrAgentNotRunning, "agent_not_running"},
+	{sshagent.ErrNoAgentKeys, "no_agent_keys"},
+
+	// cloning
+	{clone.ErrConflictingFlags, "conflicting_flags"},
+	{clone.ErrDefaultBranch, "default_branch"},
+	{clone.ErrDestinationExists, "destination_exists"},
+	{clone.ErrDestNotWritable, "destination_not_writable"},
+	{clone.ErrEmptyRepoURL, "empty_repo_url"},
+	{clone.ErrGitNotFound, "git_not_found"},
+	{clone.ErrHookFailed, "hook_failed"},
+	{clone.ErrInvalidArgs, "missing_repo_url"},
+	{clone.ErrInvalidCheckout, "invalid_checkout"},
+	{clone.ErrInvalidDepth, "invalid_depth"},
+	{clone.ErrInvalidFilter, "invalid_filter"},
+	{clone.ErrInvalidJobs, "invalid_jobs"},
+	{clone.ErrInvalidOnExists, "invalid_on_exists"},
+	{clone.ErrInvalidOrigin, "invalid_origin"},
+	{clone.ErrInvalidParallel, "invalid_parallel"},
+	{clone.ErrInvalidPushURL, "invalid_push_url"},
+	{clone.ErrInvalidRepoList, "invalid_repo_list"},
+	{clone.ErrInvalidRepoURLFormat, "invalid_repo_url"},
+	{clone.ErrInvalidSince, "invalid_since"},
+	{clone.ErrKeyRejected, "key_rejected"},
+	{clone.ErrNoOriginRemote, "no_origin_remote"},
+	{clone.ErrOrgNameNotFound, "org_name_not_in_url"},
+	{clone.ErrOverwriteDeclined, "overwrite_declined"},
+	{clone.ErrSparsePathRequired, "sparse_path_required"},
+	{clone.ErrSSHConfigDirPermissions, "ssh_config_dir_permissions"},
+	{clone.ErrSSHConfigDirOwner, "ssh_config_dir_owner"},
+	{clone.ErrUnknownGitVersion, "unknown_git_version"},
+	{clone.ErrUnsafeOverwrite, "unsafe_overwrite"},
+	{sshconfig.ErrInvalidOption, "invalid_ssh_option"},
+	{sshconfig.ErrInvalidProxy, "invalid_proxy"},
+	{sshconfig.ErrInvalidTemplate, "invalid_ssh_config_template"},
+
+	// self-update
+	{selfupdate.ErrDevelopmentBuild, "development_build"},
+	{selfupdate.ErrInvalidVersion, "invalid_version"},
+	{selfupdate.ErrNoReleaseAsset, "no_release_asset"},
+	{selfupdate.ErrNoChecksum, "no_checksum"},
+	{selfupdate.ErrChecksumMismatch, "checksum_mismatch"},
+
+	// anything else from the file system
+	{os.ErrNotExist, "not_found"},
+	{os.ErrPermission, "permission_denied"},
+}
+
+// errorKind returns the stable kind of err, or unknownErrorKind if it matches
+// none of the known errors.
+func errorKind(err error) string {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return unknownErrorKind
+}
+
+// jsonError is how an error is written with --json-errors.
+type jsonError struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind"`
+}
+
+// writeError writes err to w, as "Error: ..." or, if asJSON is set, as a
+// single line of JSON with the error message and its kind.
+func writeError(w io.Writer, err error, asJSON bool) {
+	if err == nil {
+		return
+	}
+	if asJSON {
+		data, jsonErr := json.Marshal(jsonError{Error: err.Error(), Kind: errorKind(err)})
+		if jsonErr == nil {
+			fmt.Fprintf(w, "%s\n", data)
+			return
+		}
+	}
+	fmt.Fprintf(w, "Error: %v\n", err)
+}
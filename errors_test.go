@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"ghc/internal/clone"
+	"ghc/internal/domain"
+)
+
+func TestErrorKind(t *testing.T) {
+	// every known error maps to its kind, even when wrapped
+	for _, k := range errorKinds {
+		t.Run(k.kind, func(t *testing.T) {
+			if got := errorKind(k.err); got != k.kind {
+				t.Errorf("expected %s for %v, got %s", k.kind, k.err, got)
+			}
+			wrapped := fmt.Errorf("cloneRepo: %w: details", k.err)
+			if got := errorKind(wrapped); got != k.kind {
+				t.Errorf("expected %s for wrapped %v, got %s", k.kind, k.err, got)
+			}
+		})
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "unknown error", err: errors.New("boom"), expected: unknownErrorKind},
+		{name: "specific error before os error", err: fmt.Errorf("%w: %w", domain.ErrKnownHostsNotFound, os.ErrNotExist), expected: "known_hosts_not_found"},
+		{name: "org name rule", err: fmt.Errorf("%w: %w", domain.ErrInvalidOrgName, domain.ErrOrgNameTooLong), expected: "invalid_org_name"},
+		{name: "file system error", err: &os.PathError{Op: "open", Path: "/key", Err: os.ErrNotExist}, expected: "not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorKind(tt.err); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	err := fmt.Errorf("cloneRepo: %w", domain.ErrOrganizationNotFound)
+
+	// plain text by default
+	var buf bytes.Buffer
+	writeError(&buf, err, false)
+	if expected := "Error: cloneRepo: organization not found\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// one line of JSON with --json-errors
+	buf.Reset()
+	writeError(&buf, err, true)
+	var got jsonError
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("expected valid JSON, got %q: %v", buf.String(), err)
+	}
+	expected := jsonError{Error: "cloneRepo: organization not found", Kind: "org_not_found"}
+	if got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+		t.Errorf("expected a single line, got %q", buf.String())
+	}
+
+	// nothing for no error
+	buf.Reset()
+	writeError(&buf, nil, true)
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+
+	// errors from other packages keep their kind
+	buf.Reset()
+	writeError(&buf, clone.ErrGitNotFound, true)
+	if !bytes.Contains(buf.Bytes(), []byte(`"kind":"git_not_found"`)) {
+		t.Errorf("expected git_not_found, got %q", buf.String())
+	}
+}
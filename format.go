@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghc/internal/domain"
+	"ghc/internal/utils"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"gopkg.in/yaml.v3"
+)
+
+// listFormat renders the organizations in a configuration to w.
+type listFormat func(w io.Writer, conf *domain.Config, opts listOptions) error
+
+// listOptions are the optional columns of "org list".
+type listOptions struct {
+	fingerprint bool // show the SHA256 fingerprint of each organization's key
+}
+
+// missingFingerprint is shown instead of the fingerprint of a key that is
+// missing or can't be read.
+const missingFingerprint = "-"
+
+// fingerprint returns the SHA256 fingerprint of the organization's key, or
+// missingFingerprint if it can't be read.
+func fingerprint(org *domain.Organization) string {
+	fp, err := org.FingerprintSHA256()
+	if err != nil {
+		return missingFingerprint
+	}
+	return fp
+}
+
+// anyExpiry reports whether any organization has an expiry time, in which case
+// the table and tsv formats show an expiry column.
+func anyExpiry(conf *domain.Config) bool {
+	expiring := conf.Filter(func(org *domain.Organization) bool {
+		return org.ExpiresAt != nil
+	})
+	return len(expiring) > 0
+}
+
+// expiry formats when the organization expires, flagging it if it has
+// expired, or returns "" if it never does.
+func expiry(org *domain.Organization) string {
+	if org.ExpiresAt == nil {
+		return ""
+	}
+	formatted := org.ExpiresAt.Format(time.RFC3339)
+	if org.Expired() {
+		formatted += " (expired)"
+	}
+	return formatted
+}
+
+// listedOrganization is an organization as rendered by the json and yaml
+// formats, with its key's fingerprint when asked for.
+type listedOrganization struct {
+	domain.Organization `yaml:",inline"`
+	Fingerprint         string `json:"fingerprint,omitempty" yaml:"fingerprint,omitempty"`
+}
+
+// listedOrganizations returns the organizations to render as json or yaml.
+// Keys that can't be read have no fingerprint.
+func listedOrganizations(conf *domain.Config, opts listOptions) []listedOrganization {
+	orgs := make([]listedOrganization, 0, len(conf.Organizations))
+	for _, org := range conf.Organizations {
+		listed := listedOrganization{Organization: *org}
+		if opts.fingerprint {
+			listed.Fingerprint, _ = org.FingerprintSHA256()
+		}
+		orgs = append(orgs, listed)
+	}
+	return orgs
+}
+
+// listFormats are the output formats supported by "org list", by name.
+var listFormats = map[string]listFormat{
+	"table": formatTable,
+	"json":  formatJSON,
+	"yaml":  formatYAML,
+	"tsv":   formatTSV,
+}
+
+// listFormatNames returns the names of the supported list formats, sorted.
+func listFormatNames() []string {
+	return slices.Sorted(maps.Keys(listFormats))
+}
+
+// formatTable renders the organizations as a human-readable table,
+// marking the effective default organization with an asterisk, and
+// expired organizations when any organization has an expiry.
+func formatTable(w io.Writer, conf *domain.Config, opts listOptions) error {
+	// create formatters
+	header := utils.NewColor(color.FgGreen, color.Underline).SprintfFunc()
+
+	columns := []any{"Org Name", "SSH Key Path", "Default"}
+	showExpiry := anyExpiry(conf)
+	if showExpiry {
+		columns = append(columns, "Expires")
+	}
+	if opts.fingerprint {
+		columns = append(columns, "Fingerprint")
+	}
+	tbl := table.New(columns...)
+	tbl.WithHeaderFormatter(header).WithPadding(2).WithWriter(w)
+
+	// only the effective default is marked, even if more than one org claims it
+	def, _ := conf.DefaultOrg()
+
+	// add rows to the table
+	for _, org := range conf.Organizations {
+		defChar := " "
+		if org == def {
+			defChar = "*"
+		}
+		row := []any{org.Name, org.SSHKeyPath, defChar}
+		if showExpiry {
+			row = append(row, expiry(org))
+		}
+		if opts.fingerprint {
+			row = append(row, fingerprint(org))
+		}
+		tbl.AddRow(row...)
+	}
+	fmt.Fprintln(w, "")
+	tbl.Print()
+	fmt.Fprintln(w, "")
+	return nil
+}
+
+// formatJSON renders the organizations as an indented JSON array.
+func formatJSON(w io.Writer, conf *domain.Config, opts listOptions) error {
+	data, err := json.MarshalIndent(listedOrganizations(conf, opts), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// formatYAML renders the organizations as a YAML sequence.
+func formatYAML(w io.Writer, conf *domain.Config, opts listOptions) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(listedOrganizations(conf, opts)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// formatTSV renders the organizations as tab-separated values with a header
+// row, for use in scripts. The expires_at column is only present when any
+// organization has an expiry.
+func formatTSV(w io.Writer, conf *domain.Config, opts listOptions) error {
+	header := []string{"name", "ssh_key_path", "is_default"}
+	showExpiry := anyExpiry(conf)
+	if showExpiry {
+		header = append(header, "expires_at")
+	}
+	if opts.fingerprint {
+		header = append(header, "fingerprint")
+	}
+	if _, err := fmt.Fprintln(w, strings.Join(header, "\t")); err != nil {
+		return err
+	}
+	for _, org := range conf.Organizations {
+		row := []string{org.Name, org.SSHKeyPath, strconv.FormatBool(org.IsDefault)}
+		if showExpiry {
+			row = append(row, expiry(org))
+		}
+		if opts.fingerprint {
+			row = append(row, fingerprint(org))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,14 +6,13 @@ require (
 	github.com/fatih/color v1.18.0
 	github.com/google/uuid v1.6.0
 	github.com/knadh/koanf v1.5.0
+	github.com/mattn/go-isatty v0.0.20
 	github.com/rodaine/table v1.3.0
 	github.com/urfave/cli/v3 v3.1.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/mattn/go-colorable v0.1.14 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-)
+require github.com/mattn/go-colorable v0.1.14 // indirect
 
 require (
 	github.com/fsnotify/fsnotify v1.8.0 // indirect
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+	"ghc/internal/ghcli"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+// ghImport is a gh account to import as an organization, with the SSH key chosen for it.
+type ghImport struct {
+	account ghcli.Account
+	keyPath string
+	name    string // the organization name, the lowercased user
+}
+
+// importGH bootstraps organizations from the accounts the GitHub CLI is logged
+// in to. gh authenticates with tokens rather than SSH keys, so each account is
+// mapped to a key: the "key" flag for every account, or otherwise the key the
+// user enters when asked, where an empty answer skips the account.
+//
+// Each account becomes an organization named after its user, lowercased like
+// "org set --canonicalize", on its host. An account whose name is already an
+// organization on another host, from the configuration or an earlier account,
+// is skipped with a note, rather than moving that organization to its host.
+// If the configuration has no default yet, the active github.com account
+// becomes the default.
+//
+// Returns an error if gh's hosts file can't be read, it holds no accounts,
+// an organization is invalid, such as when its key doesn't exist, or the
+// configuration enforces a default and still has none, in which case nothing
+// is imported.
+func importGH(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 0 {
+		return fmt.Errorf("%w: expected 0, got %d", ErrNumArguments, c.NArg())
+	}
+
+	// read the accounts gh is logged in to
+	hostsPath := c.String("hosts")
+	if hostsPath == "" {
+		hostsPath = ghcli.HostsPath()
+	}
+	accounts, err := ghcli.ReadAccounts(utils.ExpandPath(hostsPath))
+	if err != nil {
+		return err
+	}
+
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+
+	// map each account to an SSH key
+	imports, err := chooseGHKeys(accounts, utils.ExpandPath(c.String("key")), c.Root().Reader, c.Root().Writer)
+	if err != nil {
+		return err
+	}
+	if len(imports) == 0 {
+		fmt.Fprintln(c.Root().Writer, "No organizations imported")
+		return nil
+	}
+
+	var imported, skipped []ghImport
+	err = configfile.UpdateConfigAt(configPath, func(conf *domain.Config) error {
+		imported, skipped = nil, nil
+		hasDefault := slices.ContainsFunc(conf.Organizations, func(org *domain.Organization) bool { return org.IsDefault })
+		for _, imp := range imports {
+			imp.name = domain.CanonicalOrganizationName(imp.account.User)
+			// the same name on another host, such as github.com and an enterprise server
+			if org, err := conf.GetOrganization(imp.name); err == nil && orgHostName(org) != imp.account.Host {
+				skipped = append(skipped, imp)
+				continue
+			}
+			makeDefault := !hasDefault && imp.account.Active && imp.account.Host == "github.com"
+			if err := conf.SetOrganization(imp.name, imp.keyPath, makeDefault); err != nil {
+				return err
+			}
+			if imp.account.Host != "github.com" {
+				if err := conf.SetHost(imp.name, imp.account.Host); err != nil {
+					return err
+				}
+			}
+			hasDefault = hasDefault || makeDefault
+			imported = append(imported, imp)
+		}
+		return conf.CheckEnforceDefault()
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, imp := range imported {
+		fmt.Fprintf(c.Root().Writer, "Imported organization '%s' from the gh account on %s\n", imp.name, imp.account.Host)
+	}
+	for _, imp := range skipped {
+		fmt.Fprintf(c.Root().ErrWriter, "Skipped the gh account %s on %s: organization '%s' is already on another host\n", imp.account.User, imp.account.Host, imp.name)
+	}
+	return nil
+}
+
+// orgHostName returns the host org clones from, which is github.com unless it has its own.
+func orgHostName(org *domain.Organization) string {
+	if org.Host != "" {
+		return org.Host
+	}
+	return "github.com"
+}
+
+// chooseGHKeys maps each account to keyPath, or, if keyPath is empty, to the
+// key read from r after asking on w. Accounts given an empty answer are skipped.
+func chooseGHKeys(accounts []ghcli.Account, keyPath string, r io.Reader, w io.Writer) ([]ghImport, error) {
+	imports := make([]ghImport, 0, len(accounts))
+	if keyPath != "" {
+		for _, account := range accounts {
+			imports = append(imports, ghImport{account: account, keyPath: keyPath})
+		}
+		return imports, nil
+	}
+
+	answers := bufio.NewReader(r)
+	for _, account := range accounts {
+		active := ""
+		if account.Active {
+			active = ", active"
+		}
+		fmt.Fprintf(w, "SSH key for %s (%s%s), empty to skip: ", account.User, account.Host, active)
+		answer, err := answers.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if answer = strings.TrimSpace(answer); answer != "" {
+			imports = append(imports, ghImport{account: account, keyPath: utils.ExpandPath(answer)})
+		}
+		if err == io.EOF {
+			fmt.Fprintln(w)
+			break
+		}
+	}
+	return imports, nil
+}
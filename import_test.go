@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+	"ghc/internal/ghcli"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestImportGH(t *testing.T) {
+	workKey, _ := utils.GenerateTestSSHKey(t)
+	enterpriseKey, _ := utils.GenerateTestSSHKey(t)
+
+	hostsPath := filepath.Join(t.TempDir(), "hosts.yml")
+	hosts := `github.com:
+    users:
+        octocat:
+        hubot:
+    user: octocat
+ghe.example.com:
+    users:
+        monalisa:
+    user: monalisa
+`
+	if err := os.WriteFile(hostsPath, []byte(hosts), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	newCommand := func(stdin string, out *bytes.Buffer) *cli.Command {
+		return &cli.Command{
+			Name:   "gh",
+			Action: importGH,
+			Reader: strings.NewReader(stdin),
+			Writer: out,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "hosts"},
+				&cli.StringFlag{Name: "key"},
+			},
+		}
+	}
+
+	t.Run("asks for each key", func(t *testing.T) {
+		configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+
+		// hubot is skipped, octocat and monalisa get keys
+		var out bytes.Buffer
+		stdin := "\n" + workKey + "\n" + enterpriseKey + "\n"
+		if err := newCommand(stdin, &out).Run(t.Context(), []string{"gh", "--hosts", hostsPath}); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+
+		conf, err := configfile.LoadConfig()
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		if len(conf.Organizations) != 2 {
+			t.Fatalf("expected 2 organizations, got %+v", conf.Organizations)
+		}
+		octocat, err := conf.GetOrganization("octocat")
+		if err != nil || octocat.SSHKeyPath != workKey || !octocat.IsDefault || octocat.Host != "" {
+			t.Errorf("unexpected octocat %+v (%v)", octocat, err)
+		}
+		monalisa, err := conf.GetOrganization("monalisa")
+		if err != nil || monalisa.SSHKeyPath != enterpriseKey || monalisa.IsDefault || monalisa.Host != "ghe.example.com" {
+			t.Errorf("unexpected monalisa %+v (%v)", monalisa, err)
+		}
+		if !strings.Contains(out.String(), "SSH key for octocat (github.com, active)") {
+			t.Errorf("expected a prompt for octocat, got %q", out.String())
+		}
+	})
+
+	t.Run("one key for every account", func(t *testing.T) {
+		configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+
+		var out bytes.Buffer
+		if err := newCommand("", &out).Run(t.Context(), []string{"gh", "--hosts", hostsPath, "--key", workKey}); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		conf, err := configfile.LoadConfig()
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		if names := conf.OrganizationNames(); strings.Join(names, ",") != "hubot,monalisa,octocat" {
+			t.Errorf("expected every account, got %v", names)
+		}
+	})
+
+	t.Run("everything skipped", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.json")
+		configfile.SetDefaultConfigPath(configPath)
+
+		var out bytes.Buffer
+		if err := newCommand("", &out).Run(t.Context(), []string{"gh", "--hosts", hostsPath}); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		if !strings.Contains(out.String(), "No organizations imported") {
+			t.Errorf("expected nothing to be imported, got %q", out.String())
+		}
+		if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+			t.Errorf("expected no config to be written, got %v", err)
+		}
+	})
+
+	t.Run("names are lowercased and clashes across hosts skipped", func(t *testing.T) {
+		configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+		clashPath := filepath.Join(t.TempDir(), "hosts.yml")
+		clash := `github.com:
+    users:
+        OctoCat:
+    user: OctoCat
+ghe.example.com:
+    users:
+        octocat:
+    user: octocat
+`
+		if err := os.WriteFile(clashPath, []byte(clash), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		var out, errOut bytes.Buffer
+		cmd := newCommand("", &out)
+		cmd.ErrWriter = &errOut
+		if err := cmd.Run(t.Context(), []string{"gh", "--hosts", clashPath, "--key", workKey}); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		conf, err := configfile.LoadConfig()
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		if names := conf.OrganizationNames(); strings.Join(names, ",") != "octocat" {
+			t.Errorf("expected only octocat, got %v", names)
+		}
+		if octocat, err := conf.GetOrganization("octocat"); err != nil || octocat.Host != "" {
+			t.Errorf("expected octocat to stay on github.com, got %+v (%v)", octocat, err)
+		}
+		if !strings.Contains(errOut.String(), "Skipped the gh account octocat on ghe.example.com") {
+			t.Errorf("expected the clash to be reported, got %q", errOut.String())
+		}
+	})
+
+	t.Run("enforced default", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.json")
+		utils.WriteConfigFileForTest(t, configPath, []byte(`{"enforce_default": true, "organizations": []}`))
+		configfile.SetDefaultConfigPath(configPath)
+
+		// only the enterprise account, which never becomes the default
+		var out bytes.Buffer
+		stdin := "\n\n" + enterpriseKey + "\n"
+		err := newCommand(stdin, &out).Run(t.Context(), []string{"gh", "--hosts", hostsPath})
+		if !errors.Is(err, domain.ErrDefaultRequired) {
+			t.Fatalf("expected %v, got %v", domain.ErrDefaultRequired, err)
+		}
+		conf, err := configfile.LoadConfigFrom(configPath)
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		if len(conf.Organizations) != 0 {
+			t.Errorf("expected nothing to be imported, got %+v", conf.Organizations)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+
+		var out bytes.Buffer
+		err := newCommand("", &out).Run(t.Context(), []string{"gh", "--hosts", hostsPath, "--key", "/does/not/exist"})
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %v, got %v", os.ErrNotExist, err)
+		}
+	})
+
+	t.Run("gh not logged in", func(t *testing.T) {
+		var out bytes.Buffer
+		err := newCommand("", &out).Run(t.Context(), []string{"gh", "--hosts", filepath.Join(t.TempDir(), "hosts.yml")})
+		if !errors.Is(err, ghcli.ErrHostsNotFound) {
+			t.Errorf("expected %v, got %v", ghcli.ErrHostsNotFound, err)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package clone
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"ghc/internal/sshconfig"
+)
+
+// ErrKeyRejected is returned by CheckAccess when GitHub doesn't accept the key.
+var ErrKeyRejected = errors.New("GitHub did not accept the SSH key")
+
+// accessGreeting is part of the message GitHub replies with to ssh -T once the
+// key is accepted. ssh still exits with status 1, as there is no shell access,
+// so the greeting is what tells success from failure.
+const accessGreeting = "successfully authenticated"
+
+// CheckAccess confirms that GitHub accepts sshKeyPath on host, or on github.com
+// if host is empty. It writes a throwaway SSH config for the key, runs
+// ssh -T git@host through the runner without prompting, and removes the config.
+// ssh's output is written to w if the key is rejected.
+// It returns an error wrapping ErrKeyRejected if GitHub doesn't accept the key.
+func CheckAccess(host, sshKeyPath string, w io.Writer) error {
+	if host == "" {
+		host = sshHostName
+	}
+
+	dir := sshConfigDir()
+	if err := ensurePrivateDir(dir, false, w); err != nil {
+		return err
+	}
+	// never prompt for a passphrase or a host key, so this can't hang
+	configPath, err := sshconfig.CreateSSHConfigFile(host, sshKeyPath, dir, sshconfig.Option{Key: "BatchMode", Value: "yes"})
+	if err != nil {
+		return err
+	}
+	defer os.Remove(configPath)
+
+	var output bytes.Buffer
+	cmd := exec.Command("ssh", "-F", configPath, "-T", "git@"+host)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	runErr := runner.Run(cmd)
+	if strings.Contains(output.String(), accessGreeting) {
+		return nil
+	}
+
+	w.Write(output.Bytes())
+	if runErr != nil {
+		return fmt.Errorf("%w: %s on %s: %w", ErrKeyRejected, sshKeyPath, host, runErr)
+	}
+	return fmt.Errorf("%w: %s on %s", ErrKeyRejected, sshKeyPath, host)
+}
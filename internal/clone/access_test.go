@@ -0,0 +1,73 @@
+package clone
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"ghc/internal/utils"
+)
+
+func TestCheckAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		host       string
+		output     string
+		err        error
+		expectErr  error
+		expectHost string
+	}{
+		{
+			name:       "accepted",
+			output:     "Hi haukened! You've successfully authenticated, but GitHub does not provide shell access.\n",
+			err:        errors.New("exit status 1"),
+			expectHost: "github.com",
+		},
+		{
+			name:       "accepted on a custom host",
+			host:       "github.corp.example.com",
+			output:     "Hi haukened! You've successfully authenticated, but GitHub does not provide shell access.\n",
+			expectHost: "github.corp.example.com",
+		},
+		{
+			name:       "rejected",
+			output:     "git@github.com: Permission denied (publickey).\n",
+			err:        errors.New("exit status 255"),
+			expectErr:  ErrKeyRejected,
+			expectHost: "github.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfigDir, mock := setupCloneTest(t)
+			mock.output = tt.output
+			mock.hookErr = tt.err
+			privateKey, _ := utils.GenerateTestSSHKey(t)
+
+			var stderr bytes.Buffer
+			err := CheckAccess(tt.host, privateKey, &stderr)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			if len(mock.cmds) != 1 {
+				t.Fatalf("expected 1 command, got %d", len(mock.cmds))
+			}
+			args := mock.cmds[0].Args
+			if args[0] != "ssh" || !slices.Contains(args, "-T") || args[len(args)-1] != "git@"+tt.expectHost {
+				t.Errorf("expected ssh -T git@%s, got %v", tt.expectHost, args)
+			}
+
+			// the throwaway config is removed either way
+			if entries, _ := os.ReadDir(sshConfigDir); len(entries) != 0 {
+				t.Errorf("expected no ssh config files, got %d", len(entries))
+			}
+			if tt.expectErr != nil && stderr.String() != tt.output {
+				t.Errorf("expected ssh's output %q, got %q", tt.output, stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package clone
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// cloneFunc clones a single job, writing its output to stdout and stderr.
+type cloneFunc func(job cloneJob, stdout, stderr io.Writer) error
+
+// cloneOutcome is how a single clone in a batch ended.
+type cloneOutcome struct {
+	job      cloneJob
+	err      error
+	duration time.Duration
+}
+
+// cloneResult holds the outcome and buffered output of a single clone in a batch.
+type cloneResult struct {
+	cloneOutcome
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	done   chan struct{}
+}
+
+// cloneBatch clones every job using a pool of up to parallel workers.
+//
+// Each job's output is buffered while it runs, then flushed to stdout and stderr
+// in submission order as soon as it and every job before it have completed.
+// This keeps the log readable even though clones finish out of order.
+//
+// All jobs are attempted. The outcome of every job is returned in submission
+// order, and the returned error joins the errors of every failed job.
+func cloneBatch(jobs []cloneJob, parallel int, clone cloneFunc, stdout, stderr io.Writer) ([]cloneOutcome, error) {
+	results := make([]*cloneResult, len(jobs))
+	for i, job := range jobs {
+		results[i] = &cloneResult{cloneOutcome: cloneOutcome{job: job}, done: make(chan struct{})}
+	}
+
+	// the semaphore bounds the number of clones running at once
+	sem := make(chan struct{}, parallel)
+	go func() {
+		for _, result := range results {
+			sem <- struct{}{}
+			go func() {
+				defer func() { <-sem }()
+				defer close(result.done)
+				start := time.Now()
+				result.err = clone(result.job, &result.stdout, &result.stderr)
+				result.duration = time.Since(start)
+			}()
+		}
+	}()
+
+	// flush the results in submission order
+	outcomes := make([]cloneOutcome, 0, len(results))
+	var errs []error
+	for _, result := range results {
+		<-result.done
+		stdout.Write(result.stdout.Bytes())
+		stderr.Write(result.stderr.Bytes())
+		outcomes = append(outcomes, result.cloneOutcome)
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.job.repoURL, result.err))
+		}
+	}
+
+	return outcomes, errors.Join(errs...)
+}
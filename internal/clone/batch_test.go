@@ -0,0 +1,117 @@
+package clone
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCloneBatch_OrderedOutput(t *testing.T) {
+	jobs := []cloneJob{
+		{repoURL: "git@github.com:org/slow.git"},
+		{repoURL: "git@github.com:org/medium.git"},
+		{repoURL: "git@github.com:org/fast.git"},
+	}
+	delays := map[string]time.Duration{
+		"git@github.com:org/slow.git":   60 * time.Millisecond,
+		"git@github.com:org/medium.git": 30 * time.Millisecond,
+		"git@github.com:org/fast.git":   0,
+	}
+
+	// track the number of clones running at once
+	var running, maxRunning atomic.Int32
+	clone := func(job cloneJob, stdout, stderr io.Writer) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		// finish out of order, writing output part way through
+		fmt.Fprintf(stdout, "start %s\n", job.repoURL)
+		time.Sleep(delays[job.repoURL])
+		fmt.Fprintf(stdout, "end %s\n", job.repoURL)
+		return nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	if _, err := cloneBatch(jobs, 3, clone, &stdout, &stderr); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	// each job's output must be contiguous and in submission order
+	var expected strings.Builder
+	for _, job := range jobs {
+		fmt.Fprintf(&expected, "start %s\nend %s\n", job.repoURL, job.repoURL)
+	}
+	if stdout.String() != expected.String() {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected.String(), stdout.String())
+	}
+	if maxRunning.Load() < 2 {
+		t.Errorf("expected clones to run concurrently, max running was %d", maxRunning.Load())
+	}
+}
+
+func TestCloneBatch_BoundedWorkers(t *testing.T) {
+	jobs := make([]cloneJob, 6)
+	for i := range jobs {
+		jobs[i] = cloneJob{repoURL: fmt.Sprintf("git@github.com:org/repo%d.git", i)}
+	}
+
+	var running, maxRunning atomic.Int32
+	clone := func(job cloneJob, stdout, stderr io.Writer) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	if _, err := cloneBatch(jobs, 2, clone, &stdout, &stderr); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if maxRunning.Load() > 2 {
+		t.Errorf("expected at most 2 clones at once, got %d", maxRunning.Load())
+	}
+}
+
+func TestCloneBatch_Errors(t *testing.T) {
+	errClone := errors.New("clone failed")
+	jobs := []cloneJob{
+		{repoURL: "git@github.com:org/good.git"},
+		{repoURL: "git@github.com:org/bad.git"},
+	}
+	clone := func(job cloneJob, stdout, stderr io.Writer) error {
+		if strings.Contains(job.repoURL, "bad") {
+			return errClone
+		}
+		return nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	outcomes, err := cloneBatch(jobs, 2, clone, &stdout, &stderr)
+	if !errors.Is(err, errClone) {
+		t.Fatalf("expected %v, got %v", errClone, err)
+	}
+	// every job's outcome is returned in submission order
+	if len(outcomes) != 2 || outcomes[0].job != jobs[0] || outcomes[0].err != nil || !errors.Is(outcomes[1].err, errClone) {
+		t.Errorf("unexpected outcomes: %+v", outcomes)
+	}
+	if !strings.Contains(err.Error(), "git@github.com:org/bad.git") {
+		t.Errorf("expected error to name the failed repository, got %v", err)
+	}
+}
@@ -1,14 +1,26 @@
 package clone
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
 
 	"ghc/internal/configfile"
+	"ghc/internal/domain"
 	"ghc/internal/sshconfig"
 	"ghc/internal/utils"
 
@@ -16,10 +28,31 @@ import (
 )
 
 var (
-	ErrInvalidArgs          = errors.New("exactly one argument is required")
-	ErrEmptyRepoURL         = errors.New("repository URL is required")
-	ErrInvalidRepoURLFormat = errors.New("invalid GitHub SSH URL format")
-	ErrOrgNameNotFound      = errors.New("organization name not found in the URL")
+	ErrConflictingFlags        = errors.New("conflicting flags")
+	ErrDefaultBranch           = errors.New("unable to determine the default branch")
+	ErrDestinationExists       = errors.New("destination already exists, use --dest-exists-ok to skip it, or --on-exists to skip, pull or overwrite it")
+	ErrDestNotWritable         = errors.New("destination's parent directory is not writable, clone from a directory you can write to")
+	ErrGitNotFound             = errors.New("git was not found on PATH, install it from https://git-scm.com/downloads")
+	ErrHookFailed              = errors.New("post-clone hook failed")
+	ErrInvalidArgs             = errors.New("at least one repository URL is required")
+	ErrInvalidCheckout         = errors.New("invalid --checkout, expected a branch, tag or commit")
+	ErrInvalidDepth            = errors.New("depth must be at least 1")
+	ErrInvalidFilter           = errors.New("invalid --filter, expected blob:none, blob:limit=N or tree:DEPTH")
+	ErrInvalidJobs             = errors.New("jobs must be at least 1")
+	ErrInvalidOnExists         = errors.New("invalid --on-exists mode, expected error, skip, pull or overwrite")
+	ErrInvalidOrigin           = errors.New("invalid --origin, expected a git remote name such as upstream")
+	ErrInvalidParallel         = errors.New("parallel must be at least 1")
+	ErrInvalidSince            = errors.New("invalid --since, expected a positive duration such as 30d, 2w or 12h")
+	ErrInvalidPushURL          = errors.New("invalid --push-url, expected user@host:path or an ssh://, https:// or git:// URL")
+	ErrInvalidRepoList         = errors.New("invalid repository list, expected URL<TAB>LAST_PUSHED lines with RFC 3339 times")
+	ErrEmptyRepoURL            = errors.New("repository URL is required")
+	ErrInvalidRepoURLFormat    = errors.New("invalid GitHub SSH URL format")
+	ErrOrgNameNotFound         = errors.New("organization name not found in the URL")
+	ErrOverwriteDeclined       = errors.New("overwrite declined")
+	ErrSparsePathRequired      = errors.New("--sparse requires at least one --sparse-path")
+	ErrSSHConfigDirPermissions = errors.New("SSH config directory is accessible by other users")
+	ErrSSHConfigDirOwner       = errors.New("SSH config directory belongs to another user")
+	ErrUnsafeOverwrite         = errors.New("refusing to remove a directory that isn't in the working directory")
 )
 
 // You can override this variable at build time using -ldflags:
@@ -32,82 +65,1015 @@ var sshHostName = "github.com"
 // go build -ldflags="-X 'ghc/internal/clone.defaultSSHConfigPath=/custom/path'" ./cmd/ghc
 var defaultSSHConfigPath = "$HOME/.config/ghc/ssh_configs/"
 
-// cloneRepo clones a Git repository using the provided context and command.
-// It validates the repository URL, retrieves the SSH key for the organization,
+// filterRegex matches the partial clone filter specs git supports for cloning
+// from GitHub: blob:none, blob:limit=N with an optional k, m or g suffix, and tree:DEPTH.
+var filterRegex = regexp.MustCompile(`^(blob:none|blob:limit=[0-9]+[kmgKMG]?|tree:[0-9]+)$`)
+
+// defaultRemote is the name git gives the remote a repository is cloned from.
+const defaultRemote = "origin"
+
+// validRemoteName reports whether name is a legal git remote name, which
+// must make a valid ref name as refs/remotes/NAME, as git check-ref-format
+// checks: no leading dash, whitespace, control or special characters, empty or
+// dot-leading components, "..", "@{", or trailing "." or ".lock".
+func validRemoteName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") ||
+		strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") ||
+		strings.Contains(name, "..") || strings.Contains(name, "@{") ||
+		strings.ContainsFunc(name, func(r rune) bool {
+			return r < 0x20 || r == 0x7f || unicode.IsSpace(r) || strings.ContainsRune(`~^:?*[\`, r)
+		}) {
+		return false
+	}
+	for component := range strings.SplitSeq(name, "/") {
+		if component == "" || strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// What to do when a repository's destination directory already exists.
+const (
+	onExistsError     = "error"     // fail
+	onExistsSkip      = "skip"      // leave it alone
+	onExistsPull      = "pull"      // pull into it instead of cloning
+	onExistsOverwrite = "overwrite" // remove it, after confirmation, and clone again
+)
+
+// pushURLRegex matches the push URLs git accepts for a mirror: scp-like
+// user@host:path, or an ssh, https or git URL with a host and a path.
+var pushURLRegex = regexp.MustCompile(`^([^@:/\s]+@[^:/\s]+:[^\s]+|(ssh|https|git)://[^/\s]+/[^\s]+)$`)
+
+// cloneOptions holds the flag values that control how each repository is cloned.
+type cloneOptions struct {
+	keepConfig bool   // keep the generated SSH config file after cloning
+	configOnly bool   // generate the SSH config file without cloning
+	keyPath    string // use this SSH key instead of resolving one from the config
+	keyLabel   string // use the organization's key with this label instead of its primary key
+	saveOrg    string // save unconfigured organizations with this SSH key before cloning
+
+	sshOptions  []sshconfig.Option // extra directives for the generated SSH config
+	sshTemplate *template.Template // renders the generated SSH config, or nil for the built-in template
+	proxy       string             // proxy to tunnel SSH through, if any
+
+	sshCommandEnv  bool // pass the SSH command with GIT_SSH_COMMAND instead of core.sshCommand
+	fixPermissions bool // restrict an SSH config directory that other users can access, instead of failing
+
+	sparsePaths       []string // check out only these paths, with a sparse checkout
+	filter            string   // partial clone filter spec, such as blob:none
+	depth             int      // shallow clone with this many commits, or 0 for the full history
+	checkout          string   // branch, tag or commit to check out after cloning
+	origin            string   // name of the remote cloned from, instead of origin
+	recurseSubmodules bool     // also clone or update the repository's submodules
+	jobs              int      // number of submodules fetched at once, or 0 for git's default
+
+	onExists string                     // what to do when the destination already exists
+	stdin    io.Reader                  // where confirmations are read from
+	ask      func(question string) bool // asks a yes/no question, one at a time across parallel clones
+
+	pushURL            string // push to this URL instead of the one cloned from
+	printDefaultBranch bool   // print the default branch after cloning
+	writeMarker        bool   // write a .ghc file recording the organization and key into the clone
+
+	noHooks          bool // skip the post-clone hook
+	ignoreHookErrors bool // report a failing post-clone hook without failing the clone
+
+	hooks Hooks // callbacks fired at each stage of the clone, set with WithHooks
+
+	trace bool // write each git clone or pull command, with its SSH setup, to stderr before running it
+}
+
+// cloneArgs returns the extra git clone arguments for the options: no checkout
+// if only part of the repository will be checked out, any partial clone filter,
+// depth or remote name, and the submodule arguments from pullArgs.
+func (o cloneOptions) cloneArgs() []string {
+	var args []string
+	if len(o.sparsePaths) > 0 {
+		args = append(args, "--no-checkout")
+	}
+	if o.filter != "" {
+		args = append(args, "--filter="+o.filter)
+	}
+	if o.depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(o.depth))
+	}
+	if o.origin != "" {
+		args = append(args, "--origin", o.origin)
+	}
+	// the submodule arguments are the same for clone and pull
+	return append(args, o.pullArgs()...)
+}
+
+// remote returns the name of the remote a repository is cloned from.
+func (o cloneOptions) remote() string {
+	if o.origin != "" {
+		return o.origin
+	}
+	return defaultRemote
+}
+
+// pullArgs returns the extra git pull arguments for the options: updating
+// submodules, and how many to fetch at once.
+func (o cloneOptions) pullArgs() []string {
+	var args []string
+	if o.recurseSubmodules {
+		args = append(args, "--recurse-submodules")
+	}
+	if o.jobs > 0 {
+		args = append(args, "--jobs", strconv.Itoa(o.jobs))
+	}
+	return args
+}
+
+// cloneJob is a single repository to clone, with its parsed host and organization name.
+type cloneJob struct {
+	repoURL string
+	host    string
+	orgName string
+}
+
+// cloneRepo clones one or more Git repositories using the provided context and command.
+// It validates the repository URLs, retrieves the SSH key for each organization,
 // creates the necessary SSH config file, and then runs the clone command.
+// When more than one repository is given, they are cloned as a batch with up to
+// --parallel clones running at once. With --report-file, the outcome of every
+// repository is also written to a JSON file, and with --ndjson, an event is
+// written to stdout as each clone starts and completes. With --print-org, the
+// URLs are only parsed, and the organization of each is printed instead of
+// cloning. With --stdin or --stdin-null, more URLs are read from stdin, one per
+// line or NUL-separated. With --from-file, more URLs are read from a file, one
+// per line, skipping blank lines and "#" comments, and with --since, only those
+// pushed within that window, going by the timestamp column of the file.
 func CloneRepo(ctx context.Context, c *cli.Command) error {
-	// Step 0: Check nargs and args
-	if c.NArg() != 1 {
+	// Step 0: Check nargs and args, adding any URLs read from a file or stdin
+	repoURLs := c.Args().Slice()
+	var since time.Time
+	if c.IsSet("since") {
+		if c.String("from-file") == "" {
+			return fmt.Errorf("cloneRepo: %w: --since filters the --from-file list, which isn't set", ErrInvalidSince)
+		}
+		window, err := parseSince(c.String("since"))
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+		since = now().Add(-window)
+	}
+	if fromFile := c.String("from-file"); fromFile != "" {
+		fileURLs, err := readRepoURLFile(fromFile, since)
+		if err != nil {
+			return fmt.Errorf("cloneRepo: reading repository URLs from %s: %w", fromFile, err)
+		}
+		repoURLs = append(repoURLs, fileURLs...)
+	}
+	readStdin := c.Bool("stdin") || c.Bool("stdin-null")
+	if readStdin {
+		if c.Bool("config-stdin") {
+			return fmt.Errorf("cloneRepo: %w: --config-stdin and --stdin both read stdin", ErrConflictingFlags)
+		}
+		stdinURLs, err := readRepoURLs(c.Root().Reader, c.Bool("stdin-null"))
+		if err != nil {
+			return fmt.Errorf("cloneRepo: reading repository URLs from stdin: %w", err)
+		}
+		repoURLs = append(repoURLs, stdinURLs...)
+	}
+	if len(repoURLs) < 1 {
+		// nothing recent enough is nothing to do, rather than a mistake
+		if !since.IsZero() {
+			fmt.Fprintf(c.Root().ErrWriter, "No repositories in %s were pushed within %s\n", c.String("from-file"), c.String("since"))
+			return nil
+		}
 		return fmt.Errorf("cloneRepo: %w", ErrInvalidArgs)
 	}
 
-	repoURL := c.Args().First()
-	if repoURL == "" {
-		return fmt.Errorf("cloneRepo: %w", ErrEmptyRepoURL)
+	// Step 1: Parse the repository URLs, after applying the url.*.insteadOf
+	// rewrites from the user's git config, as git itself would. If the git
+	// config can't be read, the URLs are used as given.
+	var rewrites []urlRewrite
+	if output, err := readURLRewrites(); err == nil {
+		rewrites = parseURLRewrites(output)
+	}
+	jobs := make([]cloneJob, 0, len(repoURLs))
+	for _, repoURL := range repoURLs {
+		if repoURL == "" {
+			return fmt.Errorf("cloneRepo: %w", ErrEmptyRepoURL)
+		}
+		repoURL = rewriteURL(repoURL, rewrites)
+		host, orgName, err := parseGitSSHRepoUrl(repoURL)
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+		if orgName == "" {
+			return fmt.Errorf("cloneRepo: %w", ErrOrgNameNotFound)
+		}
+		jobs = append(jobs, cloneJob{repoURL: repoURL, host: host, orgName: orgName})
+	}
+
+	// with --print-org, only the organization of each repository is printed,
+	// without reading the config or running git
+	if c.Bool("print-org") {
+		for _, job := range jobs {
+			fmt.Fprintln(c.Root().Writer, job.orgName)
+		}
+		return nil
+	}
+
+	opts := cloneOptions{
+		keepConfig: c.Bool("keep-config"),
+		configOnly: c.Bool("config-only"),
+		keyPath:    utils.ExpandPath(c.String("key")),
+		keyLabel:   c.String("key-label"),
+		saveOrg:    utils.ExpandPath(c.String("save-org")),
+		proxy:      c.String("proxy"),
+
+		fixPermissions: c.Bool("fix-permissions"),
+
+		pushURL:  c.String("push-url"),
+		filter:   c.String("filter"),
+		depth:    int(c.Int("depth")),
+		checkout: c.String("checkout"),
+		origin:   c.String("origin"),
+		onExists: c.String("on-exists"),
+		stdin:    c.Root().Reader,
+
+		recurseSubmodules: c.Bool("recurse-submodules"),
+		jobs:              int(c.Int("jobs")),
+
+		printDefaultBranch: c.Bool("print-default-branch"),
+		writeMarker:        c.Bool("write-marker"),
+
+		noHooks:          c.Bool("no-hooks"),
+		ignoreHookErrors: c.Bool("ignore-hook-errors"),
+
+		trace: c.Bool("trace"),
+		hooks: hooksFrom(ctx),
+	}
+	// stdin is used up by the URLs, so nothing can be confirmed
+	if readStdin {
+		opts.stdin = strings.NewReader("")
+	}
+	if opts.pushURL != "" {
+		if !pushURLRegex.MatchString(opts.pushURL) {
+			return fmt.Errorf("cloneRepo: %w: %q", ErrInvalidPushURL, opts.pushURL)
+		}
+		if len(jobs) > 1 {
+			return fmt.Errorf("cloneRepo: %w: --push-url applies to a single repository", ErrConflictingFlags)
+		}
+		if opts.configOnly {
+			return fmt.Errorf("cloneRepo: %w: --config-only doesn't clone, so --push-url cannot be set", ErrConflictingFlags)
+		}
+	}
+	if c.IsSet("depth") && opts.depth < 1 {
+		return fmt.Errorf("cloneRepo: %w: %d", ErrInvalidDepth, opts.depth)
+	}
+	if opts.checkout != "" {
+		// a leading dash would be read as an option to git checkout
+		if strings.HasPrefix(opts.checkout, "-") || strings.ContainsFunc(opts.checkout, unicode.IsSpace) {
+			return fmt.Errorf("cloneRepo: %w: %q", ErrInvalidCheckout, opts.checkout)
+		}
+		if opts.configOnly {
+			return fmt.Errorf("cloneRepo: %w: --config-only doesn't clone, so --checkout cannot be set", ErrConflictingFlags)
+		}
+		// a shallow clone only fetches the most recent commits of the default branch,
+		// so any other branch, tag or commit may be missing
+		if opts.depth > 0 {
+			fmt.Fprintf(c.Root().ErrWriter, "Warning: a --depth %d clone only fetches the default branch, so --checkout %s may not be found; clone without --depth if the checkout fails\n", opts.depth, opts.checkout)
+		}
+	}
+	if c.IsSet("origin") && !validRemoteName(opts.origin) {
+		return fmt.Errorf("cloneRepo: %w: %q", ErrInvalidOrigin, opts.origin)
+	}
+	if c.IsSet("jobs") && opts.jobs < 1 {
+		return fmt.Errorf("cloneRepo: %w: %d", ErrInvalidJobs, opts.jobs)
+	}
+	if opts.recurseSubmodules && c.Bool("sparse") {
+		return fmt.Errorf("cloneRepo: %w: --sparse checks out after cloning, so --recurse-submodules cannot be set", ErrConflictingFlags)
+	}
+	if opts.filter != "" && !filterRegex.MatchString(opts.filter) {
+		return fmt.Errorf("cloneRepo: %w: %q", ErrInvalidFilter, opts.filter)
+	}
+	// --dest-exists-ok is shorthand for --on-exists=skip
+	if c.Bool("dest-exists-ok") {
+		if opts.onExists != "" && opts.onExists != onExistsError && opts.onExists != onExistsSkip {
+			return fmt.Errorf("cloneRepo: %w: --dest-exists-ok cannot be used with --on-exists=%s", ErrConflictingFlags, opts.onExists)
+		}
+		opts.onExists = onExistsSkip
+	}
+	switch opts.onExists {
+	case "":
+		opts.onExists = onExistsError
+	case onExistsError, onExistsSkip, onExistsPull, onExistsOverwrite:
+	default:
+		return fmt.Errorf("cloneRepo: %w: %q", ErrInvalidOnExists, opts.onExists)
+	}
+	if c.Bool("sparse") {
+		opts.sparsePaths = c.StringSlice("sparse-path")
+		if len(opts.sparsePaths) == 0 {
+			return fmt.Errorf("cloneRepo: %w", ErrSparsePathRequired)
+		}
+	} else if c.IsSet("sparse-path") {
+		return fmt.Errorf("cloneRepo: %w: --sparse-path requires --sparse", ErrConflictingFlags)
+	}
+	if opts.keyPath != "" && opts.saveOrg != "" {
+		return fmt.Errorf("cloneRepo: %w: --key and --save-org cannot be used together", ErrConflictingFlags)
+	}
+	if opts.keyPath != "" && opts.keyLabel != "" {
+		return fmt.Errorf("cloneRepo: %w: --key and --key-label cannot be used together", ErrConflictingFlags)
+	}
+	configStdin := c.Bool("config-stdin")
+	if configStdin && opts.keyPath != "" {
+		return fmt.Errorf("cloneRepo: %w: --config-stdin and --key cannot be used together", ErrConflictingFlags)
+	}
+	if configStdin && opts.saveOrg != "" {
+		return fmt.Errorf("cloneRepo: %w: --config-stdin and --save-org cannot be used together", ErrConflictingFlags)
+	}
+	// log the SSH handshake when debugging authentication failures
+	if c.Bool("verbose-ssh") {
+		opts.sshOptions = append(opts.sshOptions, sshconfig.Option{Key: "LogLevel", Value: "DEBUG3"})
+	}
+	for _, s := range c.StringSlice("ssh-option") {
+		opt, err := sshconfig.ParseOption(s)
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+		opts.sshOptions = append(opts.sshOptions, opt)
+	}
+
+	// Fail fast if git isn't installed, unless git won't be run at all
+	if !opts.configOnly {
+		if _, err := lookPath("git"); err != nil {
+			return fmt.Errorf("cloneRepo: %w", ErrGitNotFound)
+		}
+		// older git releases silently ignore core.sshCommand
+		opts.sshCommandEnv = !supportsSSHCommandConfig()
+	}
+
+	// Step 2: Load the config holding the SSH keys for each organization,
+	// unless a key was given directly or the config is piped in with
+	// --config-stdin, in which case the config file is never touched
+	var config *domain.Config
+	switch {
+	case opts.keyPath != "":
+		if err := domain.ValidateSSHKeyPath(opts.keyPath); err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+	case configStdin:
+		// the piped config is only held in memory, and stdin is used up by it
+		var err error
+		config, err = configfile.ParseConfig(c.Root().Reader)
+		if err != nil {
+			return fmt.Errorf("cloneRepo: reading config from stdin: %w", err)
+		}
+		configfile.ApplyEnvOverrides(config)
+		opts.stdin = strings.NewReader("")
+	default:
+		configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+		// register any unconfigured organizations before loading the config
+		if opts.saveOrg != "" {
+			if err := saveOrganizations(configPath, jobs, opts.saveOrg, c.Root().ErrWriter); err != nil {
+				return fmt.Errorf("cloneRepo: %w", err)
+			}
+		}
+		config, err = configfile.LoadConfigChecked(configPath, c.Bool("strict-config-permissions"), c.Root().ErrWriter)
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+	}
+	// the configured proxy applies unless --proxy or GHC_PROXY is set
+	if config != nil && opts.proxy == "" {
+		opts.proxy = config.Proxy
+	}
+
+	// render the SSH config with a custom template, from --ssh-config-template
+	// or the configuration, if there is one
+	templatePath := c.String("ssh-config-template")
+	if templatePath == "" && config != nil {
+		templatePath = config.SSHConfigTemplate
+	}
+	if templatePath != "" {
+		tmpl, err := sshconfig.ParseTemplate(utils.ExpandPath(templatePath))
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+		opts.sshTemplate = tmpl
+	}
+
+	// route SSH through the proxy, if there is one
+	if opts.proxy != "" {
+		opt, err := sshconfig.ProxyOption(opts.proxy)
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+		opts.sshOptions = append(opts.sshOptions, opt)
+	}
+
+	// parallel clones buffer their output, so questions are asked one at a time,
+	// straight on stderr, and each answer is read by the clone that asked
+	var askMu sync.Mutex
+	stdin := opts.stdin
+	opts.ask = func(question string) bool {
+		askMu.Lock()
+		defer askMu.Unlock()
+		// removing a directory is destructive, so without a terminal it's a no
+		return utils.Confirm(stdin, c.Root().ErrWriter, question, true)
+	}
+
+	// with --ndjson, stdout holds only the events, so the clone output goes to stderr
+	var events *eventWriter
+	stdout := c.Root().Writer
+	if c.Bool("ndjson") {
+		events = &eventWriter{w: c.Root().Writer}
+		stdout = c.Root().ErrWriter
+	}
+	clone := func(job cloneJob, stdout, stderr io.Writer) error {
+		events.started(job)
+		start := time.Now()
+		err := cloneOne(config, job, opts, stdout, stderr)
+		events.completed(cloneOutcome{job: job, err: err, duration: time.Since(start)})
+		return err
+	}
+
+	// a single repository streams its output directly
+	var outcomes []cloneOutcome
+	var err error
+	if len(jobs) == 1 {
+		start := time.Now()
+		err = clone(jobs[0], stdout, c.Root().ErrWriter)
+		outcomes = []cloneOutcome{{job: jobs[0], err: err, duration: time.Since(start)}}
+	} else {
+		parallel := c.Int("parallel")
+		if parallel < 1 {
+			return fmt.Errorf("cloneRepo: %w: %d", ErrInvalidParallel, parallel)
+		}
+		outcomes, err = cloneBatch(jobs, int(parallel), clone, stdout, c.Root().ErrWriter)
+	}
+
+	// report every outcome, even if some clones failed
+	if reportFile := c.String("report-file"); reportFile != "" {
+		if reportErr := writeReport(utils.ExpandPath(reportFile), outcomes); reportErr != nil {
+			err = errors.Join(err, fmt.Errorf("cloneRepo: writing report: %w", reportErr))
+		}
+	}
+	return err
+}
+
+// cloneOne clones a single repository, writing all output to stdout and stderr.
+func cloneOne(config *domain.Config, job cloneJob, opts cloneOptions, stdout, stderr io.Writer) error {
+	// Returns the organization, and with it the selected SSH key path, unless a key was given directly
+	var org *domain.Organization
+	sshKeyPath := opts.keyPath
+	if sshKeyPath == "" {
+		var err error
+		org, err = resolveOrganization(config, job.orgName, stderr)
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+		sshKeyPath, err = org.KeyPath(opts.keyLabel)
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+	}
+	if opts.hooks.OnResolveOrg != nil {
+		event := ResolveOrgEvent{RepoURL: job.repoURL, KeyPath: sshKeyPath}
+		if org != nil {
+			event.Org = org.Name
+		}
+		opts.hooks.OnResolveOrg(event)
+	}
+
+	// The URL must point at the organization's host, or github.com if it has none
+	host := orgHost(org)
+	if job.host != host {
+		return fmt.Errorf("cloneRepo: %w: expected a URL on %s, got %s", ErrInvalidRepoURLFormat, host, job.repoURL)
+	}
+
+	// Handle a destination that already exists, before anything is written
+	dir := repoDir(job.repoURL)
+	pull := false
+	if !opts.configOnly && dirExists(dir) {
+		switch opts.onExists {
+		case onExistsSkip:
+			fmt.Fprintf(stderr, "Skipping %s: already cloned in %s\n", job.repoURL, dir)
+			return nil
+		case onExistsPull:
+			pull = true
+		case onExistsOverwrite:
+			// only ever remove a plain child of the working directory
+			if dir != filepath.Base(dir) || dir == "." || dir == ".." {
+				return fmt.Errorf("cloneRepo: %w: %s", ErrUnsafeOverwrite, dir)
+			}
+			if !opts.ask(fmt.Sprintf("Remove the existing %s and clone again?", dir)) {
+				return fmt.Errorf("cloneRepo: %w: %s", ErrOverwriteDeclined, dir)
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("cloneRepo: %w", err)
+			}
+		default:
+			return fmt.Errorf("cloneRepo: %w: %s", ErrDestinationExists, dir)
+		}
+	}
+
+	// Fail fast if git couldn't create the destination, rather than leaving
+	// the user to dig a permission error out of git's output
+	if !opts.configOnly && !pull {
+		parent := filepath.Dir(dir)
+		if !dirWritable(parent) {
+			if abs, err := filepath.Abs(parent); err == nil {
+				parent = abs
+			}
+			return fmt.Errorf("cloneRepo: %w: %s", ErrDestNotWritable, parent)
+		}
+	}
+
+	// Steps 8-10 run right after the clone, while its SSH config is still
+	// there, since a partial clone (--filter) fetches missing objects when
+	// checking them out. env holds the SSH command, if git needs it passed.
+	afterClone := func(env []string) error {
+		// Step 8: Push to the mirror instead of the upstream, if requested
+		if opts.pushURL != "" {
+			if err := setPushURL(dir, opts.remote(), opts.pushURL, stdout, stderr); err != nil {
+				return fmt.Errorf("cloneRepo: %w", err)
+			}
+		}
+
+		// Step 9: Check out only the sparse paths, if requested
+		if len(opts.sparsePaths) > 0 {
+			if err := sparseCheckout(dir, opts.sparsePaths, env, stdout, stderr); err != nil {
+				return fmt.Errorf("cloneRepo: %w", err)
+			}
+		}
+
+		// Step 10: Check out the requested branch, tag or commit
+		if opts.checkout != "" {
+			if err := checkoutRef(dir, opts.checkout, env, stdout, stderr); err != nil {
+				return fmt.Errorf("cloneRepo: %w", err)
+			}
+		}
+		return nil
+	}
+
+	// Steps 3-7: Clone through the org's SSH host alias if it has one,
+	// otherwise through a generated SSH config file
+	var err error
+	if org != nil && org.HostAlias != "" {
+		if opts.configOnly {
+			return fmt.Errorf("cloneRepo: %w: org '%s' uses the SSH host alias '%s', so there is no SSH config to generate", ErrConflictingFlags, org.Name, org.HostAlias)
+		}
+		if len(opts.sshOptions) > 0 || org.KnownHosts != "" || org.IdentityAgent != "" || org.ConnectTimeout > 0 || org.ServerAliveInterval > 0 {
+			fmt.Fprintf(stderr, "Warning: org '%s' uses the SSH host alias '%s'; SSH options, proxies, known_hosts and identity agents from ghc are ignored\n", org.Name, org.HostAlias)
+		}
+		err = fetchWithHostAlias(org.HostAlias, job.repoURL, dir, pull, opts, stdout, stderr)
+		if err == nil && !pull {
+			// the alias stays in the user's SSH config
+			err = afterClone(nil)
+		}
+	} else {
+		err = fetchWithSSHConfig(org, job.repoURL, dir, host, sshKeyPath, pull, afterClone, opts, stdout, stderr)
+	}
+	if err != nil || pull || opts.configOnly {
+		// the remaining steps only apply to a fresh clone
+		return err
+	}
+
+	// Step 11: Report the repository's default branch, if requested
+	if opts.printDefaultBranch {
+		branch, err := defaultBranch(dir, opts.remote())
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+		fmt.Fprintln(stdout, branch)
 	}
 
-	// Step 1: Parse the repository URL
-	orgName, err := parseGitSSHRepoUrl(repoURL)
+	// Step 12: Record which organization and key the clone used, if requested
+	if opts.writeMarker {
+		m := marker{Org: job.orgName, Host: host, KeyPath: sshKeyPath, Repo: job.repoURL, ClonedAt: now().UTC().Truncate(time.Second)}
+		if org != nil {
+			m.Org = org.Name
+		}
+		if err := writeMarker(dir, m); err != nil {
+			return fmt.Errorf("cloneRepo: writing %s: %w", markerFile, err)
+		}
+	}
+
+	// Step 13: Run the post-clone hook in the cloned repository, if there is one
+	if opts.noHooks {
+		return nil
+	}
+	return runPostCloneHook(postCloneHook(config, org), dir, opts.ignoreHookErrors, stdout, stderr)
+}
+
+// fetchWithSSHConfig clones repoURL, or pulls into dir if pull is set, through
+// a generated SSH config file for host with the given key. After a clone,
+// afterClone runs with the environment holding the SSH command. The config
+// file is removed afterwards, unless opts asks to keep it, or only asks for the file.
+func fetchWithSSHConfig(org *domain.Organization, repoURL, dir, host, sshKeyPath string, pull bool, afterClone func(env []string) error, opts cloneOptions, stdout, stderr io.Writer) error {
+	// Step 3: Resolve the ghc config path
+	expandedSSHConfigPath := sshConfigDir()
+
+	// Step 4: Ensure the SSH config directory exists, and only we can access it
+	err := ensurePrivateDir(expandedSSHConfigPath, opts.fixPermissions, stderr)
 	if err != nil {
 		return fmt.Errorf("cloneRepo: %w", err)
 	}
-	if orgName == "" {
-		return fmt.Errorf("cloneRepo: %w", ErrOrgNameNotFound)
+
+	// one-off options come first, because ssh uses the first value it finds for a directive
+	sshOptions := slices.Clone(opts.sshOptions)
+
+	// pin the organization's host keys, if it has any
+	var knownHostsFile string
+	if org != nil && org.KnownHosts != "" {
+		var khOptions []sshconfig.Option
+		khOptions, knownHostsFile, err = sshconfig.KnownHostsOptions(utils.ExpandPath(org.KnownHosts), org.KnownHostsInline(), expandedSSHConfigPath)
+		if err != nil {
+			return fmt.Errorf("cloneRepo: %w", err)
+		}
+		sshOptions = append(sshOptions, khOptions...)
+	}
+
+	// use the organization's own agent, if it has one
+	if org != nil && org.IdentityAgent != "" {
+		sshOptions = append(sshOptions, sshconfig.Option{Key: "IdentityAgent", Value: org.IdentityAgent})
+	}
+
+	// tune the connection for flaky networks, if the organization asks for it
+	if org != nil {
+		sshOptions = append(sshOptions, sshconfig.TimeoutOptions(org.ConnectTimeout, org.ServerAliveInterval)...)
 	}
 
-	// Step 2: Get the SSH key for that organization
-	config, err := configfile.LoadConfig()
+	// Step 5: Create the SSH config file
+	configPath, err := sshconfig.CreateSSHConfigFileFromTemplate(opts.sshTemplate, host, sshKeyPath, expandedSSHConfigPath, sshOptions...)
 	if err != nil {
+		// nothing refers to the inline known_hosts file without the config
+		if knownHostsFile != "" {
+			os.Remove(knownHostsFile)
+		}
 		return fmt.Errorf("cloneRepo: %w", err)
 	}
+	if opts.hooks.OnConfigGenerated != nil {
+		opts.hooks.OnConfigGenerated(ConfigGeneratedEvent{RepoURL: repoURL, ConfigPath: configPath})
+	}
+
+	// Stop here if only the SSH config file was requested
+	if opts.configOnly {
+		fmt.Fprintln(stdout, configPath)
+		return nil
+	}
+
+	// Step 6: Clone the repository using the SSH config file.
+	// An existing clone is updated with a pull instead, if requested.
+	gitRunner := runner
+	if opts.trace {
+		gitRunner = traceRunner{next: runner, w: stderr, sshConfig: configPath}
+	}
+	err = opts.hooks.runGit(repoURL, dir, pull, func() error {
+		if pull {
+			return pullRepoUsingConfigFile(configPath, dir, gitRunner, opts.sshCommandEnv, stdout, stderr, opts.pullArgs()...)
+		}
+		return cloneRepoUsingConfigFile(configPath, repoURL, gitRunner, opts.sshCommandEnv, stdout, stderr, opts.cloneArgs()...)
+	})
+	if err == nil && !pull {
+		err = afterClone(sshCommandEnv(configPath))
+	}
+
+	// Step 7: Clean up the SSH config file, unless it should be kept for debugging
+	if opts.keepConfig {
+		fmt.Fprintf(stderr, "SSH config file retained at: %s\n", configPath)
+	} else {
+		if knownHostsFile != "" {
+			if rmErr := os.Remove(knownHostsFile); rmErr != nil && err == nil {
+				err = fmt.Errorf("cloneRepo: %w", rmErr)
+			}
+		}
+		if rmErr := os.Remove(configPath); rmErr != nil && err == nil {
+			err = fmt.Errorf("cloneRepo: %w", rmErr)
+		}
+	}
+	return err
+}
+
+// fetchWithHostAlias clones repoURL through alias, a Host from the user's own
+// SSH config, or pulls into dir if pull is set. Nothing is generated, so ssh
+// resolves the key and any other settings for the alias itself.
+// The extra git arguments from opts are passed to git clone before the URL, or to git pull.
+func fetchWithHostAlias(alias, repoURL, dir string, pull bool, opts cloneOptions, stdout, stderr io.Writer) error {
+	var cmd *exec.Cmd
+	if pull {
+		cmd = exec.Command("git", append([]string{"-C", dir, "pull"}, opts.pullArgs()...)...)
+	} else {
+		args := append([]string{"clone"}, opts.cloneArgs()...)
+		cmd = exec.Command("git", append(args, aliasURL(repoURL, alias))...)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return opts.hooks.runGit(repoURL, dir, pull, func() error {
+		if opts.trace {
+			return traceRunner{next: runner, w: stderr}.Run(cmd)
+		}
+		return runner.Run(cmd)
+	})
+}
+
+// aliasURL returns repoURL with its host replaced by alias, so
+// git@github.com:org/repo.git becomes git@alias:org/repo.git.
+func aliasURL(repoURL, alias string) string {
+	_, repoPath, _ := strings.Cut(repoURL, ":")
+	return "git@" + alias + ":" + repoPath
+}
+
+// postCloneHook returns the post-clone hook command for org. The organization's
+// own hook takes precedence over the global one in config. It returns an empty
+// string if there is no hook, or if a key was given directly and there is no config.
+func postCloneHook(config *domain.Config, org *domain.Organization) string {
+	if org != nil && org.PostClone != "" {
+		return org.PostClone
+	}
+	if config != nil {
+		return config.PostClone
+	}
+	return ""
+}
+
+// setPushURL points pushes from the clone in dir at url, leaving fetches on the
+// URL it was cloned from, which is the given remote.
+func setPushURL(dir, remote, url string, stdout, stderr io.Writer) error {
+	cmd := exec.Command("git", "-C", dir, "remote", "set-url", "--push", remote, url)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := runner.Run(cmd); err != nil {
+		return fmt.Errorf("git remote set-url: %w", err)
+	}
+	return nil
+}
+
+// checkoutRef checks out ref, a branch, tag or commit, in the clone in dir,
+// running git with env, or the current environment if env is nil.
+// The trailing "--" keeps git from reading ref as a path.
+func checkoutRef(dir, ref string, env []string, stdout, stderr io.Writer) error {
+	cmd := exec.Command("git", "-C", dir, "checkout", ref, "--")
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := runner.Run(cmd); err != nil {
+		return fmt.Errorf("git checkout %s: %w", ref, err)
+	}
+	return nil
+}
+
+// sparseCheckout limits the working tree of the repository cloned into dir
+// to paths, and then checks it out, running git with env as for checkoutRef.
+func sparseCheckout(dir string, paths []string, env []string, stdout, stderr io.Writer) error {
+	steps := [][]string{
+		append([]string{"-C", dir, "sparse-checkout", "set", "--"}, paths...),
+		{"-C", dir, "checkout"},
+	}
+	for _, args := range steps {
+		cmd := exec.Command("git", args...)
+		cmd.Env = env
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := runner.Run(cmd); err != nil {
+			return fmt.Errorf("git %s: %w", args[2], err)
+		}
+	}
+	return nil
+}
+
+// defaultBranch returns the name of the default branch of the repository
+// cloned into dir, as recorded in the HEAD of the remote it was cloned from.
+func defaultBranch(dir, remote string) (string, error) {
+	var out bytes.Buffer
+	cmd := exec.Command("git", "-C", dir, "symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD")
+	cmd.Stdout = &out
+	if err := runner.Run(cmd); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrDefaultBranch, err)
+	}
+
+	branch := strings.TrimPrefix(strings.TrimSpace(out.String()), remote+"/")
+	if branch == "" {
+		return "", ErrDefaultBranch
+	}
+	return branch, nil
+}
+
+// repoDir returns the directory git clones repoURL into, which is the
+// repository name without the ".git" suffix.
+func repoDir(repoURL string) string {
+	return strings.TrimSuffix(path.Base(repoURL), ".git")
+}
+
+// runPostCloneHook runs the hook command with the shell, in dir, using the
+// CommandRunner. If the hook fails, the failure is reported, and returned
+// as an error unless ignoreErrors is set.
+func runPostCloneHook(hook, dir string, ignoreErrors bool, stdout, stderr io.Writer) error {
+	if hook == "" {
+		return nil
+	}
 
-	// Returns the SSH key path for the organization
-	sshKeyPath, err := config.GetKeyPathForOrg(orgName)
+	cmd := exec.Command("sh", "-c", hook)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := runner.Run(cmd); err != nil {
+		if ignoreErrors {
+			fmt.Fprintf(stderr, "Warning: post-clone hook failed in %s: %v\n", dir, err)
+			return nil
+		}
+		return fmt.Errorf("cloneRepo: %w: %s: %w", ErrHookFailed, dir, err)
+	}
+	return nil
+}
+
+// saveOrganizations adds every organization in jobs that isn't configured yet
+// to the config file at configPath, using the SSH key at keyPath. Each
+// organization is validated before anything is written, and all of them are
+// saved in a single config update, so a bad organization name, or a config
+// that enforces a default but has none, leaves the config untouched.
+func saveOrganizations(configPath string, jobs []cloneJob, keyPath string, w io.Writer) error {
+	if err := domain.ValidateSSHKeyPath(keyPath); err != nil {
+		return err
+	}
+
+	var saved []string
+	err := configfile.UpdateConfigAt(configPath, func(cfg *domain.Config) error {
+		saved = nil
+		for _, job := range jobs {
+			if _, err := cfg.GetOrganization(job.orgName); err == nil {
+				continue
+			} else if !errors.Is(err, domain.ErrOrganizationNotFound) {
+				return err
+			}
+			if err := cfg.SetOrganization(job.orgName, keyPath, false); err != nil {
+				return err
+			}
+			// remember a host other than github.com, so later clones use it too
+			if job.host != sshHostName {
+				if err := cfg.SetHost(job.orgName, job.host); err != nil {
+					return err
+				}
+			}
+			saved = append(saved, job.orgName)
+		}
+		// the saved organizations are never the default, so one must already be marked
+		return cfg.CheckEnforceDefault()
+	})
 	if err != nil {
-		return fmt.Errorf("cloneRepo: %w", err)
+		return err
 	}
 
-	// Step 3: Resolve the ghc config path
-	expandedSSHConfigPath := utils.ExpandPath(defaultSSHConfigPath)
+	for _, name := range saved {
+		fmt.Fprintf(w, "Saved org '%s' with key %s\n", name, keyPath)
+	}
+	return nil
+}
+
+// sshConfigDir returns the directory generated SSH config files are written to.
+// If there is no home directory, such as in some locked-down CI environments,
+// the user's cache directory is used instead, or failing that a directory of
+// their own in the system temporary directory, which other users share.
+func sshConfigDir() string {
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		return utils.ExpandPath(defaultSSHConfigPath)
+	}
+	if cache, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(cache, "ghc", "ssh_configs")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("ghc-%d", currentUID()), "ssh_configs")
+}
 
-	// Step 4: Ensure the SSH config directory exists
-	err = os.MkdirAll(expandedSSHConfigPath, 0700)
+// currentUID returns the uid files created by ghc are owned by.
+// This can be overridden in tests.
+var currentUID = os.Geteuid
+
+// ensurePrivateDir creates dir with mode 0700 if it doesn't exist. Generated SSH
+// config files reveal key paths, so an existing directory that another user
+// owns is refused with ErrSSHConfigDirOwner, such as one planted in a shared
+// temporary directory. One that its group or others can access is refused
+// with ErrSSHConfigDirPermissions, unless fix is set, in which case it is
+// restricted to 0700 and a warning is written to w.
+func ensurePrivateDir(dir string, fix bool, w io.Writer) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
 	if err != nil {
-		return fmt.Errorf("cloneRepo: %w", err)
+		return err
+	}
+	if uid, ok := fileOwner(info); ok && uid != currentUID() {
+		return fmt.Errorf("%w: %s is owned by uid %d", ErrSSHConfigDirOwner, dir, uid)
+	}
+	mode := info.Mode().Perm()
+	if mode&0077 == 0 {
+		return nil
+	}
+	if !fix {
+		return fmt.Errorf("%w: %s has mode %04o, run: chmod 700 %s, or pass --fix-permissions", ErrSSHConfigDirPermissions, dir, mode, dir)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return err
 	}
+	fmt.Fprintf(w, "Warning: %s had mode %04o, restricted it to 0700\n", dir, mode)
+	return nil
+}
 
-	// Step 5: Create the SSH config file
-	configPath, err := sshconfig.CreateSSHConfigFile(sshHostName, sshKeyPath, expandedSSHConfigPath)
+// resolveOrganization returns the organization whose SSH key should be used.
+// If the organization is not configured and the default organization's key is
+// used instead, a warning is written to w so the user knows which identity is in use.
+func resolveOrganization(config *domain.Config, orgName string, w io.Writer) (*domain.Organization, error) {
+	org, fallback, err := config.ResolveOrganization(orgName)
 	if err != nil {
-		return fmt.Errorf("cloneRepo: %w", err)
+		return nil, err
 	}
+	if fallback {
+		fmt.Fprintf(w, "Warning: org '%s' not configured; using default org '%s' key\n", orgName, org.Name)
+	}
+	return org, nil
+}
 
-	// Step 6: Clone the repository using the SSH config file
-	runner := &defaultRunner{}
-	return cloneRepoUsingConfigFile(configPath, repoURL, runner)
+// orgHost returns the SSH host to clone from for org, which is its own host if
+// it has one, or sshHostName otherwise, including when no organization is used.
+func orgHost(org *domain.Organization) string {
+	if org != nil && org.Host != "" {
+		return org.Host
+	}
+	return sshHostName
 }
 
-// returns the GitHub User/Org and an error if it's not a GitHub SSH URL
-func parseGitSSHRepoUrl(url string) (string, error) {
+// repoURLRegex matches an SSH repository URL, capturing the host, the User/Org
+// and the repository.
+var repoURLRegex = regexp.MustCompile(`^git@([^:/\s]+):([^/]+)/([^/]+)$`)
+
+// returns the host and GitHub User/Org, and an error if it's not a GitHub SSH URL.
+// Whether the host is the right one depends on the organization, so it's checked later.
+// A repository named "." or "..", or only ".git", is rejected, as it would be
+// cloned into the working directory or its parent.
+func parseGitSSHRepoUrl(url string) (string, string, error) {
 	// format = git@github.com:haukened/ghc.git
-	// quote the metacharacters in the SSH host name
-	pattern := fmt.Sprintf(`^git@%s:([^/]+)/[^/]+(?:\.git)?$`, regexp.QuoteMeta(sshHostName))
-	re := regexp.MustCompile(pattern)
-	matches := re.FindStringSubmatch(url)
-	if len(matches) != 2 {
-		return "", ErrInvalidRepoURLFormat
+	matches := repoURLRegex.FindStringSubmatch(url)
+	if len(matches) != 4 {
+		return "", "", ErrInvalidRepoURLFormat
+	}
+	switch strings.TrimSuffix(matches[3], ".git") {
+	case "", ".", "..":
+		return "", "", fmt.Errorf("%w: invalid repository name in %s", ErrInvalidRepoURLFormat, url)
 	}
-	return matches[1], nil
+	return matches[1], matches[2], nil
+}
+
+// sshCommandEnv returns the current environment with GIT_SSH_COMMAND set to
+// use the SSH config file at configPath.
+func sshCommandEnv(configPath string) []string {
+	return append(os.Environ(), "GIT_SSH_COMMAND="+fmt.Sprintf("ssh -F %s", configPath))
 }
 
 // buildCloneCommand constructs an exec.Cmd to clone a Git repository using a custom SSH config file.
-func buildCloneCommand(configPath, cloneURI string) *exec.Cmd {
-	return exec.Command("git", "clone", "--config", fmt.Sprintf("core.sshCommand=ssh -F %s", configPath), cloneURI)
+// If useEnv is true, the SSH command is passed with the GIT_SSH_COMMAND environment
+// variable rather than core.sshCommand, for git releases that don't support the latter.
+// Any cloneArgs, such as "--no-checkout", are passed to git clone before the URI.
+func buildCloneCommand(configPath, cloneURI string, useEnv bool, cloneArgs ...string) *exec.Cmd {
+	sshCommand := fmt.Sprintf("ssh -F %s", configPath)
+	if useEnv {
+		args := append([]string{"clone"}, cloneArgs...)
+		cmd := exec.Command("git", append(args, cloneURI)...)
+		cmd.Env = sshCommandEnv(configPath)
+		return cmd
+	}
+	args := append([]string{"clone", "--config", "core.sshCommand=" + sshCommand}, cloneArgs...)
+	return exec.Command("git", append(args, cloneURI)...)
+}
+
+// buildPullCommand constructs an exec.Cmd to pull into an existing clone in dir
+// using a custom SSH config file. useEnv works as for buildCloneCommand.
+// Any pullArgs, such as "--recurse-submodules", are passed to git pull.
+func buildPullCommand(configPath, dir string, useEnv bool, pullArgs ...string) *exec.Cmd {
+	sshCommand := fmt.Sprintf("ssh -F %s", configPath)
+	if useEnv {
+		cmd := exec.Command("git", append([]string{"-C", dir, "pull"}, pullArgs...)...)
+		cmd.Env = sshCommandEnv(configPath)
+		return cmd
+	}
+	return exec.Command("git", append([]string{"-C", dir, "-c", "core.sshCommand=" + sshCommand, "pull"}, pullArgs...)...)
+}
+
+// pullRepoUsingConfigFile runs git pull in the existing clone in dir, using the provided CommandRunner.
+// The command's output is written to stdout and stderr.
+func pullRepoUsingConfigFile(configPath, dir string, runner CommandRunner, useEnv bool, stdout, stderr io.Writer, pullArgs ...string) error {
+	if !fileExists(configPath) {
+		return fmt.Errorf("%w: ssh config file %s does not exist", os.ErrNotExist, configPath)
+	}
+
+	cmd := buildPullCommand(configPath, dir, useEnv, pullArgs...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return runner.Run(cmd)
 }
 
 // cloneRepoUsingConfigFile validates the SSH config and clone URL, and runs the Git clone command using the provided CommandRunner.
+// The command's output is written to stdout and stderr.
 // It returns an error if validation fails or the clone command fails to run.
-func cloneRepoUsingConfigFile(configPath, cloneURI string, runner CommandRunner) error {
+func cloneRepoUsingConfigFile(configPath, cloneURI string, runner CommandRunner, useEnv bool, stdout, stderr io.Writer, cloneArgs ...string) error {
 	if !fileExists(configPath) {
 		return fmt.Errorf("%w: ssh config file %s does not exist", os.ErrNotExist, configPath)
 	}
@@ -117,7 +1083,9 @@ func cloneRepoUsingConfigFile(configPath, cloneURI string, runner CommandRunner)
 		return ErrInvalidRepoURLFormat
 	}
 
-	cmd := buildCloneCommand(configPath, cloneURI)
+	cmd := buildCloneCommand(configPath, cloneURI, useEnv, cloneArgs...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	return runner.Run(cmd)
 }
 
@@ -129,13 +1097,44 @@ type CommandRunner interface {
 
 type defaultRunner struct{}
 
-// Run executes the given command, streaming its output to stdout and stderr.
+// runner is the CommandRunner used by CloneRepo.
+// This can be overridden in tests.
+var runner CommandRunner = &defaultRunner{}
+
+// Run executes the given command, streaming its output to stdout and stderr
+// unless the command already has its own output writers.
 func (r *defaultRunner) Run(cmd *exec.Cmd) error {
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
 	return cmd.Run()
 }
 
+// dirExists reports whether path exists and is a directory.
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// dirWritable reports whether a file can be created in dir, by creating and
+// removing an empty one, which also accounts for ACLs and read-only mounts.
+func dirWritable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".ghc-write-check-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// lookPath searches for an executable on PATH.
+// This can be overridden in tests.
+var lookPath = exec.LookPath
+
 var fileExists = func(path string) bool {
 	// fileExists checks whether the specified file path exists on the filesystem.
 	// This function can be overridden in tests.
@@ -0,0 +1,2366 @@
+package clone
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+	"ghc/internal/sshconfig"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+// mockRunner records the commands it is asked to run instead of running them.
+// Git commands fail with err, and any other command, such as a hook, with hookErr.
+// Git commands other than clone write output to their stdout.
+type mockRunner struct {
+	cmds    []*exec.Cmd
+	err     error
+	hookErr error
+	output  string
+}
+
+func (m *mockRunner) Run(cmd *exec.Cmd) error {
+	m.cmds = append(m.cmds, cmd)
+	if m.output != "" && cmd.Stdout != nil && !slices.Contains(cmd.Args, "clone") {
+		io.WriteString(cmd.Stdout, m.output)
+	}
+	if filepath.Base(cmd.Path) != "git" {
+		return m.hookErr
+	}
+	return m.err
+}
+
+// setupCloneTest writes a config with a single default org, points the
+// clone package at a temporary SSH config directory, and installs a mock runner
+// and a git lookup that always finds a modern git.
+// It returns the SSH config directory and the mock runner.
+func setupCloneTest(t *testing.T) (string, *mockRunner) {
+	t.Helper()
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	tempDir := t.TempDir()
+
+	configPath := filepath.Join(tempDir, "config.json")
+	conf := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "haukened", SSHKeyPath: privateKey, IsDefault: true},
+		},
+	}
+	confBytes, err := conf.JSON()
+	if err != nil {
+		t.Fatalf("failed to marshal test config: %v", err)
+	}
+	utils.WriteConfigFileForTest(t, configPath, confBytes)
+	configfile.SetDefaultConfigPath(configPath)
+
+	sshConfigDir := filepath.Join(tempDir, "ssh_configs")
+	oldSSHConfigPath := defaultSSHConfigPath
+	defaultSSHConfigPath = sshConfigDir
+
+	mock := &mockRunner{}
+	oldRunner := runner
+	runner = mock
+
+	// pretend git is installed, since the mock runner never runs it
+	oldLookPath := lookPath
+	lookPath = func(file string) (string, error) {
+		return "/usr/bin/" + file, nil
+	}
+	oldReadGitVersion := readGitVersion
+	readGitVersion = func() (string, error) {
+		return "git version 2.40.0\n", nil
+	}
+
+	// ignore the git config of whoever runs the tests
+	oldReadURLRewrites := readURLRewrites
+	readURLRewrites = func() (string, error) {
+		return "", nil
+	}
+
+	// answer questions from the command's reader, as if it were a terminal
+	oldIsTerminal := utils.IsTerminal
+	utils.IsTerminal = func(any) bool { return true }
+
+	t.Cleanup(func() {
+		defaultSSHConfigPath = oldSSHConfigPath
+		runner = oldRunner
+		lookPath = oldLookPath
+		readGitVersion = oldReadGitVersion
+		readURLRewrites = oldReadURLRewrites
+		utils.IsTerminal = oldIsTerminal
+	})
+	return sshConfigDir, mock
+}
+
+// newCloneCommand returns a clone command wired up like the real CLI.
+func newCloneCommand(stdout, stderr *bytes.Buffer) *cli.Command {
+	return &cli.Command{
+		Name:      "clone",
+		Action:    CloneRepo,
+		Writer:    stdout,
+		ErrWriter: stderr,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "keep-config"},
+			&cli.BoolFlag{Name: "config-only"},
+			&cli.BoolFlag{Name: "print-org"},
+			&cli.StringFlag{Name: "ssh-config-template"},
+			&cli.BoolFlag{Name: "stdin"},
+			&cli.BoolFlag{Name: "stdin-null"},
+			&cli.StringFlag{Name: "from-file"},
+			&cli.StringFlag{Name: "origin"},
+			&cli.StringFlag{Name: "since"},
+			&cli.StringFlag{Name: "key"},
+			&cli.StringFlag{Name: "key-label"},
+			&cli.BoolFlag{Name: "config-stdin"},
+			&cli.StringFlag{Name: "save-org"},
+			&cli.BoolFlag{Name: "dest-exists-ok"},
+			&cli.StringFlag{Name: "on-exists", Value: "error"},
+			&cli.StringFlag{Name: "proxy", Sources: cli.EnvVars("GHC_PROXY")},
+			&cli.StringFlag{Name: "filter"},
+			&cli.IntFlag{Name: "depth"},
+			&cli.StringFlag{Name: "checkout"},
+			&cli.BoolFlag{Name: "recurse-submodules"},
+			&cli.IntFlag{Name: "jobs"},
+			&cli.StringFlag{Name: "push-url"},
+			&cli.StringFlag{Name: "report-file"},
+			&cli.BoolFlag{Name: "ndjson"},
+			&cli.BoolFlag{Name: "sparse"},
+			&cli.StringSliceFlag{Name: "sparse-path"},
+			&cli.BoolFlag{Name: "print-default-branch"},
+			&cli.BoolFlag{Name: "write-marker"},
+			&cli.BoolFlag{Name: "no-hooks"},
+			&cli.BoolFlag{Name: "ignore-hook-errors"},
+			&cli.BoolFlag{Name: "fix-permissions"},
+			&cli.BoolFlag{Name: "verbose-ssh"},
+			&cli.BoolFlag{Name: "trace"},
+			&cli.StringSliceFlag{Name: "ssh-option"},
+			&cli.IntFlag{Name: "parallel", Value: 1},
+		},
+	}
+}
+
+func TestResolveOrganization(t *testing.T) {
+	config := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: false},
+			{Name: "org2", SSHKeyPath: "/path/to/key2", IsDefault: true},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		orgName     string
+		expectsPath string
+		expectsWarn bool
+	}{
+		{
+			name:        "Exact match does not warn",
+			orgName:     "org1",
+			expectsPath: "/path/to/key1",
+			expectsWarn: false,
+		},
+		{
+			name:        "Fallback to default warns",
+			orgName:     "foo",
+			expectsPath: "/path/to/key2",
+			expectsWarn: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			org, err := resolveOrganization(config, tt.orgName, &stderr)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if org.SSHKeyPath != tt.expectsPath {
+				t.Errorf("expected %s, got %s", tt.expectsPath, org.SSHKeyPath)
+			}
+			warned := strings.Contains(stderr.String(), "org 'foo' not configured; using default org 'org2' key")
+			if warned != tt.expectsWarn {
+				t.Errorf("expected warning %v, got %q", tt.expectsWarn, stderr.String())
+			}
+		})
+	}
+}
+
+func TestCloneRepo_KeepConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expectsKept bool
+	}{
+		{
+			name:        "Config is cleaned up by default",
+			args:        []string{"clone", "git@github.com:haukened/ghc.git"},
+			expectsKept: false,
+		},
+		{
+			name:        "Config is kept with --keep-config",
+			args:        []string{"clone", "--keep-config", "git@github.com:haukened/ghc.git"},
+			expectsKept: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfigDir, mock := setupCloneTest(t)
+
+			var stdout, stderr bytes.Buffer
+			if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), tt.args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if len(mock.cmds) != 1 {
+				t.Fatalf("expected 1 command, got %d", len(mock.cmds))
+			}
+
+			entries, err := os.ReadDir(sshConfigDir)
+			if err != nil {
+				t.Fatalf("failed to read ssh config dir: %v", err)
+			}
+			kept := len(entries) == 1
+			if kept != tt.expectsKept {
+				t.Errorf("expected kept %v, got %d files", tt.expectsKept, len(entries))
+			}
+			if tt.expectsKept {
+				keptPath := filepath.Join(sshConfigDir, entries[0].Name())
+				if !strings.Contains(stderr.String(), keptPath) {
+					t.Errorf("expected retained path %s to be reported, got %q", keptPath, stderr.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCloneRepo_ConfigOnly(t *testing.T) {
+	_, mock := setupCloneTest(t)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--config-only", "git@github.com:haukened/ghc.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+	}
+
+	configPath := strings.TrimSpace(stdout.String())
+	if _, err := os.Stat(configPath); err != nil {
+		t.Errorf("expected config file %q to exist: %v", configPath, err)
+	}
+}
+
+func TestCloneRepo_Key(t *testing.T) {
+	sshConfigDir, mock := setupCloneTest(t)
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	// point the config at a path that doesn't exist, so any config access fails
+	configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "missing", "config.json"))
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--key", privateKey, "--keep-config", "git@github.com:unconfigured/ghc.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(mock.cmds) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(mock.cmds))
+	}
+
+	// the generated SSH config must use the given key
+	entries, err := os.ReadDir(sshConfigDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+	}
+	content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("failed to read ssh config: %v", err)
+	}
+	if !strings.Contains(string(content), "IdentityFile "+privateKey) {
+		t.Errorf("expected ssh config to use %s, got:\n%s", privateKey, content)
+	}
+}
+
+func TestCloneRepo_KeyBadPermissions(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	if err := os.Chmod(privateKey, 0644); err != nil {
+		t.Fatalf("failed to set key permissions: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--key", privateKey, "git@github.com:haukened/ghc.git"}
+	err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected os.ErrPermission, got %v", err)
+	}
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+	}
+}
+
+func TestCloneRepo_SSHOption(t *testing.T) {
+	sshConfigDir, _ := setupCloneTest(t)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{
+		"clone", "--keep-config",
+		"--ssh-option", "StrictHostKeyChecking=no",
+		"--ssh-option", "ConnectTimeout=10",
+		"git@github.com:haukened/ghc.git",
+	}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	entries, err := os.ReadDir(sshConfigDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+	}
+	content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("failed to read ssh config: %v", err)
+	}
+	for _, directive := range []string{"\tStrictHostKeyChecking no\n", "\tConnectTimeout 10\n"} {
+		if !strings.Contains(string(content), directive) {
+			t.Errorf("expected ssh config to contain %q, got:\n%s", directive, content)
+		}
+	}
+}
+
+func TestCloneRepo_GitNotFound(t *testing.T) {
+	sshConfigDir, mock := setupCloneTest(t)
+
+	oldLookPath := lookPath
+	lookPath = func(file string) (string, error) {
+		return "", exec.ErrNotFound
+	}
+	defer func() { lookPath = oldLookPath }()
+
+	var stdout, stderr bytes.Buffer
+	err := newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "git@github.com:haukened/ghc.git"})
+	if !errors.Is(err, ErrGitNotFound) {
+		t.Fatalf("expected %v, got %v", ErrGitNotFound, err)
+	}
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+	}
+
+	// no SSH config should have been generated
+	if _, err := os.Stat(sshConfigDir); !os.IsNotExist(err) {
+		t.Errorf("expected no ssh config directory, got %v", err)
+	}
+}
+
+func TestCloneRepo_SaveOrg(t *testing.T) {
+	sshConfigDir, mock := setupCloneTest(t)
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--save-org", privateKey, "--keep-config", "git@github.com:new-team/api.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(mock.cmds) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(mock.cmds))
+	}
+	if !strings.Contains(stderr.String(), "Saved org 'new-team' with key "+privateKey) {
+		t.Errorf("expected saved message, got %q", stderr.String())
+	}
+	if strings.Contains(stderr.String(), "not configured") {
+		t.Errorf("expected no fallback warning, got %q", stderr.String())
+	}
+
+	// the org must be saved without taking over the default
+	conf, err := configfile.LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	org, err := conf.GetOrganization("new-team")
+	if err != nil {
+		t.Fatalf("expected org to be saved, got %v", err)
+	}
+	if org.SSHKeyPath != privateKey || org.IsDefault {
+		t.Errorf("unexpected saved org: %+v", org)
+	}
+
+	// and the clone must use the saved key
+	entries, err := os.ReadDir(sshConfigDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+	}
+	content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("failed to read ssh config: %v", err)
+	}
+	if !strings.Contains(string(content), "IdentityFile "+privateKey) {
+		t.Errorf("expected ssh config to use %s, got:\n%s", privateKey, content)
+	}
+}
+
+func TestCloneRepo_SaveOrgEnforceDefault(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	if err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+		cfg.EnforceDefault = true
+		cfg.Organizations = []*domain.Organization{}
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to update config: %v", err)
+	}
+
+	// the saved org isn't the default, so the config would be left without one
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--save-org", privateKey, "git@github.com:new-team/api.git"}
+	err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+	if !errors.Is(err, domain.ErrDefaultRequired) {
+		t.Fatalf("expected %v, got %v", domain.ErrDefaultRequired, err)
+	}
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+	}
+	conf, err := configfile.LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if len(conf.Organizations) != 0 {
+		t.Errorf("expected nothing to be saved, got %+v", conf.Organizations)
+	}
+}
+
+func TestCloneRepo_SaveOrgExisting(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	before, err := configfile.LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--save-org", privateKey, "git@github.com:haukened/ghc.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(mock.cmds) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(mock.cmds))
+	}
+
+	// a configured org keeps its key
+	conf, err := configfile.LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	org, err := conf.GetOrganization("haukened")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if want, _ := before.GetOrganization("haukened"); org.SSHKeyPath != want.SSHKeyPath {
+		t.Errorf("expected key %s to be kept, got %s", want.SSHKeyPath, org.SSHKeyPath)
+	}
+	if strings.Contains(stderr.String(), "Saved org") {
+		t.Errorf("expected nothing to be saved, got %q", stderr.String())
+	}
+}
+
+func TestCloneRepo_SaveOrgErrors(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr error
+	}{
+		{
+			name:      "invalid org name",
+			args:      []string{"clone", "--save-org", privateKey, "git@github.com:Bad--Org/api.git"},
+			expectErr: domain.ErrInvalidOrgName,
+		},
+		{
+			name:      "missing key",
+			args:      []string{"clone", "--save-org", filepath.Join(t.TempDir(), "missing"), "git@github.com:new-team/api.git"},
+			expectErr: os.ErrNotExist,
+		},
+		{
+			name:      "conflicts with key",
+			args:      []string{"clone", "--save-org", privateKey, "--key", privateKey, "git@github.com:new-team/api.git"},
+			expectErr: ErrConflictingFlags,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+
+			var stdout, stderr bytes.Buffer
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if len(mock.cmds) != 0 {
+				t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+			}
+
+			// nothing may be saved when validation fails
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			if len(conf.Organizations) != 1 {
+				t.Errorf("expected config to be unchanged, got %d orgs", len(conf.Organizations))
+			}
+		})
+	}
+}
+
+func TestCloneRepo_Proxy(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		env         string
+		configProxy string
+		expected    string
+		expectErr   error
+	}{
+		{
+			name:     "flag",
+			args:     []string{"--proxy", "http://proxy.example.com:3128"},
+			expected: "\tProxyCommand nc -X connect -x proxy.example.com:3128 %h %p\n",
+		},
+		{
+			name:     "environment",
+			env:      "socks5://localhost:1080",
+			expected: "\tProxyCommand nc -X 5 -x localhost:1080 %h %p\n",
+		},
+		{
+			name:        "config default",
+			configProxy: "socks5://config-proxy:1080",
+			expected:    "\tProxyCommand nc -X 5 -x config-proxy:1080 %h %p\n",
+		},
+		{
+			name:        "flag overrides config",
+			args:        []string{"--proxy", "socks5://flag-proxy:1080"},
+			configProxy: "socks5://config-proxy:1080",
+			expected:    "\tProxyCommand nc -X 5 -x flag-proxy:1080 %h %p\n",
+		},
+		{
+			name:      "invalid proxy",
+			args:      []string{"--proxy", "socks5://bad host:1080"},
+			expectErr: sshconfig.ErrInvalidProxy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfigDir, mock := setupCloneTest(t)
+			t.Setenv("GHC_PROXY", tt.env)
+			if tt.configProxy != "" {
+				err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+					cfg.Proxy = tt.configProxy
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("failed to update config: %v", err)
+				}
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone", "--keep-config"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+				}
+				return
+			}
+
+			entries, err := os.ReadDir(sshConfigDir)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+			}
+			content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+			if err != nil {
+				t.Fatalf("failed to read ssh config: %v", err)
+			}
+			if !strings.Contains(string(content), tt.expected) {
+				t.Errorf("expected ssh config to contain %q, got:\n%s", tt.expected, content)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_VerboseSSH(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected bool
+	}{
+		{name: "default", args: []string{"clone", "--keep-config"}, expected: false},
+		{name: "verbose", args: []string{"clone", "--keep-config", "--verbose-ssh"}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfigDir, _ := setupCloneTest(t)
+
+			var stdout, stderr bytes.Buffer
+			args := append(tt.args, "git@github.com:haukened/ghc.git")
+			if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+
+			entries, err := os.ReadDir(sshConfigDir)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+			}
+			content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+			if err != nil {
+				t.Fatalf("failed to read ssh config: %v", err)
+			}
+			if got := strings.Contains(string(content), "\tLogLevel DEBUG3\n"); got != tt.expected {
+				t.Errorf("expected LogLevel DEBUG3 %v, got:\n%s", tt.expected, content)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_PostCloneHook(t *testing.T) {
+	hookErr := errors.New("exit status 2")
+
+	tests := []struct {
+		name         string
+		args         []string
+		orgHook      string
+		globalHook   string
+		hookErr      error
+		expectHook   string
+		expectErr    error
+		expectWarned bool
+	}{
+		{
+			name:       "org hook",
+			orgHook:    "make setup",
+			globalHook: "direnv allow",
+			expectHook: "make setup",
+		},
+		{
+			name:       "global hook",
+			globalHook: "direnv allow",
+			expectHook: "direnv allow",
+		},
+		{
+			name: "no hook",
+		},
+		{
+			name:    "skipped with no-hooks",
+			args:    []string{"--no-hooks"},
+			orgHook: "make setup",
+		},
+		{
+			name:       "failing hook",
+			orgHook:    "make setup",
+			hookErr:    hookErr,
+			expectHook: "make setup",
+			expectErr:  ErrHookFailed,
+		},
+		{
+			name:         "failing hook ignored",
+			args:         []string{"--ignore-hook-errors"},
+			orgHook:      "make setup",
+			hookErr:      hookErr,
+			expectHook:   "make setup",
+			expectWarned: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			mock.hookErr = tt.hookErr
+			err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+				cfg.PostClone = tt.globalHook
+				cfg.Organizations[0].PostClone = tt.orgHook
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to update config: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err = newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			// the clone always runs first, then the hook, if any
+			if tt.expectHook == "" {
+				if len(mock.cmds) != 1 {
+					t.Fatalf("expected only the clone to run, got %d commands", len(mock.cmds))
+				}
+			} else {
+				if len(mock.cmds) != 2 {
+					t.Fatalf("expected the clone and the hook to run, got %d commands", len(mock.cmds))
+				}
+				hook := mock.cmds[1]
+				if !slices.Equal(hook.Args, []string{"sh", "-c", tt.expectHook}) {
+					t.Errorf("expected hook %q, got %v", tt.expectHook, hook.Args)
+				}
+				if hook.Dir != "ghc" {
+					t.Errorf("expected the hook to run in ghc, got %q", hook.Dir)
+				}
+			}
+
+			warned := strings.Contains(stderr.String(), "Warning: post-clone hook failed")
+			if warned != tt.expectWarned {
+				t.Errorf("expected warning %v, got %q", tt.expectWarned, stderr.String())
+			}
+		})
+	}
+}
+
+func TestCloneRepo_PostCloneHookCloneFails(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	mock.err = errors.New("exit status 128")
+	err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+		cfg.PostClone = "make setup"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to update config: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "git@github.com:haukened/ghc.git"})
+	if !errors.Is(err, mock.err) {
+		t.Fatalf("expected %v, got %v", mock.err, err)
+	}
+	if len(mock.cmds) != 1 {
+		t.Errorf("expected the hook not to run after a failed clone, got %d commands", len(mock.cmds))
+	}
+}
+
+func TestCloneRepo_PrintDefaultBranch(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		output       string
+		expectOutput string
+		expectErr    error
+		expectCmds   int
+	}{
+		{
+			name:         "prints default branch",
+			args:         []string{"--print-default-branch"},
+			output:       "origin/main\n",
+			expectOutput: "main\n",
+			expectCmds:   2,
+		},
+		{
+			name:         "not requested",
+			output:       "origin/main\n",
+			expectOutput: "",
+			expectCmds:   1,
+		},
+		{
+			name:       "no default branch",
+			args:       []string{"--print-default-branch"},
+			output:     "",
+			expectErr:  ErrDefaultBranch,
+			expectCmds: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			mock.output = tt.output
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if len(mock.cmds) != tt.expectCmds {
+				t.Fatalf("expected %d commands, got %d", tt.expectCmds, len(mock.cmds))
+			}
+			if tt.expectCmds == 2 {
+				expected := []string{"git", "-C", "ghc", "symbolic-ref", "--short", "refs/remotes/origin/HEAD"}
+				if !slices.Equal(mock.cmds[1].Args, expected) {
+					t.Errorf("expected %v, got %v", expected, mock.cmds[1].Args)
+				}
+			}
+			if tt.expectErr == nil && stdout.String() != tt.expectOutput {
+				t.Errorf("expected output %q, got %q", tt.expectOutput, stdout.String())
+			}
+		})
+	}
+}
+
+func TestCloneRepo_DefaultOrgEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       string
+		expectKey string // "default" or "other"
+		expectErr error
+	}{
+		{name: "persisted default", env: "", expectKey: "default"},
+		{name: "env override", env: "other-org", expectKey: "other"},
+		{name: "env override not configured", env: "missing-org", expectErr: domain.ErrDefaultOverrideNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfigDir, mock := setupCloneTest(t)
+			otherKey, _ := utils.GenerateTestSSHKey(t)
+			err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+				return cfg.SetOrganization("other-org", otherKey, false)
+			})
+			if err != nil {
+				t.Fatalf("failed to update config: %v", err)
+			}
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			def, err := conf.GetOrganization("haukened")
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			defaultKey := def.SSHKeyPath
+			t.Setenv(configfile.DefaultOrgEnv, tt.env)
+
+			// the repository's org isn't configured, so the default is used
+			var stdout, stderr bytes.Buffer
+			args := []string{"clone", "--keep-config", "git@github.com:unconfigured/ghc.git"}
+			err = newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+				}
+				return
+			}
+
+			expectKey := defaultKey
+			if tt.expectKey == "other" {
+				expectKey = otherKey
+			}
+			entries, err := os.ReadDir(sshConfigDir)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+			}
+			content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+			if err != nil {
+				t.Fatalf("failed to read ssh config: %v", err)
+			}
+			if !strings.Contains(string(content), "IdentityFile "+expectKey+"\n") {
+				t.Errorf("expected ssh config to use %s, got:\n%s", expectKey, content)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_OrgKeyEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      bool
+		keyLabel string
+	}{
+		{name: "file value", env: false},
+		{name: "env override", env: true},
+		{name: "labeled key ignores the override", env: true, keyLabel: "write"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfigDir, _ := setupCloneTest(t)
+			ciKey, _ := utils.GenerateTestSSHKey(t)
+			writeKey, _ := utils.GenerateTestSSHKey(t)
+			var fileKey string
+			if err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+				org, err := cfg.GetOrganization("haukened")
+				if err != nil {
+					return err
+				}
+				fileKey = org.SSHKeyPath
+				org.Keys = map[string]string{"write": writeKey}
+				return nil
+			}); err != nil {
+				t.Fatalf("failed to update config: %v", err)
+			}
+			if tt.env {
+				t.Setenv(configfile.OrgKeyEnv("haukened"), ciKey)
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := []string{"clone", "--keep-config"}
+			if tt.keyLabel != "" {
+				args = append(args, "--key-label", tt.keyLabel)
+			}
+			args = append(args, "git@github.com:haukened/ghc.git")
+			if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+
+			expectKey := fileKey
+			switch {
+			case tt.keyLabel != "":
+				expectKey = writeKey
+			case tt.env:
+				expectKey = ciKey
+			}
+			entries, err := os.ReadDir(sshConfigDir)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+			}
+			content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+			if err != nil {
+				t.Fatalf("failed to read ssh config: %v", err)
+			}
+			if !strings.Contains(string(content), "IdentityFile "+expectKey+"\n") {
+				t.Errorf("expected ssh config to use %s, got:\n%s", expectKey, content)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_DestNotWritable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	_, mock := setupCloneTest(t)
+
+	// clone from a read-only directory
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0500); err != nil {
+		t.Fatalf("failed to make the directory read-only: %v", err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0700) })
+	t.Chdir(dir)
+
+	var stdout, stderr bytes.Buffer
+	err := newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "git@github.com:haukened/ghc.git"})
+	if !errors.Is(err, ErrDestNotWritable) {
+		t.Fatalf("expected %v, got %v", ErrDestNotWritable, err)
+	}
+	if !strings.Contains(err.Error(), dir) {
+		t.Errorf("expected the error to name %s, got %v", dir, err)
+	}
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+	}
+
+	// only generating the SSH config doesn't write to the directory
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "--config-only", "git@github.com:haukened/ghc.git"}); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestDirWritable(t *testing.T) {
+	dir := t.TempDir()
+	if !dirWritable(dir) {
+		t.Errorf("expected %s to be writable", dir)
+	}
+	// the check leaves nothing behind
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("expected an empty directory, got %d entries (%v)", len(entries), err)
+	}
+	if dirWritable(filepath.Join(dir, "missing")) {
+		t.Errorf("expected a missing directory not to be writable")
+	}
+}
+
+func TestCloneRepo_InlineKnownHostsRemovedOnConfigError(t *testing.T) {
+	sshConfigDir, mock := setupCloneTest(t)
+	if err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+		org, err := cfg.GetOrganization("haukened")
+		if err != nil {
+			return err
+		}
+		org.KnownHosts = "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to update config: %v", err)
+	}
+
+	// a template that renders its sample, but fails with the known_hosts options
+	template := filepath.Join(t.TempDir(), "fails.tmpl")
+	text := "Host {{.Host}}\n{{if eq (len .Options) 2}}{{index .Options 5}}{{end}}"
+	if err := os.WriteFile(template, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--ssh-config-template", template, "git@github.com:haukened/ghc.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	if entries, _ := os.ReadDir(sshConfigDir); len(entries) != 0 {
+		t.Errorf("expected the known_hosts file to be removed, got %d entries", len(entries))
+	}
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+	}
+}
+
+func TestCloneRepo_SSHConfigTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		flag      string // template given with --ssh-config-template
+		config    string // template set in the configuration
+		expected  string
+		expectErr error
+	}{
+		{name: "flag", flag: "Host {{.Host}}\n\tPort 443\n", expected: "Host github.com\n\tPort 443\n"},
+		{name: "config", config: "Host {{.Host}}\n\tPort 22\n", expected: "Host github.com\n\tPort 22\n"},
+		{name: "flag overrides config", flag: "Host {{.Host}}\n\tPort 443\n", config: "Host {{.Host}}\n\tPort 22\n", expected: "Host github.com\n\tPort 443\n"},
+		{name: "bad template", flag: "Host {{.Nope}}\n", expectErr: sshconfig.ErrInvalidTemplate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfigDir, mock := setupCloneTest(t)
+			writeTemplate := func(name, text string) string {
+				path := filepath.Join(t.TempDir(), name)
+				if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			}
+
+			args := []string{"clone", "--keep-config"}
+			if tt.flag != "" {
+				args = append(args, "--ssh-config-template", writeTemplate("flag.tmpl", tt.flag))
+			}
+			if tt.config != "" {
+				path := writeTemplate("config.tmpl", tt.config)
+				if err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+					cfg.SSHConfigTemplate = path
+					return nil
+				}); err != nil {
+					t.Fatalf("failed to update config: %v", err)
+				}
+			}
+			args = append(args, "git@github.com:haukened/ghc.git")
+
+			var stdout, stderr bytes.Buffer
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+				}
+				return
+			}
+
+			entries, err := os.ReadDir(sshConfigDir)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+			}
+			content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+			if err != nil {
+				t.Fatalf("failed to read ssh config: %v", err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, content)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_PrintOrg(t *testing.T) {
+	tests := []struct {
+		name      string
+		urls      []string
+		expected  string
+		expectErr error
+	}{
+		{name: "scp-like URL", urls: []string{"git@github.com:haukened/ghc.git"}, expected: "haukened\n"},
+		{name: "unconfigured org", urls: []string{"git@github.com:unconfigured/ghc.git"}, expected: "unconfigured\n"},
+		{name: "several URLs", urls: []string{"git@github.com:haukened/ghc.git", "git@github.com:other-org/api.git"}, expected: "haukened\nother-org\n"},
+		{name: "HTTPS URL", urls: []string{"https://github.com/haukened/ghc.git"}, expectErr: ErrInvalidRepoURLFormat},
+		{name: "not a URL", urls: []string{"ghc"}, expectErr: ErrInvalidRepoURLFormat},
+		{name: "one invalid URL fails all", urls: []string{"git@github.com:haukened/ghc.git", "ghc"}, expectErr: ErrInvalidRepoURLFormat},
+		{name: "parent directory", urls: []string{"git@github.com:haukened/.."}, expectErr: ErrInvalidRepoURLFormat},
+		{name: "parent directory with .git", urls: []string{"git@github.com:haukened/...git"}, expectErr: ErrInvalidRepoURLFormat},
+		{name: "working directory", urls: []string{"git@github.com:haukened/."}, expectErr: ErrInvalidRepoURLFormat},
+		{name: "only .git", urls: []string{"git@github.com:haukened/.git"}, expectErr: ErrInvalidRepoURLFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone", "--print-org"}, tt.urls...)
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr == nil && stdout.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, stdout.String())
+			}
+			if tt.expectErr != nil && stdout.Len() != 0 {
+				t.Errorf("expected no output, got %q", stdout.String())
+			}
+			if len(mock.cmds) != 0 {
+				t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+			}
+		})
+	}
+}
+
+func TestCloneRepo_IdentityAgent(t *testing.T) {
+	sshConfigDir, _ := setupCloneTest(t)
+	err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+		org, err := cfg.GetOrganization("haukened")
+		if err != nil {
+			return err
+		}
+		org.IdentityAgent = "~/.1password/agent.sock"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to update config: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--keep-config", "git@github.com:haukened/ghc.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	entries, err := os.ReadDir(sshConfigDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+	}
+	content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("failed to read ssh config: %v", err)
+	}
+	if !strings.Contains(string(content), "\tIdentityAgent ~/.1password/agent.sock\n") {
+		t.Errorf("expected ssh config to set IdentityAgent, got:\n%s", content)
+	}
+}
+
+func TestCloneRepo_SSHTimeouts(t *testing.T) {
+	tests := []struct {
+		name                string
+		connectTimeout      int
+		serverAliveInterval int
+		expected            []string
+		unexpected          []string
+	}{
+		{name: "both", connectTimeout: 10, serverAliveInterval: 15, expected: []string{"\tConnectTimeout 10\n", "\tServerAliveInterval 15\n"}},
+		{name: "keepalive only", serverAliveInterval: 30, expected: []string{"\tServerAliveInterval 30\n"}, unexpected: []string{"ConnectTimeout"}},
+		{name: "unset", unexpected: []string{"ConnectTimeout", "ServerAliveInterval"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfigDir, _ := setupCloneTest(t)
+			err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+				org, err := cfg.GetOrganization("haukened")
+				if err != nil {
+					return err
+				}
+				org.ConnectTimeout = tt.connectTimeout
+				org.ServerAliveInterval = tt.serverAliveInterval
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to update config: %v", err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := []string{"clone", "--keep-config", "git@github.com:haukened/ghc.git"}
+			if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+
+			entries, err := os.ReadDir(sshConfigDir)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+			}
+			content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+			if err != nil {
+				t.Fatalf("failed to read ssh config: %v", err)
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(string(content), want) {
+					t.Errorf("expected ssh config to include %q, got:\n%s", want, content)
+				}
+			}
+			for _, unwanted := range tt.unexpected {
+				if strings.Contains(string(content), unwanted) {
+					t.Errorf("expected ssh config to omit %s, got:\n%s", unwanted, content)
+				}
+			}
+		})
+	}
+}
+
+func TestCloneRepo_Sparse(t *testing.T) {
+	_, mock := setupCloneTest(t)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--sparse", "--sparse-path", "cmd", "--sparse-path", "internal/clone", "git@github.com:haukened/ghc.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	// clone without a checkout, limit the paths, then check out
+	if len(mock.cmds) != 3 {
+		t.Fatalf("expected 3 commands, got %d", len(mock.cmds))
+	}
+	if !slices.Contains(mock.cmds[0].Args, "clone") || !slices.Contains(mock.cmds[0].Args, "--no-checkout") {
+		t.Errorf("expected a clone without checkout, got %v", mock.cmds[0].Args)
+	}
+	expected := [][]string{
+		{"git", "-C", "ghc", "sparse-checkout", "set", "--", "cmd", "internal/clone"},
+		{"git", "-C", "ghc", "checkout"},
+	}
+	for i, want := range expected {
+		if got := mock.cmds[i+1].Args; !slices.Equal(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+// configCheckingRunner records, for each command after the clone, the SSH
+// config file named in its GIT_SSH_COMMAND, and whether it exists then.
+type configCheckingRunner struct {
+	configs []string
+	exists  []bool
+}
+
+func (r *configCheckingRunner) Run(cmd *exec.Cmd) error {
+	if slices.Contains(cmd.Args, "clone") {
+		return nil
+	}
+	for _, kv := range cmd.Env {
+		if path, ok := strings.CutPrefix(kv, "GIT_SSH_COMMAND=ssh -F "); ok {
+			r.configs = append(r.configs, path)
+			r.exists = append(r.exists, fileExists(path))
+		}
+	}
+	return nil
+}
+
+func TestCloneRepo_FilterChecksOutBeforeCleanup(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{name: "sparse", args: []string{"--sparse", "--sparse-path", "cmd"}, expected: 2},
+		{name: "checkout", args: []string{"--checkout", "v1.0.0"}, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCloneTest(t)
+			checking := &configCheckingRunner{}
+			runner = checking
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone", "--filter", "blob:none"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+
+			// a partial clone fetches the missing objects on checkout, so
+			// the SSH config is still there, and removed afterwards
+			if len(checking.configs) != tt.expected {
+				t.Fatalf("expected %d commands with the SSH command, got %d", tt.expected, len(checking.configs))
+			}
+			for i, path := range checking.configs {
+				if !checking.exists[i] {
+					t.Errorf("expected %s to exist during the checkout", path)
+				}
+				if fileExists(path) {
+					t.Errorf("expected %s to be removed after the clone", path)
+				}
+			}
+		})
+	}
+}
+
+func TestCloneRepo_SparseErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		gitErr    error
+		expectErr error
+		expectCmd int
+	}{
+		{
+			name:      "sparse without paths",
+			args:      []string{"--sparse"},
+			expectErr: ErrSparsePathRequired,
+		},
+		{
+			name:      "paths without sparse",
+			args:      []string{"--sparse-path", "cmd"},
+			expectErr: ErrConflictingFlags,
+		},
+		{
+			name:      "failed clone skips the sparse checkout",
+			args:      []string{"--sparse", "--sparse-path", "cmd"},
+			gitErr:    errors.New("exit status 128"),
+			expectCmd: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			mock.err = tt.gitErr
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if tt.expectErr != nil && !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.gitErr != nil && !errors.Is(err, tt.gitErr) {
+				t.Fatalf("expected %v, got %v", tt.gitErr, err)
+			}
+			if len(mock.cmds) != tt.expectCmd {
+				t.Errorf("expected %d commands, got %d", tt.expectCmd, len(mock.cmds))
+			}
+		})
+	}
+}
+
+func TestCloneRepo_OnExists(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		input       string
+		notTerminal bool
+		expectErr   error
+		expectArgs  []string // nil means nothing is run
+		expectDir   bool     // whether the existing directory is still there
+	}{
+		{
+			name:      "error by default",
+			expectErr: ErrDestinationExists,
+			expectDir: true,
+		},
+		{
+			name:      "skip",
+			mode:      "skip",
+			expectDir: true,
+		},
+		{
+			name:       "pull",
+			mode:       "pull",
+			expectArgs: []string{"git", "-C", "ghc", "-c"},
+			expectDir:  true,
+		},
+		{
+			name:       "overwrite confirmed",
+			mode:       "overwrite",
+			input:      "y\n",
+			expectArgs: []string{"git", "clone"},
+		},
+		{
+			name:      "overwrite declined",
+			mode:      "overwrite",
+			input:     "n\n",
+			expectErr: ErrOverwriteDeclined,
+			expectDir: true,
+		},
+		{
+			name:        "overwrite without a terminal",
+			mode:        "overwrite",
+			input:       "y\n",
+			notTerminal: true,
+			expectErr:   ErrOverwriteDeclined,
+			expectDir:   true,
+		},
+		{
+			name:      "invalid mode",
+			mode:      "merge",
+			expectErr: ErrInvalidOnExists,
+			expectDir: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			if tt.notTerminal {
+				utils.IsTerminal = func(any) bool { return false }
+			}
+			t.Chdir(t.TempDir())
+			existing := filepath.Join("ghc", "README.md")
+			if err := os.MkdirAll("ghc", 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(existing, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			cmd := newCloneCommand(&stdout, &stderr)
+			cmd.Reader = strings.NewReader(tt.input)
+			args := []string{"clone"}
+			if tt.mode != "" {
+				args = append(args, "--on-exists", tt.mode)
+			}
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := cmd.Run(t.Context(), args)
+			if tt.expectErr != nil {
+				if !errors.Is(err, tt.expectErr) {
+					t.Fatalf("expected %v, got %v", tt.expectErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+
+			if tt.expectArgs == nil {
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected no commands, got %v", mock.cmds[0].Args)
+				}
+			} else {
+				if len(mock.cmds) != 1 {
+					t.Fatalf("expected 1 command, got %d", len(mock.cmds))
+				}
+				got := mock.cmds[0].Args
+				if !slices.Equal(got[:len(tt.expectArgs)], tt.expectArgs) {
+					t.Errorf("expected %v to start with %v", got, tt.expectArgs)
+				}
+			}
+
+			_, statErr := os.Stat(existing)
+			if exists := statErr == nil; exists != tt.expectDir {
+				t.Errorf("expected existing directory kept to be %v", tt.expectDir)
+			}
+		})
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written from several goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCloneOne_OverwriteOutsideWorkingDir(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	key, _ := utils.GenerateTestSSHKey(t)
+	parent := t.TempDir()
+	t.Chdir(parent)
+	if err := os.Mkdir("child", 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir("child")
+
+	// a URL that parsing would reject, to check the overwrite itself
+	job := cloneJob{repoURL: "git@github.com:haukened/..", host: "github.com", orgName: "haukened"}
+	opts := cloneOptions{
+		keyPath:  key,
+		onExists: onExistsOverwrite,
+		ask: func(string) bool {
+			t.Error("expected no question")
+			return true
+		},
+	}
+	var stdout, stderr bytes.Buffer
+	err := cloneOne(nil, job, opts, &stdout, &stderr)
+	if !errors.Is(err, ErrUnsafeOverwrite) {
+		t.Fatalf("expected %v, got %v", ErrUnsafeOverwrite, err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "child")); err != nil {
+		t.Errorf("expected the parent to be kept, got %v", err)
+	}
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+	}
+}
+
+func TestCloneRepo_OverwriteParallel(t *testing.T) {
+	setupCloneTest(t)
+	t.Chdir(t.TempDir())
+	repos := []string{"one", "two", "three"}
+	args := []string{"clone", "--parallel", "3", "--on-exists", "overwrite"}
+	for _, repo := range repos {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, "git@github.com:haukened/"+repo+".git")
+	}
+
+	var stdout bytes.Buffer
+	var stderr syncBuffer
+	cmd := newCloneCommand(&stdout, nil)
+	cmd.ErrWriter = &stderr
+	cmd.Reader = strings.NewReader("y\nn\ny\n")
+	err := cmd.Run(t.Context(), args)
+	if !errors.Is(err, ErrOverwriteDeclined) {
+		t.Fatalf("expected %v, got %v", ErrOverwriteDeclined, err)
+	}
+
+	// the questions are asked one at a time, each whole, and the nth answer
+	// goes to the nth question asked
+	asked := regexp.MustCompile(`Remove the existing (\w+) and clone again\? \[y/N\]: `).FindAllStringSubmatch(stderr.String(), -1)
+	if len(asked) != len(repos) {
+		t.Fatalf("expected %d questions, got %d in %q", len(repos), len(asked), stderr.String())
+	}
+	for i, question := range asked {
+		_, statErr := os.Stat(filepath.Join(question[1], "README.md"))
+		if kept, declined := statErr == nil, i == 1; kept != declined {
+			t.Errorf("expected %s kept to be %v, answered %q", question[1], declined, []string{"y", "n", "y"}[i])
+		}
+	}
+}
+
+func TestCloneRepo_OrgHost(t *testing.T) {
+	_, mock := setupCloneTest(t)
+
+	// add an organization on a GitHub Enterprise server
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+		if err := cfg.SetOrganization("corp", privateKey, false); err != nil {
+			return err
+		}
+		return cfg.SetHost("corp", "github.corp.example.com")
+	})
+	if err != nil {
+		t.Fatalf("failed to update config: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		expectErr  error
+		expectKey  string
+		expectHost string
+	}{
+		{name: "custom host", url: "git@github.corp.example.com:corp/api.git", expectKey: privateKey, expectHost: "github.corp.example.com"},
+		{name: "github.com for an org without a host", url: "git@github.com:haukened/ghc.git", expectHost: "github.com"},
+		{name: "github.com for an org with a host", url: "git@github.com:corp/api.git", expectErr: ErrInvalidRepoURLFormat},
+		{name: "custom host for an org without one", url: "git@github.corp.example.com:haukened/ghc.git", expectErr: ErrInvalidRepoURLFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.cmds = nil
+
+			var stdout, stderr bytes.Buffer
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "--keep-config", tt.url})
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected no commands, got %v", mock.cmds[0].Args)
+				}
+				return
+			}
+
+			if len(mock.cmds) != 1 || mock.cmds[0].Args[len(mock.cmds[0].Args)-1] != tt.url {
+				t.Fatalf("expected a clone of %s, got %v", tt.url, mock.cmds)
+			}
+
+			// the generated SSH config must be for the org's host and key
+			_, configPath, _ := strings.Cut(stderr.String(), "SSH config file retained at: ")
+			configPath = strings.TrimSpace(configPath)
+			content, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatalf("failed to read ssh config: %v", err)
+			}
+			if !strings.HasPrefix(string(content), "Host "+tt.expectHost+"\n") {
+				t.Errorf("expected an ssh config for %s, got:\n%s", tt.expectHost, content)
+			}
+			if tt.expectKey != "" && !strings.Contains(string(content), "IdentityFile "+tt.expectKey+"\n") {
+				t.Errorf("expected ssh config to use %s, got:\n%s", tt.expectKey, content)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_KeyLabel(t *testing.T) {
+	_, mock := setupCloneTest(t)
+
+	// give the organization separate read and write keys, with read as the primary
+	readKey, _ := utils.GenerateTestSSHKey(t)
+	writeKey, _ := utils.GenerateTestSSHKey(t)
+	err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+		org, err := cfg.GetOrganization("haukened")
+		if err != nil {
+			return err
+		}
+		org.Keys = map[string]string{"read": readKey, "write": writeKey}
+		org.PrimaryKey = "read"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to update config: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr error
+		expectKey string
+	}{
+		{name: "primary key by default", expectKey: readKey},
+		{name: "selected key", args: []string{"--key-label", "write"}, expectKey: writeKey},
+		{name: "unknown label", args: []string{"--key-label", "admin"}, expectErr: domain.ErrKeyLabelNotFound},
+		{name: "with --key", args: []string{"--key", readKey, "--key-label", "write"}, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.cmds = nil
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone", "--keep-config"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				return
+			}
+
+			_, configPath, _ := strings.Cut(stderr.String(), "SSH config file retained at: ")
+			content, err := os.ReadFile(strings.TrimSpace(configPath))
+			if err != nil {
+				t.Fatalf("failed to read ssh config: %v", err)
+			}
+			if !strings.Contains(string(content), "IdentityFile "+tt.expectKey+"\n") {
+				t.Errorf("expected ssh config to use %s, got:\n%s", tt.expectKey, content)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_HostAlias(t *testing.T) {
+	sshConfigDir, mock := setupCloneTest(t)
+
+	// add an organization that clones through the user's own SSH config
+	err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+		return cfg.SetHostAlias("work", "github-work", false)
+	})
+	if err != nil {
+		t.Fatalf("failed to update config: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		existing   bool
+		expectErr  error
+		expectArgs []string
+	}{
+		{name: "clone through the alias", expectArgs: []string{"git", "clone", "git@github-work:work/api.git"}},
+		{name: "sparse", args: []string{"--sparse", "--sparse-path", "cmd"}, expectArgs: []string{"git", "clone", "--no-checkout", "git@github-work:work/api.git"}},
+		{name: "pull through the alias", args: []string{"--on-exists", "pull"}, existing: true, expectArgs: []string{"git", "-C", "api", "pull"}},
+		{name: "config only", args: []string{"--config-only"}, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.cmds = nil
+			t.Chdir(t.TempDir())
+			if tt.existing {
+				if err := os.Mkdir("api", 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:work/api.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				return
+			}
+
+			if len(mock.cmds) == 0 {
+				t.Fatalf("expected commands to be run")
+			}
+			if got := mock.cmds[0].Args; !slices.Equal(got, tt.expectArgs) {
+				t.Errorf("expected %v, got %v", tt.expectArgs, got)
+			}
+			if slices.ContainsFunc(mock.cmds[0].Env, func(env string) bool { return strings.HasPrefix(env, "GIT_SSH_COMMAND=") }) {
+				t.Errorf("expected no SSH command override, got %v", mock.cmds[0].Env)
+			}
+
+			// no SSH config is generated for an alias
+			if entries, _ := os.ReadDir(sshConfigDir); len(entries) != 0 {
+				t.Errorf("expected no ssh config files, got %d", len(entries))
+			}
+		})
+	}
+}
+
+func TestCloneRepo_Filter(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		expectErr  error
+		expectArgs []string // git clone arguments between the SSH config and the URL
+	}{
+		{name: "blob:none", args: []string{"--filter", "blob:none"}, expectArgs: []string{"--filter=blob:none"}},
+		{name: "blob:limit", args: []string{"--filter", "blob:limit=1m"}, expectArgs: []string{"--filter=blob:limit=1m"}},
+		{name: "tree:0", args: []string{"--filter", "tree:0"}, expectArgs: []string{"--filter=tree:0"}},
+		{name: "with sparse", args: []string{"--filter", "blob:none", "--sparse", "--sparse-path", "cmd"}, expectArgs: []string{"--no-checkout", "--filter=blob:none"}},
+		{name: "unknown spec", args: []string{"--filter", "blob:some"}, expectErr: ErrInvalidFilter},
+		{name: "limit without a size", args: []string{"--filter", "blob:limit="}, expectErr: ErrInvalidFilter},
+		{name: "sparse filter", args: []string{"--filter", "sparse:oid=HEAD"}, expectErr: ErrInvalidFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				// git must not be run for a rejected spec
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected no commands, got %v", mock.cmds[0].Args)
+				}
+				return
+			}
+
+			// git clone --config core.sshCommand=... ARGS... URL
+			got := mock.cmds[0].Args
+			if got := got[4 : len(got)-1]; !slices.Equal(got, tt.expectArgs) {
+				t.Errorf("expected clone arguments %v, got %v", tt.expectArgs, got)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_Submodules(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		existing   bool // whether the repository was already cloned, so it is pulled
+		expectErr  error
+		expectArgs []string // git arguments after the clone or pull subcommand, before any URL
+	}{
+		{name: "jobs", args: []string{"--jobs", "8"}, expectArgs: []string{"--jobs", "8"}},
+		{name: "recurse with jobs", args: []string{"--recurse-submodules", "--jobs", "4"}, expectArgs: []string{"--recurse-submodules", "--jobs", "4"}},
+		{name: "recurse with a filter", args: []string{"--filter", "blob:none", "--recurse-submodules"}, expectArgs: []string{"--filter=blob:none", "--recurse-submodules"}},
+		{name: "pull recurses with jobs", args: []string{"--on-exists", "pull", "--recurse-submodules", "--jobs", "4"}, existing: true, expectArgs: []string{"--recurse-submodules", "--jobs", "4"}},
+		{name: "zero jobs", args: []string{"--jobs", "0"}, expectErr: ErrInvalidJobs},
+		{name: "negative jobs", args: []string{"--jobs", "-2"}, expectErr: ErrInvalidJobs},
+		{name: "with sparse", args: []string{"--recurse-submodules", "--sparse", "--sparse-path", "cmd"}, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			t.Chdir(t.TempDir())
+			if tt.existing {
+				if err := os.Mkdir("ghc", 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected no commands, got %v", mock.cmds[0].Args)
+				}
+				return
+			}
+
+			// git clone --config core.sshCommand=... ARGS... URL, or
+			// git -C DIR -c core.sshCommand=... pull ARGS...
+			got := mock.cmds[0].Args
+			if tt.existing {
+				got = got[6:]
+			} else {
+				got = got[4 : len(got)-1]
+			}
+			if !slices.Equal(got, tt.expectArgs) {
+				t.Errorf("expected git arguments %v, got %v", tt.expectArgs, mock.cmds[0].Args)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_SSHConfigDirPermissions(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr error
+	}{
+		{name: "refused by default", expectErr: ErrSSHConfigDirPermissions},
+		{name: "fixed with --fix-permissions", args: []string{"--fix-permissions"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfigDir, mock := setupCloneTest(t)
+
+			// a directory left behind with looser permissions
+			if err := os.MkdirAll(sshConfigDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(sshConfigDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			info, statErr := os.Stat(sshConfigDir)
+			if statErr != nil {
+				t.Fatal(statErr)
+			}
+			if tt.expectErr != nil {
+				// nothing may be written into the directory, or run
+				if entries, _ := os.ReadDir(sshConfigDir); len(entries) != 0 {
+					t.Errorf("expected no ssh config files, got %d", len(entries))
+				}
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected no commands, got %v", mock.cmds[0].Args)
+				}
+				if mode := info.Mode().Perm(); mode != 0755 {
+					t.Errorf("expected mode 0755 to be left alone, got %04o", mode)
+				}
+				return
+			}
+
+			if mode := info.Mode().Perm(); mode != 0700 {
+				t.Errorf("expected mode 0700, got %04o", mode)
+			}
+			if !strings.Contains(stderr.String(), "restricted it to 0700") {
+				t.Errorf("expected a warning, got %q", stderr.String())
+			}
+			if len(mock.cmds) != 1 {
+				t.Errorf("expected 1 command, got %d", len(mock.cmds))
+			}
+		})
+	}
+}
+
+func TestCloneRepo_SSHConfigDirOwner(t *testing.T) {
+	sshConfigDir, mock := setupCloneTest(t)
+	if err := os.MkdirAll(sshConfigDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(sshConfigDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, ok := fileOwner(info)
+	if !ok {
+		t.Skip("files have no owner on this platform")
+	}
+
+	// pretend to be someone else, so the directory belongs to another user
+	oldCurrentUID := currentUID
+	currentUID = func() int { return uid + 1 }
+	t.Cleanup(func() { currentUID = oldCurrentUID })
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--fix-permissions", "git@github.com:haukened/ghc.git"}
+	err = newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+	if !errors.Is(err, ErrSSHConfigDirOwner) {
+		t.Fatalf("expected %v, got %v", ErrSSHConfigDirOwner, err)
+	}
+	if entries, _ := os.ReadDir(sshConfigDir); len(entries) != 0 {
+		t.Errorf("expected no ssh config files, got %d", len(entries))
+	}
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected no commands, got %v", mock.cmds[0].Args)
+	}
+}
+
+func TestSSHConfigDir(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" || runtime.GOOS == "plan9" {
+		t.Skip("the home and cache directories don't come from HOME and XDG_CACHE_HOME")
+	}
+	cache := t.TempDir()
+	tests := []struct {
+		name     string
+		home     string
+		cache    string
+		expected string
+	}{
+		{name: "home", home: "/home/me", expected: "/home/me/.config/ghc/ssh_configs"},
+		{name: "cache without a home", cache: cache, expected: filepath.Join(cache, "ghc", "ssh_configs")},
+		{name: "neither", expected: filepath.Join(os.TempDir(), fmt.Sprintf("ghc-%d", os.Geteuid()), "ssh_configs")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", tt.home)
+			t.Setenv("XDG_CACHE_HOME", tt.cache)
+			if got := filepath.Clean(sshConfigDir()); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_DestExistsOK(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		existing  bool
+		expectErr error
+		expectCmd int
+	}{
+		{name: "existing destination is a no-op", args: []string{"--dest-exists-ok"}, existing: true, expectCmd: 0},
+		{name: "missing destination is cloned", args: []string{"--dest-exists-ok"}, expectCmd: 1},
+		{name: "with --on-exists=pull", args: []string{"--dest-exists-ok", "--on-exists", "pull"}, existing: true, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			t.Chdir(t.TempDir())
+			if tt.existing {
+				if err := os.Mkdir("ghc", 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if len(mock.cmds) != tt.expectCmd {
+				t.Errorf("expected %d commands, got %d", tt.expectCmd, len(mock.cmds))
+			}
+			if tt.existing && tt.expectErr == nil && !strings.Contains(stderr.String(), "already cloned") {
+				t.Errorf("expected an already cloned message, got %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestCloneRepo_ConfigStdin(t *testing.T) {
+	sshConfigDir, mock := setupCloneTest(t)
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	// point the config at a path that doesn't exist, so any config access fails
+	missingConfig := filepath.Join(t.TempDir(), "missing", "config.json")
+	configfile.SetDefaultConfigPath(missingConfig)
+
+	conf := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "piped", SSHKeyPath: privateKey, IsDefault: true},
+		},
+	}
+	confBytes, err := conf.JSON()
+	if err != nil {
+		t.Fatalf("failed to marshal test config: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := newCloneCommand(&stdout, &stderr)
+	cmd.Reader = bytes.NewReader(confBytes)
+	args := []string{"clone", "--config-stdin", "--keep-config", "git@github.com:piped/ghc.git"}
+	if err := cmd.Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(mock.cmds) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(mock.cmds))
+	}
+
+	// the generated SSH config must use the piped org's key
+	entries, err := os.ReadDir(sshConfigDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 ssh config file, got %d (%v)", len(entries), err)
+	}
+	content, err := os.ReadFile(filepath.Join(sshConfigDir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("failed to read ssh config: %v", err)
+	}
+	if !strings.Contains(string(content), "IdentityFile "+privateKey) {
+		t.Errorf("expected ssh config to use %s, got:\n%s", privateKey, content)
+	}
+
+	// the config file is never written
+	if _, err := os.Stat(missingConfig); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no config file at %s, got %v", missingConfig, err)
+	}
+}
+
+func TestCloneRepo_ConfigStdinErrors(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		stdin     string
+		args      []string
+		expectErr error
+	}{
+		{
+			name:      "with --key",
+			args:      []string{"--key", privateKey},
+			expectErr: ErrConflictingFlags,
+		},
+		{
+			name:      "with --save-org",
+			args:      []string{"--save-org", privateKey},
+			expectErr: ErrConflictingFlags,
+		},
+		{
+			name:      "org not in piped config",
+			stdin:     `{"organizations": []}`,
+			expectErr: domain.ErrNoDefaultOrg,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+
+			var stdout, stderr bytes.Buffer
+			cmd := newCloneCommand(&stdout, &stderr)
+			cmd.Reader = strings.NewReader(tt.stdin)
+			args := append([]string{"clone", "--config-stdin"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			if err := cmd.Run(t.Context(), args); !errors.Is(err, tt.expectErr) {
+				t.Errorf("expected %v, got %v", tt.expectErr, err)
+			}
+			if len(mock.cmds) != 0 {
+				t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+			}
+		})
+	}
+
+	// a config that isn't JSON is rejected
+	_, mock := setupCloneTest(t)
+	var stdout, stderr bytes.Buffer
+	cmd := newCloneCommand(&stdout, &stderr)
+	cmd.Reader = strings.NewReader("not json")
+	if err := cmd.Run(t.Context(), []string{"clone", "--config-stdin", "git@github.com:haukened/ghc.git"}); err == nil {
+		t.Error("expected an error for invalid JSON, got nil")
+	}
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+	}
+}
+
+func TestCloneRepo_PushURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		pushURL string
+	}{
+		{name: "scp-like", pushURL: "git@mirror.example.com:haukened/ghc.git"},
+		{name: "ssh URL", pushURL: "ssh://git@mirror.example.com:2222/haukened/ghc.git"},
+		{name: "https URL", pushURL: "https://mirror.example.com/haukened/ghc.git"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+
+			var stdout, stderr bytes.Buffer
+			args := []string{"clone", "--push-url", tt.pushURL, "git@github.com:haukened/ghc.git"}
+			if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+
+			// clone from upstream, then point pushes at the mirror
+			if len(mock.cmds) != 2 {
+				t.Fatalf("expected 2 commands, got %d", len(mock.cmds))
+			}
+			if !slices.Contains(mock.cmds[0].Args, "clone") || !slices.Contains(mock.cmds[0].Args, "git@github.com:haukened/ghc.git") {
+				t.Errorf("expected a clone from upstream, got %v", mock.cmds[0].Args)
+			}
+			expected := []string{"git", "-C", "ghc", "remote", "set-url", "--push", "origin", tt.pushURL}
+			if got := mock.cmds[1].Args; !slices.Equal(got, expected) {
+				t.Errorf("expected %v, got %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_PushURLErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		gitErr    error
+		expectErr error
+		expectRun int
+	}{
+		{name: "not a URL", args: []string{"--push-url", "mirror"}, expectErr: ErrInvalidPushURL},
+		{name: "unsupported scheme", args: []string{"--push-url", "ftp://mirror.example.com/ghc.git"}, expectErr: ErrInvalidPushURL},
+		{name: "no path", args: []string{"--push-url", "https://mirror.example.com"}, expectErr: ErrInvalidPushURL},
+		{name: "with config-only", args: []string{"--push-url", "git@mirror.example.com:haukened/ghc.git", "--config-only"}, expectErr: ErrConflictingFlags},
+		{name: "several repositories", args: []string{"--push-url", "git@mirror.example.com:haukened/ghc.git", "git@github.com:haukened/other.git"}, expectErr: ErrConflictingFlags},
+		{name: "clone fails", args: []string{"--push-url", "git@mirror.example.com:haukened/ghc.git"}, gitErr: errors.New("clone failed"), expectRun: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			mock.err = tt.gitErr
+			expectErr := tt.expectErr
+			if expectErr == nil {
+				expectErr = tt.gitErr
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); !errors.Is(err, expectErr) {
+				t.Fatalf("expected %v, got %v", expectErr, err)
+			}
+			// the push URL is never set without a successful clone
+			if len(mock.cmds) != tt.expectRun {
+				t.Errorf("expected %d commands, got %d", tt.expectRun, len(mock.cmds))
+			}
+		})
+	}
+}
+
+func TestCloneRepo_Checkout(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expectErr   error
+		expectClone []string // git clone arguments before the URL
+		expectRef   string   // what is checked out after cloning, if anything
+		expectWarn  bool     // warned that a shallow clone may not include the ref
+	}{
+		{name: "tag", args: []string{"--checkout", "v1.2.0"}, expectRef: "v1.2.0"},
+		{name: "commit", args: []string{"--checkout", "0123abcd"}, expectRef: "0123abcd"},
+		{name: "shallow tag", args: []string{"--depth", "1", "--checkout", "v1.2.0"}, expectClone: []string{"--depth", "1"}, expectRef: "v1.2.0", expectWarn: true},
+		{name: "shallow commit", args: []string{"--depth", "1", "--checkout", "0123abcd"}, expectClone: []string{"--depth", "1"}, expectRef: "0123abcd", expectWarn: true},
+		{name: "depth only", args: []string{"--depth", "10"}, expectClone: []string{"--depth", "10"}},
+		{name: "zero depth", args: []string{"--depth", "0"}, expectErr: ErrInvalidDepth},
+		{name: "option as ref", args: []string{"--checkout", "--orphan=x"}, expectErr: ErrInvalidCheckout},
+		{name: "with config-only", args: []string{"--checkout", "v1.2.0", "--config-only"}, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected no commands, got %v", mock.cmds[0].Args)
+				}
+				return
+			}
+
+			// git clone --config core.sshCommand=... ARGS... URL
+			got := mock.cmds[0].Args
+			if got = got[4 : len(got)-1]; !slices.Equal(got, tt.expectClone) {
+				t.Errorf("expected clone arguments %v, got %v", tt.expectClone, mock.cmds[0].Args)
+			}
+			if tt.expectRef == "" {
+				if len(mock.cmds) != 1 {
+					t.Errorf("expected only the clone, got %d commands", len(mock.cmds))
+				}
+			} else {
+				if len(mock.cmds) != 2 {
+					t.Fatalf("expected 2 commands, got %d", len(mock.cmds))
+				}
+				expected := []string{"git", "-C", "ghc", "checkout", tt.expectRef, "--"}
+				if got := mock.cmds[1].Args; !slices.Equal(got, expected) {
+					t.Errorf("expected %v, got %v", expected, got)
+				}
+			}
+			if warned := strings.Contains(stderr.String(), "may not be found"); warned != tt.expectWarn {
+				t.Errorf("expected warning %v, got %q", tt.expectWarn, stderr.String())
+			}
+		})
+	}
+}
+
+func TestCloneRepo_ExpiredOrg(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	expiredAt := time.Now().Add(-time.Minute)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	conf := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "haukened", SSHKeyPath: privateKey, IsDefault: true, ExpiresAt: &expiredAt},
+		},
+	}
+	confBytes, err := conf.JSON()
+	if err != nil {
+		t.Fatalf("failed to marshal test config: %v", err)
+	}
+	utils.WriteConfigFileForTest(t, configPath, confBytes)
+	configfile.SetDefaultConfigPath(configPath)
+
+	var stdout, stderr bytes.Buffer
+	err = newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "git@github.com:haukened/ghc.git"})
+	if !errors.Is(err, domain.ErrOrgExpired) {
+		t.Errorf("expected %v, got %v", domain.ErrOrgExpired, err)
+	}
+	if len(mock.cmds) != 0 {
+		t.Errorf("expected git not to run, got %d commands", len(mock.cmds))
+	}
+}
+
+func TestValidRemoteName(t *testing.T) {
+	tests := map[string]bool{
+		"origin":       true,
+		"upstream":     true,
+		"my-fork_2":    true,
+		"team/fork":    true,
+		"":             false,
+		"-x":           false,
+		"has space":    false,
+		"a..b":         false,
+		"foo.lock":     false,
+		"trailing/":    false,
+		".hidden":      false,
+		"team/.hidden": false,
+		"end.":         false,
+		"a@{1}":        false,
+		"what?":        false,
+		"@":            false,
+	}
+	for name, expected := range tests {
+		if got := validRemoteName(name); got != expected {
+			t.Errorf("%q: expected %v, got %v", name, expected, got)
+		}
+	}
+}
+
+func TestCloneRepo_Origin(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expectErr   error
+		expectClone []string   // git clone arguments before the URL
+		expectAfter [][]string // commands run after cloning
+	}{
+		{name: "default", expectClone: nil},
+		{name: "upstream", args: []string{"--origin", "upstream"}, expectClone: []string{"--origin", "upstream"}},
+		{
+			name:        "push url",
+			args:        []string{"--origin", "upstream", "--push-url", "git@mirror.example.com:haukened/ghc.git"},
+			expectClone: []string{"--origin", "upstream"},
+			expectAfter: [][]string{{"git", "-C", "ghc", "remote", "set-url", "--push", "upstream", "git@mirror.example.com:haukened/ghc.git"}},
+		},
+		{
+			name:        "default branch",
+			args:        []string{"--origin", "upstream", "--print-default-branch"},
+			expectClone: []string{"--origin", "upstream"},
+			expectAfter: [][]string{{"git", "-C", "ghc", "symbolic-ref", "--short", "refs/remotes/upstream/HEAD"}},
+		},
+		{name: "option as name", args: []string{"--origin", "--bare"}, expectErr: ErrInvalidOrigin},
+		{name: "illegal name", args: []string{"--origin", "up..stream"}, expectErr: ErrInvalidOrigin},
+		{name: "empty name", args: []string{"--origin", ""}, expectErr: ErrInvalidOrigin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			mock.output = "upstream/main\n"
+
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"clone"}, tt.args...)
+			args = append(args, "git@github.com:haukened/ghc.git")
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				if len(mock.cmds) != 0 {
+					t.Errorf("expected no commands, got %v", mock.cmds[0].Args)
+				}
+				return
+			}
+
+			// git clone --config core.sshCommand=... ARGS... URL
+			got := mock.cmds[0].Args
+			if got = got[4 : len(got)-1]; !slices.Equal(got, tt.expectClone) {
+				t.Errorf("expected clone arguments %v, got %v", tt.expectClone, mock.cmds[0].Args)
+			}
+			if len(mock.cmds) != 1+len(tt.expectAfter) {
+				t.Fatalf("expected %d commands, got %d", 1+len(tt.expectAfter), len(mock.cmds))
+			}
+			for i, expected := range tt.expectAfter {
+				if got := mock.cmds[i+1].Args; !slices.Equal(got, expected) {
+					t.Errorf("expected %v, got %v", expected, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package clone
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// ErrNoOriginRemote is returned by DetectOrgFromCWD outside a clone with a remote.
+var ErrNoOriginRemote = errors.New("no origin remote found, run ghc from inside a cloned repository")
+
+// readRemotes returns the names of the remotes of the repository in the
+// current directory, one per line, as printed by `git remote`.
+// This can be overridden in tests.
+var readRemotes = func() (string, error) {
+	out, err := exec.Command("git", "remote").Output()
+	return string(out), err
+}
+
+// readRemoteURL returns the URL of the named remote of the repository in the
+// current directory, as printed by `git remote get-url`.
+// This can be overridden in tests.
+var readRemoteURL = func(remote string) (string, error) {
+	out, err := exec.Command("git", "remote", "get-url", remote).Output()
+	return string(out), err
+}
+
+// DetectOrgFromCWD returns the organization of the repository in the current
+// directory, parsed from its remote URL after applying the user's
+// url.*.insteadOf rewrites, so commands can default to this repository's org.
+// The origin remote is used if there is one, otherwise the first remote, such
+// as for a repository cloned with --origin.
+// It returns ErrNoOriginRemote if git can't read a remote, or the error from
+// parsing its URL, such as ErrInvalidRepoURLFormat.
+func DetectOrgFromCWD() (string, error) {
+	remote := defaultRemote
+	if output, err := readRemotes(); err == nil {
+		if remotes := strings.Fields(output); len(remotes) > 0 && !slices.Contains(remotes, defaultRemote) {
+			remote = remotes[0]
+		}
+	}
+
+	output, err := readRemoteURL(remote)
+	remoteURL := strings.TrimSpace(output)
+	if err != nil || remoteURL == "" {
+		return "", ErrNoOriginRemote
+	}
+
+	var rewrites []urlRewrite
+	if output, err := readURLRewrites(); err == nil {
+		rewrites = parseURLRewrites(output)
+	}
+	_, orgName, err := parseGitSSHRepoUrl(rewriteURL(remoteURL, rewrites))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s is %s", err, remote, remoteURL)
+	}
+	if orgName == "" {
+		return "", ErrOrgNameNotFound
+	}
+	return orgName, nil
+}
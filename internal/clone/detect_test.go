@@ -0,0 +1,61 @@
+package clone
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDetectOrgFromCWD(t *testing.T) {
+	tests := []struct {
+		name      string
+		remotes   string // the output of git remote, origin if empty
+		remote    string // the remote whose URL is read, origin if empty
+		origin    string
+		originErr error
+		gitConfig string
+		expected  string
+		expectErr error
+	}{
+		{name: "ssh remote", origin: "git@github.com:haukened/ghc.git\n", expected: "haukened"},
+		{name: "rewritten https remote", origin: "https://github.com/haukened/ghc.git\n", gitConfig: "url.git@github.com:.insteadof https://github.com/\n", expected: "haukened"},
+		{name: "https remote", origin: "https://github.com/haukened/ghc.git\n", expectErr: ErrInvalidRepoURLFormat},
+		{name: "not a repository", originErr: errors.New("exit status 128"), expectErr: ErrNoOriginRemote},
+		{name: "empty remote", origin: "\n", expectErr: ErrNoOriginRemote},
+		{name: "custom remote", remotes: "upstream\n", remote: "upstream", origin: "git@github.com:haukened/ghc.git\n", expected: "haukened"},
+		{name: "origin preferred", remotes: "fork\norigin\n", origin: "git@github.com:haukened/ghc.git\n", expected: "haukened"},
+	}
+
+	oldReadRemotes, oldReadRemoteURL, oldReadURLRewrites := readRemotes, readRemoteURL, readURLRewrites
+	t.Cleanup(func() {
+		readRemotes, readRemoteURL, readURLRewrites = oldReadRemotes, oldReadRemoteURL, oldReadURLRewrites
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remotes, remote := tt.remotes, tt.remote
+			if remotes == "" {
+				remotes = "origin\n"
+			}
+			if remote == "" {
+				remote = "origin"
+			}
+			readRemotes = func() (string, error) { return remotes, nil }
+			readRemoteURL = func(name string) (string, error) {
+				if name != remote {
+					return "", fmt.Errorf("no such remote '%s'", name)
+				}
+				return tt.origin, tt.originErr
+			}
+			readURLRewrites = func() (string, error) { return tt.gitConfig, nil }
+
+			org, err := DetectOrgFromCWD()
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if org != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, org)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package clone
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+var (
+	ErrUnknownGitVersion = errors.New("unable to determine git version")
+)
+
+// minSSHCommandConfigVersion is the first git release that honors core.sshCommand.
+// Older releases silently ignore it, which would clone with the wrong key.
+var minSSHCommandConfigVersion = gitVersion{major: 2, minor: 10, patch: 0}
+
+// gitVersion is a parsed git release number.
+type gitVersion struct {
+	major, minor, patch int
+}
+
+// less reports whether v is an older release than other.
+func (v gitVersion) less(other gitVersion) bool {
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	if v.minor != other.minor {
+		return v.minor < other.minor
+	}
+	return v.patch < other.patch
+}
+
+// gitVersionPattern matches the release number in `git --version` output, such as
+// "git version 2.39.3 (Apple Git-145)" or "git version 2.45.1.windows.1".
+// The patch number is optional.
+var gitVersionPattern = regexp.MustCompile(`git version (\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseGitVersion extracts the release number from `git --version` output.
+// It returns ErrUnknownGitVersion if no release number can be found.
+func parseGitVersion(output string) (gitVersion, error) {
+	matches := gitVersionPattern.FindStringSubmatch(output)
+	if matches == nil {
+		return gitVersion{}, fmt.Errorf("%w: %q", ErrUnknownGitVersion, output)
+	}
+
+	// the pattern guarantees these are numbers, so the errors can be ignored
+	var v gitVersion
+	v.major, _ = strconv.Atoi(matches[1])
+	v.minor, _ = strconv.Atoi(matches[2])
+	if matches[3] != "" {
+		v.patch, _ = strconv.Atoi(matches[3])
+	}
+	return v, nil
+}
+
+// readGitVersion returns the output of `git --version`.
+// This can be overridden in tests.
+var readGitVersion = func() (string, error) {
+	out, err := exec.Command("git", "--version").Output()
+	return string(out), err
+}
+
+// supportsSSHCommandConfig reports whether the installed git honors
+// `--config core.sshCommand=...`. If the version can't be determined,
+// it reports false so the caller uses the GIT_SSH_COMMAND environment
+// variable, which every supported git release honors.
+func supportsSSHCommandConfig() bool {
+	output, err := readGitVersion()
+	if err != nil {
+		return false
+	}
+	v, err := parseGitVersion(output)
+	if err != nil {
+		return false
+	}
+	return !v.less(minSSHCommandConfigVersion)
+}
@@ -0,0 +1,98 @@
+package clone
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseGitVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		expected  gitVersion
+		expectErr error
+	}{
+		{name: "plain", output: "git version 2.39.3\n", expected: gitVersion{2, 39, 3}},
+		{name: "apple", output: "git version 2.39.3 (Apple Git-145)\n", expected: gitVersion{2, 39, 3}},
+		{name: "windows", output: "git version 2.45.1.windows.1\n", expected: gitVersion{2, 45, 1}},
+		{name: "no patch", output: "git version 2.9\n", expected: gitVersion{2, 9, 0}},
+		{name: "garbage", output: "command not found", expectErr: ErrUnknownGitVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseGitVersion(tt.output)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if v != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, v)
+			}
+		})
+	}
+}
+
+func TestSupportsSSHCommandConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		err      error
+		expected bool
+	}{
+		{name: "modern", output: "git version 2.43.0", expected: true},
+		{name: "threshold", output: "git version 2.10.0", expected: true},
+		{name: "just below threshold", output: "git version 2.9.5", expected: false},
+		{name: "ancient", output: "git version 1.8.3.1", expected: false},
+		{name: "unparseable", output: "not git", expected: false},
+		{name: "command failed", err: errors.New("exit status 1"), expected: false},
+	}
+
+	oldReadGitVersion := readGitVersion
+	defer func() { readGitVersion = oldReadGitVersion }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readGitVersion = func() (string, error) {
+				return tt.output, tt.err
+			}
+			if got := supportsSSHCommandConfig(); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBuildCloneCommand(t *testing.T) {
+	const repo = "git@github.com:haukened/ghc.git"
+
+	cmd := buildCloneCommand("/tmp/config", repo, false)
+	expected := []string{"git", "clone", "--config", "core.sshCommand=ssh -F /tmp/config", repo}
+	if !slices.Equal(cmd.Args, expected) {
+		t.Errorf("expected %v, got %v", expected, cmd.Args)
+	}
+
+	cmd = buildCloneCommand("/tmp/config", repo, true)
+	expected = []string{"git", "clone", repo}
+	if !slices.Equal(cmd.Args, expected) {
+		t.Errorf("expected %v, got %v", expected, cmd.Args)
+	}
+	if !slices.ContainsFunc(cmd.Env, func(env string) bool {
+		return strings.HasPrefix(env, "GIT_SSH_COMMAND=ssh -F /tmp/config")
+	}) {
+		t.Errorf("expected GIT_SSH_COMMAND in the environment")
+	}
+
+	// extra clone arguments go before the URI
+	cmd = buildCloneCommand("/tmp/config", repo, false, "--no-checkout")
+	expected = []string{"git", "clone", "--config", "core.sshCommand=ssh -F /tmp/config", "--no-checkout", repo}
+	if !slices.Equal(cmd.Args, expected) {
+		t.Errorf("expected %v, got %v", expected, cmd.Args)
+	}
+	cmd = buildCloneCommand("/tmp/config", repo, true, "--no-checkout")
+	expected = []string{"git", "clone", "--no-checkout", repo}
+	if !slices.Equal(cmd.Args, expected) {
+		t.Errorf("expected %v, got %v", expected, cmd.Args)
+	}
+}
@@ -0,0 +1,78 @@
+package clone
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks are optional callbacks fired at each stage of a clone, so that tools
+// embedding ghc can log or measure clones without ghc importing a logging
+// framework. They are passed to CloneRepo with WithHooks, for that run only.
+// Nil callbacks are skipped. When several repositories are cloned
+// in parallel, the callbacks are called from several goroutines at once and
+// must be safe for concurrent use.
+type Hooks struct {
+	OnResolveOrg      func(ResolveOrgEvent)      // the SSH key for a repository was selected
+	OnConfigGenerated func(ConfigGeneratedEvent) // an SSH config file was written for a repository
+	OnCloneStart      func(CloneStartEvent)      // git is about to clone or pull a repository
+	OnCloneComplete   func(CloneCompleteEvent)   // git finished cloning or pulling a repository
+}
+
+// ResolveOrgEvent is passed to Hooks.OnResolveOrg.
+type ResolveOrgEvent struct {
+	RepoURL string // the repository being cloned
+	Org     string // the organization whose key is used, empty if the key was given with --key
+	KeyPath string // the SSH key used to clone
+}
+
+// ConfigGeneratedEvent is passed to Hooks.OnConfigGenerated. It isn't fired
+// for organizations that clone through an SSH host alias.
+type ConfigGeneratedEvent struct {
+	RepoURL    string // the repository being cloned
+	ConfigPath string // the generated SSH config file
+}
+
+// CloneStartEvent is passed to Hooks.OnCloneStart.
+type CloneStartEvent struct {
+	RepoURL string // the repository being cloned
+	Dir     string // the directory it is cloned into
+	Pull    bool   // whether an existing clone is updated with git pull instead
+}
+
+// CloneCompleteEvent is passed to Hooks.OnCloneComplete, whether git succeeded or not.
+type CloneCompleteEvent struct {
+	RepoURL  string        // the repository being cloned
+	Dir      string        // the directory it is cloned into
+	Pull     bool          // whether an existing clone was updated with git pull instead
+	Duration time.Duration // how long git ran
+	Err      error         // the error from git, nil on success
+}
+
+// hooksKey is the context key of the Hooks set with WithHooks.
+type hooksKey struct{}
+
+// WithHooks returns a copy of ctx carrying h, so CloneRepo, when run with it,
+// fires the callbacks of h. Other runs are unaffected.
+func WithHooks(ctx context.Context, h Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, h)
+}
+
+// hooksFrom returns the Hooks carried by ctx, or none.
+func hooksFrom(ctx context.Context) Hooks {
+	h, _ := ctx.Value(hooksKey{}).(Hooks)
+	return h
+}
+
+// runGit runs git to clone or pull repoURL into dir, firing the
+// OnCloneStart and OnCloneComplete hooks around it.
+func (h Hooks) runGit(repoURL, dir string, pull bool, run func() error) error {
+	if h.OnCloneStart != nil {
+		h.OnCloneStart(CloneStartEvent{RepoURL: repoURL, Dir: dir, Pull: pull})
+	}
+	start := time.Now()
+	err := run()
+	if h.OnCloneComplete != nil {
+		h.OnCloneComplete(CloneCompleteEvent{RepoURL: repoURL, Dir: dir, Pull: pull, Duration: time.Since(start), Err: err})
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package clone
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"ghc/internal/configfile"
+)
+
+func TestCloneRepo_Hooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		gitErr    error
+		directKey bool
+		expectOrg string
+	}{
+		{name: "successful clone", expectOrg: "haukened"},
+		{name: "failed clone", gitErr: errors.New("git failed"), expectOrg: "haukened"},
+		{name: "key given directly", directKey: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			mock.err = tt.gitErr
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load test config: %v", err)
+			}
+			keyPath := conf.Organizations[0].SSHKeyPath
+
+			// record every hook in the order it fires
+			var fired []string
+			var resolved ResolveOrgEvent
+			var generated ConfigGeneratedEvent
+			var started CloneStartEvent
+			var completed CloneCompleteEvent
+			hooks := Hooks{
+				OnResolveOrg:      func(e ResolveOrgEvent) { fired = append(fired, "resolve"); resolved = e },
+				OnConfigGenerated: func(e ConfigGeneratedEvent) { fired = append(fired, "config"); generated = e },
+				OnCloneStart:      func(e CloneStartEvent) { fired = append(fired, "start"); started = e },
+				OnCloneComplete:   func(e CloneCompleteEvent) { fired = append(fired, "complete"); completed = e },
+			}
+
+			args := []string{"clone"}
+			if tt.directKey {
+				args = append(args, "--key", keyPath)
+			}
+			args = append(args, "git@github.com:haukened/ghc.git")
+
+			var stdout, stderr bytes.Buffer
+			err = newCloneCommand(&stdout, &stderr).Run(WithHooks(t.Context(), hooks), args)
+			if !errors.Is(err, tt.gitErr) {
+				t.Fatalf("expected %v, got %v", tt.gitErr, err)
+			}
+
+			if expected := []string{"resolve", "config", "start", "complete"}; !slices.Equal(fired, expected) {
+				t.Fatalf("expected hooks %v, got %v", expected, fired)
+			}
+			repoURL := "git@github.com:haukened/ghc.git"
+			if resolved != (ResolveOrgEvent{RepoURL: repoURL, Org: tt.expectOrg, KeyPath: keyPath}) {
+				t.Errorf("unexpected resolve event: %+v", resolved)
+			}
+			if generated.RepoURL != repoURL || filepath.Dir(generated.ConfigPath) != filepath.Clean(sshConfigDir()) {
+				t.Errorf("unexpected config event: %+v", generated)
+			}
+			if started != (CloneStartEvent{RepoURL: repoURL, Dir: "ghc"}) {
+				t.Errorf("unexpected start event: %+v", started)
+			}
+			if completed.RepoURL != repoURL || completed.Dir != "ghc" || completed.Pull || !errors.Is(completed.Err, tt.gitErr) || completed.Duration < 0 {
+				t.Errorf("unexpected complete event: %+v", completed)
+			}
+		})
+	}
+
+	// without hooks, nothing is fired and cloning works as before
+	_, mock := setupCloneTest(t)
+	var stdout, stderr bytes.Buffer
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "git@github.com:haukened/ghc.git"}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(mock.cmds) != 1 || !strings.Contains(strings.Join(mock.cmds[0].Args, " "), "clone") {
+		t.Errorf("expected a single clone, got %d commands", len(mock.cmds))
+	}
+}
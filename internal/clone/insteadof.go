@@ -0,0 +1,72 @@
+package clone
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// urlRewrite is a url.<base>.insteadOf entry from the user's git config:
+// URLs starting with prefix are rewritten to start with base instead.
+type urlRewrite struct {
+	base   string
+	prefix string
+}
+
+// readURLRewrites returns the url.*.insteadOf entries of the user's git config,
+// as printed by `git config --get-regexp`. No entries is not an error.
+// This can be overridden in tests.
+var readURLRewrites = func() (string, error) {
+	git, err := lookPath("git")
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	cmd := exec.Command(git, "config", "--get-regexp", `^url\..*\.insteadof$`)
+	cmd.Stdout = &out
+	cmd.Stderr = io.Discard
+	err = runner.Run(cmd)
+	// git exits with status 1 when nothing matches
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	return out.String(), err
+}
+
+// parseURLRewrites parses `git config --get-regexp` output, one
+// "url.<base>.insteadof <prefix>" entry per line. git lowercases the
+// section and key, but keeps the base as written. Malformed lines are skipped.
+func parseURLRewrites(output string) []urlRewrite {
+	var rewrites []urlRewrite
+	for line := range strings.Lines(output) {
+		key, prefix, found := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		if !found || prefix == "" || len(key) < len("url..insteadof") {
+			continue
+		}
+		if !strings.EqualFold(key[:len("url.")], "url.") || !strings.EqualFold(key[len(key)-len(".insteadof"):], ".insteadof") {
+			continue
+		}
+		base := key[len("url.") : len(key)-len(".insteadof")]
+		rewrites = append(rewrites, urlRewrite{base: base, prefix: prefix})
+	}
+	return rewrites
+}
+
+// rewriteURL applies the rewrites to repoURL like git does: the entry with
+// the longest prefix matching the start of the URL wins, and is applied once.
+// URLs that no entry matches are returned unchanged.
+func rewriteURL(repoURL string, rewrites []urlRewrite) string {
+	var best *urlRewrite
+	for i, r := range rewrites {
+		if strings.HasPrefix(repoURL, r.prefix) && (best == nil || len(r.prefix) > len(best.prefix)) {
+			best = &rewrites[i]
+		}
+	}
+	if best == nil {
+		return repoURL
+	}
+	return best.base + strings.TrimPrefix(repoURL, best.prefix)
+}
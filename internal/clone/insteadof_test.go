@@ -0,0 +1,114 @@
+package clone
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"slices"
+	"testing"
+)
+
+func TestParseURLRewrites(t *testing.T) {
+	output := "url.git@github.com:.insteadof https://github.com/\n" +
+		"url.ssh://git@GHE.example.com/.insteadof ghe:\n" +
+		"not a rewrite\n" +
+		"url.git@github.com:.insteadof\n"
+	expected := []urlRewrite{
+		{base: "git@github.com:", prefix: "https://github.com/"},
+		{base: "ssh://git@GHE.example.com/", prefix: "ghe:"},
+	}
+	if rewrites := parseURLRewrites(output); !slices.Equal(rewrites, expected) {
+		t.Errorf("expected %+v, got %+v", expected, rewrites)
+	}
+}
+
+// gitReadURLRewrites is the readURLRewrites that setupCloneTest replaces.
+var gitReadURLRewrites = readURLRewrites
+
+func TestReadURLRewrites(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	readURLRewrites = gitReadURLRewrites
+	mock.output = "url.git@github.com:.insteadof https://github.com/\n"
+
+	output, err := readURLRewrites()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != mock.output {
+		t.Errorf("expected %q, got %q", mock.output, output)
+	}
+	expected := []string{"/usr/bin/git", "config", "--get-regexp", `^url\..*\.insteadof$`}
+	if len(mock.cmds) != 1 || !slices.Equal(mock.cmds[0].Args, expected) {
+		t.Errorf("expected %v, got %v", expected, mock.cmds)
+	}
+
+	// without git, there is nothing to read
+	lookPath = func(string) (string, error) { return "", exec.ErrNotFound }
+	if _, err := readURLRewrites(); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("expected %v, got %v", exec.ErrNotFound, err)
+	}
+}
+
+func TestRewriteURL(t *testing.T) {
+	rewrites := []urlRewrite{
+		{base: "git@github.com:", prefix: "https://github.com/"},
+		{base: "git@github.com:haukened/", prefix: "https://github.com/haukened/"},
+		{base: "git@github.com:", prefix: "gh:"},
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{name: "https to ssh", url: "https://github.com/other/api.git", expected: "git@github.com:other/api.git"},
+		{name: "longest prefix wins", url: "https://github.com/haukened/ghc.git", expected: "git@github.com:haukened/ghc.git"},
+		{name: "short alias", url: "gh:haukened/ghc.git", expected: "git@github.com:haukened/ghc.git"},
+		{name: "already ssh", url: "git@github.com:haukened/ghc.git", expected: "git@github.com:haukened/ghc.git"},
+		{name: "prefix only at the start", url: "git@github.com:gh:x/y.git", expected: "git@github.com:gh:x/y.git"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rewriteURL(tt.url, rewrites); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_InsteadOf(t *testing.T) {
+	tests := []struct {
+		name      string
+		gitConfig string
+		readErr   error
+		expectErr error
+	}{
+		{name: "rewritten to ssh", gitConfig: "url.git@github.com:.insteadof https://github.com/\n"},
+		{name: "no rewrites", expectErr: ErrInvalidRepoURLFormat},
+		{name: "unreadable git config", readErr: errors.New("git failed"), expectErr: ErrInvalidRepoURLFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupCloneTest(t)
+			readURLRewrites = func() (string, error) {
+				return tt.gitConfig, tt.readErr
+			}
+
+			var stdout, stderr bytes.Buffer
+			err := newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "https://github.com/haukened/ghc.git"})
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				return
+			}
+
+			// git is given the rewritten URL
+			if len(mock.cmds) != 1 || !slices.Contains(mock.cmds[0].Args, "git@github.com:haukened/ghc.git") {
+				t.Errorf("expected a clone of the rewritten URL, got %v", mock.cmds)
+			}
+		})
+	}
+}
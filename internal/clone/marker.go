@@ -0,0 +1,75 @@
+package clone
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// markerFile is the name of the file --write-marker writes into a clone.
+const markerFile = ".ghc"
+
+// marker is the JSON that --write-marker writes into a clone, so other tools
+// can tell which organization and key it was cloned with, and when.
+type marker struct {
+	Org      string    `json:"org"`
+	Host     string    `json:"host"`
+	KeyPath  string    `json:"key_path,omitempty"`
+	Repo     string    `json:"repo"`
+	ClonedAt time.Time `json:"cloned_at"`
+}
+
+// writeMarker writes m as indented JSON to the marker file in dir, and lists
+// the marker file in the clone's .git/info/exclude, if it has a .git
+// directory, so it never shows up as an untracked file.
+func writeMarker(dir string, m marker) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, markerFile), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	gitDir := filepath.Join(dir, ".git")
+	if !dirExists(gitDir) {
+		return nil
+	}
+	return excludeMarker(gitDir)
+}
+
+// excludeMarker adds the marker file to .git/info/exclude in gitDir, unless it
+// is already listed, so writing the marker again doesn't add it twice.
+func excludeMarker(gitDir string) error {
+	if err := os.MkdirAll(filepath.Join(gitDir, "info"), 0755); err != nil {
+		return err
+	}
+	excludePath := filepath.Join(gitDir, "info", "exclude")
+	existing, err := os.ReadFile(excludePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for line := range strings.Lines(string(existing)) {
+		if strings.TrimSpace(line) == "/"+markerFile {
+			return nil
+		}
+	}
+
+	entry := "/" + markerFile + "\n"
+	// don't join the entry onto a last line without a newline
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		entry = "\n" + entry
+	}
+	f, err := os.OpenFile(excludePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
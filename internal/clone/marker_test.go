@@ -0,0 +1,125 @@
+package clone
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// cloningRunner creates the destination of each git clone, with a .git
+// directory, as git would, before handing the command to next.
+type cloningRunner struct {
+	next CommandRunner
+	dir  string
+}
+
+func (r cloningRunner) Run(cmd *exec.Cmd) error {
+	if slices.Contains(cmd.Args, "clone") {
+		if err := os.MkdirAll(filepath.Join(r.dir, ".git"), 0755); err != nil {
+			return err
+		}
+	}
+	return r.next.Run(cmd)
+}
+
+func TestCloneRepo_WriteMarker(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	t.Chdir(t.TempDir())
+	runner = cloningRunner{next: mock, dir: "ghc"}
+
+	clonedAt := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	origNow := now
+	now = func() time.Time { return clonedAt.Add(500 * time.Millisecond) }
+	t.Cleanup(func() { now = origNow })
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--write-marker", "git@github.com:haukened/ghc.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v\n%s", err, stderr.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join("ghc", markerFile))
+	if err != nil {
+		t.Fatalf("failed to read marker: %v", err)
+	}
+	var got marker
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to parse marker %s: %v", data, err)
+	}
+	if got.Org != "haukened" || got.Host != "github.com" || got.Repo != "git@github.com:haukened/ghc.git" || got.KeyPath == "" {
+		t.Errorf("unexpected marker: %+v", got)
+	}
+	if !got.ClonedAt.Equal(clonedAt) {
+		t.Errorf("expected cloned_at %v, got %v", clonedAt, got.ClonedAt)
+	}
+
+	// the marker is kept out of git status
+	exclude, err := os.ReadFile(filepath.Join("ghc", ".git", "info", "exclude"))
+	if err != nil {
+		t.Fatalf("failed to read exclude: %v", err)
+	}
+	if string(exclude) != "/.ghc\n" {
+		t.Errorf("expected the marker to be excluded, got %q", exclude)
+	}
+}
+
+func TestCloneRepo_NoMarker(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	t.Chdir(t.TempDir())
+	runner = cloningRunner{next: mock, dir: "ghc"}
+
+	var stdout, stderr bytes.Buffer
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "git@github.com:haukened/ghc.git"}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join("ghc", markerFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no marker without --write-marker, got %v", err)
+	}
+}
+
+func TestExcludeMarker(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // the exclude file before, if any
+		expected string
+	}{
+		{name: "no exclude file", expected: "/.ghc\n"},
+		{name: "appended", existing: "# comment\n*.log\n", expected: "# comment\n*.log\n/.ghc\n"},
+		{name: "no trailing newline", existing: "*.log", expected: "*.log\n/.ghc\n"},
+		{name: "already excluded", existing: "/.ghc\n*.log\n", expected: "/.ghc\n*.log\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitDir := t.TempDir()
+			excludePath := filepath.Join(gitDir, "info", "exclude")
+			if tt.existing != "" {
+				if err := os.MkdirAll(filepath.Dir(excludePath), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(excludePath, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// excluding twice is the same as excluding once
+			for range 2 {
+				if err := excludeMarker(gitDir); err != nil {
+					t.Fatalf("expected nil, got %v", err)
+				}
+			}
+			got, err := os.ReadFile(excludePath)
+			if err != nil {
+				t.Fatalf("failed to read exclude: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package clone
+
+import "os"
+
+// fileOwner reports false on platforms without Unix file owners.
+func fileOwner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
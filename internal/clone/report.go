@@ -0,0 +1,120 @@
+package clone
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Report statuses of a single repository.
+const (
+	reportOK     = "ok"
+	reportFailed = "failed"
+)
+
+// report is the JSON written by --report-file, with the outcome of every repository.
+type report struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []reportEntry `json:"results"`
+}
+
+// reportEntry is the outcome of a single repository in a report.
+type reportEntry struct {
+	Repo            string  `json:"repo"`
+	Org             string  `json:"org"`
+	Status          string  `json:"status"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// newReport summarizes the outcomes of a clone, in the order they were given.
+func newReport(outcomes []cloneOutcome) report {
+	r := report{Results: make([]reportEntry, 0, len(outcomes))}
+	for _, o := range outcomes {
+		entry := newReportEntry(o)
+		if o.err != nil {
+			r.Failed++
+		} else {
+			r.Succeeded++
+		}
+		r.Results = append(r.Results, entry)
+	}
+	return r
+}
+
+// newReportEntry describes the outcome of a single repository.
+func newReportEntry(o cloneOutcome) reportEntry {
+	entry := reportEntry{
+		Repo:            o.job.repoURL,
+		Org:             o.job.orgName,
+		Status:          reportOK,
+		DurationSeconds: o.duration.Seconds(),
+	}
+	if o.err != nil {
+		entry.Status = reportFailed
+		entry.Error = o.err.Error()
+	}
+	return entry
+}
+
+// writeReport writes the report of outcomes as indented JSON to path.
+func writeReport(path string, outcomes []cloneOutcome) error {
+	data, err := json.MarshalIndent(newReport(outcomes), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Kinds of --ndjson events.
+const (
+	eventStart    = "start"
+	eventComplete = "complete"
+)
+
+// startEvent is the --ndjson event written as a clone starts.
+type startEvent struct {
+	Event string `json:"event"`
+	Repo  string `json:"repo"`
+	Org   string `json:"org"`
+}
+
+// completeEvent is the --ndjson event written as a clone completes, with its
+// outcome as it appears in a report.
+type completeEvent struct {
+	Event string `json:"event"`
+	reportEntry
+}
+
+// eventWriter writes --ndjson events to w, one JSON object per line, as each
+// clone starts and completes, so a long batch can be followed live. It is safe
+// for concurrent use by the clones of a batch. A nil eventWriter writes nothing.
+type eventWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// started writes the start event of job.
+func (e *eventWriter) started(job cloneJob) {
+	e.write(startEvent{Event: eventStart, Repo: job.repoURL, Org: job.orgName})
+}
+
+// completed writes the complete event of an outcome.
+func (e *eventWriter) completed(o cloneOutcome) {
+	e.write(completeEvent{Event: eventComplete, reportEntry: newReportEntry(o)})
+}
+
+func (e *eventWriter) write(event any) {
+	if e == nil {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(data, '\n'))
+}
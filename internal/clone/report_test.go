@@ -0,0 +1,173 @@
+package clone
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCloneRepo_ReportFile(t *testing.T) {
+	_, mock := setupCloneTest(t)
+	t.Chdir(t.TempDir())
+
+	// the second repository is already cloned, so it fails
+	if err := os.Mkdir("existing", 0755); err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{
+		"clone", "--parallel", "2", "--report-file", reportPath,
+		"git@github.com:haukened/ghc.git",
+		"git@github.com:haukened/existing.git",
+		"git@github.com:other/tool.git",
+	}
+	err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args)
+	if !errors.Is(err, ErrDestinationExists) {
+		t.Fatalf("expected %v, got %v", ErrDestinationExists, err)
+	}
+	if len(mock.cmds) != 2 {
+		t.Errorf("expected 2 clones, got %d", len(mock.cmds))
+	}
+
+	// the report is written even though a clone failed
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var got report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to parse report %s: %v", data, err)
+	}
+	if got.Succeeded != 2 || got.Failed != 1 || len(got.Results) != 3 {
+		t.Fatalf("unexpected report: %+v", got)
+	}
+	expected := []reportEntry{
+		{Repo: "git@github.com:haukened/ghc.git", Org: "haukened", Status: reportOK},
+		{Repo: "git@github.com:haukened/existing.git", Org: "haukened", Status: reportFailed},
+		{Repo: "git@github.com:other/tool.git", Org: "other", Status: reportOK},
+	}
+	for i, want := range expected {
+		entry := got.Results[i]
+		if entry.Repo != want.Repo || entry.Org != want.Org || entry.Status != want.Status || entry.DurationSeconds < 0 {
+			t.Errorf("expected %+v, got %+v", want, entry)
+		}
+		if (entry.Error != "") != (want.Status == reportFailed) {
+			t.Errorf("expected an error only for failed repositories, got %+v", entry)
+		}
+	}
+}
+
+func TestCloneRepo_ReportFileSingle(t *testing.T) {
+	setupCloneTest(t)
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"clone", "--report-file", reportPath, "git@github.com:haukened/ghc.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var got report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to parse report %s: %v", data, err)
+	}
+	if got.Succeeded != 1 || got.Failed != 0 || len(got.Results) != 1 || got.Results[0].Status != reportOK {
+		t.Errorf("unexpected report: %+v", got)
+	}
+
+	// a report that can't be written fails the command
+	args = []string{"clone", "--report-file", filepath.Join(t.TempDir(), "missing", "report.json"), "git@github.com:haukened/ghc.git"}
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected %v, got %v", os.ErrNotExist, err)
+	}
+}
+
+func TestCloneRepo_NDJSON(t *testing.T) {
+	setupCloneTest(t)
+	t.Chdir(t.TempDir())
+
+	// the second repository is already cloned, so it fails
+	if err := os.Mkdir("existing", 0755); err != nil {
+		t.Fatal(err)
+	}
+	repos := []string{
+		"git@github.com:haukened/ghc.git",
+		"git@github.com:haukened/existing.git",
+		"git@github.com:other/tool.git",
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"clone", "--ndjson", "--parallel", "2"}, repos...)
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); !errors.Is(err, ErrDestinationExists) {
+		t.Fatalf("expected %v, got %v", ErrDestinationExists, err)
+	}
+
+	// every line of stdout is an event, and each repository starts, then completes
+	type event struct {
+		Event  string `json:"event"`
+		Repo   string `json:"repo"`
+		Org    string `json:"org"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	seen := map[string][]event{}
+	lines := 0
+	for line := range strings.Lines(stdout.String()) {
+		var e event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("expected a JSON event, got %q: %v", line, err)
+		}
+		seen[e.Repo] = append(seen[e.Repo], e)
+		lines++
+	}
+	if lines != 2*len(repos) {
+		t.Fatalf("expected %d events, got %d:\n%s", 2*len(repos), lines, stdout.String())
+	}
+	for _, repo := range repos {
+		events := seen[repo]
+		if len(events) != 2 || events[0].Event != eventStart || events[1].Event != eventComplete {
+			t.Errorf("expected start then complete for %s, got %+v", repo, events)
+			continue
+		}
+		expectStatus := reportOK
+		if repo == "git@github.com:haukened/existing.git" {
+			expectStatus = reportFailed
+		}
+		if events[1].Status != expectStatus || (events[1].Error != "") != (expectStatus == reportFailed) {
+			t.Errorf("expected status %s for %s, got %+v", expectStatus, repo, events[1])
+		}
+		if events[0].Org == "" || events[0].Org != events[1].Org {
+			t.Errorf("expected the organization in both events, got %+v", events)
+		}
+	}
+}
+
+func TestEventWriter(t *testing.T) {
+	var out bytes.Buffer
+	events := &eventWriter{w: &out}
+	job := cloneJob{repoURL: "git@github.com:haukened/ghc.git", orgName: "haukened"}
+	events.started(job)
+	events.completed(cloneOutcome{job: job, err: errors.New("boom"), duration: 1500 * time.Millisecond})
+
+	expected := `{"event":"start","repo":"git@github.com:haukened/ghc.git","org":"haukened"}
+{"event":"complete","repo":"git@github.com:haukened/ghc.git","org":"haukened","status":"failed","error":"boom","duration_seconds":1.5}
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	// a nil writer writes nothing
+	var none *eventWriter
+	none.started(job)
+}
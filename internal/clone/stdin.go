@@ -0,0 +1,103 @@
+package clone
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"ghc/internal/utils"
+)
+
+// readRepoURLs reads repository URLs from r, one per line, or, if null is set,
+// separated by NUL bytes like the output of find -print0. Lines are trimmed and
+// blank ones skipped; NUL-separated URLs are kept exactly as given, except
+// that empty ones, such as after a trailing NUL, are skipped.
+func readRepoURLs(r io.Reader, null bool) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	if null {
+		scanner.Split(scanNull)
+	}
+
+	var urls []string
+	for scanner.Scan() {
+		url := scanner.Text()
+		if !null {
+			url = strings.TrimSpace(url)
+		}
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls, scanner.Err()
+}
+
+// readRepoURLFile reads repository URLs from the file at path, one per line.
+// Lines are trimmed, and blank ones and "#" comments skipped. A line may have
+// more tab-separated columns after the URL, such as from "gh repo list"; the
+// second one is then the time the repository was last pushed, in RFC 3339.
+// If since is set, only repositories pushed at or after since are returned.
+//
+// Returns an error wrapping ErrInvalidRepoList if since is set and a line has
+// no valid push time.
+func readRepoURLFile(path string, since time.Time) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		columns := strings.Split(line, "\t")
+		url := strings.TrimSpace(columns[0])
+		if since.IsZero() {
+			urls = append(urls, url)
+			continue
+		}
+		if len(columns) < 2 {
+			return nil, fmt.Errorf("%w: line %d: no push time for %s", ErrInvalidRepoList, n, url)
+		}
+		pushedAt, err := time.Parse(time.RFC3339, strings.TrimSpace(columns[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid push time %q", ErrInvalidRepoList, n, columns[1])
+		}
+		if !pushedAt.Before(since) {
+			urls = append(urls, url)
+		}
+	}
+	return urls, scanner.Err()
+}
+
+// parseSince parses the --since window with utils.ParseDuration, which accepts
+// whole days and weeks, such as 30d or 2w, besides the units of time.ParseDuration.
+// It returns an error wrapping ErrInvalidSince unless the window is positive.
+func parseSince(s string) (time.Duration, error) {
+	d, ok := utils.ParseDuration(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSince, s)
+	}
+	return d, nil
+}
+
+// now returns the current time. This can be overridden in tests.
+var now = time.Now
+
+// scanNull is a bufio.SplitFunc that splits on NUL bytes.
+func scanNull(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
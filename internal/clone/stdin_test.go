@@ -0,0 +1,232 @@
+package clone
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRepoURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		null     bool
+		expected []string
+	}{
+		{name: "lines", input: "git@github.com:org1/a.git\ngit@github.com:org2/b.git\n", expected: []string{"git@github.com:org1/a.git", "git@github.com:org2/b.git"}},
+		{name: "blank lines and spaces", input: "\n  git@github.com:org1/a.git \r\n\n", expected: []string{"git@github.com:org1/a.git"}},
+		{name: "null separated", input: "git@github.com:org1/a.git\x00git@github.com:org2/b.git\x00", null: true, expected: []string{"git@github.com:org1/a.git", "git@github.com:org2/b.git"}},
+		{name: "null without trailing NUL", input: "git@github.com:org1/a.git\x00git@github.com:org2/b.git", null: true, expected: []string{"git@github.com:org1/a.git", "git@github.com:org2/b.git"}},
+		{name: "null keeps newlines in URLs", input: "git@github.com:org1/a\nb.git\x00", null: true, expected: []string{"git@github.com:org1/a\nb.git"}},
+		{name: "empty", input: "", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, err := readRepoURLs(strings.NewReader(tt.input), tt.null)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if !slices.Equal(urls, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, urls)
+			}
+		})
+	}
+}
+
+func TestReadRepoURLFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repos.txt")
+	content := "# work repos\ngit@github.com:org1/a.git\n\n   \n  # indented comment\n  git@github.com:org2/b.git  \r\n# trailing comment"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write repo list: %v", err)
+	}
+
+	urls, err := readRepoURLFile(path, time.Time{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	expected := []string{"git@github.com:org1/a.git", "git@github.com:org2/b.git"}
+	if !slices.Equal(urls, expected) {
+		t.Errorf("expected %q, got %q", expected, urls)
+	}
+
+	if _, err := readRepoURLFile(filepath.Join(t.TempDir(), "missing.txt"), time.Time{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected %v, got %v", os.ErrNotExist, err)
+	}
+}
+
+func TestCloneRepo_FromFile(t *testing.T) {
+	setupCloneTest(t)
+	path := filepath.Join(t.TempDir(), "repos.txt")
+	if err := os.WriteFile(path, []byte("# repos\ngit@github.com:haukened/ghc.git\n\ngit@github.com:other-org/api.git\n"), 0o600); err != nil {
+		t.Fatalf("failed to write repo list: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := newCloneCommand(&stdout, &stderr)
+	if err := cmd.Run(t.Context(), []string{"clone", "--print-org", "--from-file", path, "git@github.com:first/repo.git"}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if expected := "first\nhaukened\nother-org\n"; stdout.String() != expected {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+}
+
+func TestReadRepoURLFile_Since(t *testing.T) {
+	since := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "repos.tsv")
+	content := "# pushed\n" +
+		"git@github.com:org1/after.git\t2025-06-01T12:00:01Z\n" +
+		"git@github.com:org1/at.git\t2025-06-01T12:00:00Z\n" +
+		"git@github.com:org1/before.git\t2025-06-01T11:59:59Z\n" +
+		"\n" +
+		"git@github.com:org1/offset.git\t2025-06-01T13:00:00+02:00\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write repo list: %v", err)
+	}
+
+	urls, err := readRepoURLFile(path, since)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	expected := []string{"git@github.com:org1/after.git", "git@github.com:org1/at.git"}
+	if !slices.Equal(urls, expected) {
+		t.Errorf("expected %q, got %q", expected, urls)
+	}
+
+	// without since, every URL is read and the timestamps ignored
+	urls, err = readRepoURLFile(path, time.Time{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(urls) != 4 || urls[3] != "git@github.com:org1/offset.git" {
+		t.Errorf("expected all 4 URLs, got %q", urls)
+	}
+
+	for _, bad := range []string{"git@github.com:org1/a.git\n", "git@github.com:org1/a.git\tyesterday\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0o600); err != nil {
+			t.Fatalf("failed to write repo list: %v", err)
+		}
+		if _, err := readRepoURLFile(path, since); !errors.Is(err, ErrInvalidRepoList) {
+			t.Errorf("%q: expected %v, got %v", bad, ErrInvalidRepoList, err)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  time.Duration
+		expectErr error
+	}{
+		{input: "30d", expected: 30 * 24 * time.Hour},
+		{input: "2w", expected: 14 * 24 * time.Hour},
+		{input: "12h", expected: 12 * time.Hour},
+		{input: "0d", expectErr: ErrInvalidSince},
+		{input: "-1h", expectErr: ErrInvalidSince},
+		{input: "soon", expectErr: ErrInvalidSince},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d, err := parseSince(tt.input)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if d != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, d)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_Since(t *testing.T) {
+	setupCloneTest(t)
+	origNow := now
+	now = func() time.Time { return time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { now = origNow })
+
+	path := filepath.Join(t.TempDir(), "repos.tsv")
+	content := "git@github.com:recent/a.git\t2025-06-20T00:00:00Z\ngit@github.com:stale/b.git\t2025-01-01T00:00:00Z\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write repo list: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		args         []string
+		expected     string
+		expectStderr string
+		expectErr    error
+	}{
+		{name: "within 30 days", args: []string{"clone", "--print-org", "--from-file", path, "--since", "30d"}, expected: "recent\n"},
+		{name: "nothing within a day", args: []string{"clone", "--print-org", "--from-file", path, "--since", "1d"}, expectStderr: "No repositories in " + path + " were pushed within 1d\n"},
+		{name: "no window", args: []string{"clone", "--print-org", "--from-file", path}, expected: "recent\nstale\n"},
+		{name: "invalid window", args: []string{"clone", "--print-org", "--from-file", path, "--since", "later"}, expectErr: ErrInvalidSince},
+		{name: "without a file", args: []string{"clone", "--print-org", "--since", "30d", "git@github.com:recent/a.git"}, expectErr: ErrInvalidSince},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			cmd := newCloneCommand(&stdout, &stderr)
+			err := cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if stdout.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, stdout.String())
+			}
+			if tt.expectStderr != "" && stderr.String() != tt.expectStderr {
+				t.Errorf("expected stderr %q, got %q", tt.expectStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestCloneRepo_Stdin(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		stdin     string
+		expected  string
+		expectErr error
+	}{
+		{
+			name:     "null separated",
+			args:     []string{"clone", "--print-org", "--stdin-null"},
+			stdin:    "git@github.com:haukened/ghc.git\x00git@github.com:other-org/api.git\x00",
+			expected: "haukened\nother-org\n",
+		},
+		{
+			name:     "newline separated, after the arguments",
+			args:     []string{"clone", "--print-org", "--stdin", "git@github.com:first/repo.git"},
+			stdin:    "git@github.com:haukened/ghc.git\n",
+			expected: "first\nhaukened\n",
+		},
+		{name: "nothing to clone", args: []string{"clone", "--stdin-null"}, expectErr: ErrInvalidArgs},
+		{name: "config is also piped", args: []string{"clone", "--stdin", "--config-stdin"}, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupCloneTest(t)
+
+			var stdout, stderr bytes.Buffer
+			cmd := newCloneCommand(&stdout, &stderr)
+			cmd.Reader = strings.NewReader(tt.stdin)
+			err := cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if stdout.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package clone
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// traceRunner is a CommandRunner that writes everything needed to reproduce
+// a git command to w before running it with next: its exact argv, its working
+// directory, how ssh is configured for it, and the generated SSH config.
+type traceRunner struct {
+	next      CommandRunner
+	w         io.Writer
+	sshConfig string // path of the generated SSH config, or "" when there is none
+}
+
+func (t traceRunner) Run(cmd *exec.Cmd) error {
+	writeTrace(t.w, cmd, t.sshConfig)
+	return t.next.Run(cmd)
+}
+
+// writeTrace writes the trace of cmd to w, one "trace:" line per field,
+// followed by the contents of the SSH config at sshConfig, if there is one.
+func writeTrace(w io.Writer, cmd *exec.Cmd, sshConfig string) {
+	argv := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		argv[i] = shellQuote(arg)
+	}
+	fmt.Fprintf(w, "trace: argv: %s\n", strings.Join(argv, " "))
+
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	fmt.Fprintf(w, "trace: cwd: %s\n", dir)
+
+	for _, arg := range cmd.Args {
+		if sshCommand, ok := strings.CutPrefix(arg, "core.sshCommand="); ok {
+			fmt.Fprintf(w, "trace: core.sshCommand: %s\n", sshCommand)
+		}
+	}
+	// the command inherits the environment unless it sets its own
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GIT_SSH_COMMAND=") || strings.HasPrefix(kv, "GIT_SSH=") {
+			fmt.Fprintf(w, "trace: env: %s\n", kv)
+		}
+	}
+
+	if sshConfig == "" {
+		fmt.Fprintln(w, "trace: ssh config: none generated, ssh reads ~/.ssh/config")
+		return
+	}
+	data, err := os.ReadFile(sshConfig)
+	if err != nil {
+		fmt.Fprintf(w, "trace: ssh config %s: %v\n", sshConfig, err)
+		return
+	}
+	fmt.Fprintf(w, "trace: ssh config %s:\n", sshConfig)
+	for line := range strings.Lines(string(data)) {
+		fmt.Fprintf(w, "trace:   %s\n", strings.TrimSuffix(line, "\n"))
+	}
+}
+
+// shellQuote quotes s for a POSIX shell, if it needs quoting, so a traced
+// command can be pasted back into a terminal.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./_-", r))
+	}) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,93 @@
+package clone
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCloneRepo_Trace(t *testing.T) {
+	tests := []struct {
+		name       string
+		gitVersion string   // older releases get the SSH command through the environment
+		expected   []string // lines, or parts of lines, the trace must include
+	}{
+		{
+			name:       "core.sshCommand",
+			gitVersion: "git version 2.40.0\n",
+			expected: []string{
+				"trace: argv: git clone --config 'core.sshCommand=ssh -F ",
+				" git@github.com:haukened/ghc.git\n",
+				"trace: core.sshCommand: ssh -F ",
+			},
+		},
+		{
+			name:       "GIT_SSH_COMMAND",
+			gitVersion: "git version 2.9.0\n",
+			expected: []string{
+				"trace: argv: git clone git@github.com:haukened/ghc.git\n",
+				"trace: env: GIT_SSH_COMMAND=ssh -F ",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _ = setupCloneTest(t)
+			readGitVersion = func() (string, error) { return tt.gitVersion, nil }
+			cwd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			args := []string{"clone", "--trace", "git@github.com:haukened/ghc.git"}
+			if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+
+			trace := stderr.String()
+			expected := append(tt.expected,
+				"trace: cwd: "+cwd+"\n",
+				"trace: ssh config ",
+				"trace:   Host github.com\n",
+				"trace:   \tIdentityFile ",
+			)
+			for _, want := range expected {
+				if !strings.Contains(trace, want) {
+					t.Errorf("expected the trace to include %q, got:\n%s", want, trace)
+				}
+			}
+		})
+	}
+
+	// nothing is traced without the flag
+	_, _ = setupCloneTest(t)
+	var stdout, stderr bytes.Buffer
+	if err := newCloneCommand(&stdout, &stderr).Run(t.Context(), []string{"clone", "git@github.com:haukened/ghc.git"}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if strings.Contains(stderr.String(), "trace:") {
+		t.Errorf("expected no trace, got %q", stderr.String())
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in, expected string
+	}{
+		{in: "git", expected: "git"},
+		{in: "git@github.com:haukened/ghc.git", expected: "git@github.com:haukened/ghc.git"},
+		{in: "--filter=blob:none", expected: "--filter=blob:none"},
+		{in: "core.sshCommand=ssh -F /tmp/config", expected: "'core.sshCommand=ssh -F /tmp/config'"},
+		{in: "it's", expected: `'it'\''s'`},
+		{in: "", expected: "''"},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.expected {
+			t.Errorf("shellQuote(%q): expected %s, got %s", tt.in, tt.expected, got)
+		}
+	}
+}
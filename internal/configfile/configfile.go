@@ -4,14 +4,22 @@
 package configfile
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 
+	"github.com/fatih/color"
 	"github.com/knadh/koanf"
 	kjson "github.com/knadh/koanf/parsers/json"
-	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/rawbytes"
 
 	"ghc/internal/domain"
 	"ghc/internal/utils"
@@ -20,31 +28,195 @@ import (
 // DefaultConfigPath is the default path to the configuration file.
 const DefaultConfigPath = "$HOME/.config/ghc/ghc.conf"
 
+// LegacyConfigPath is where configuration files were stored by older releases.
+// It is also DefaultConfigPath, so ghc migrate only moves a file found here to
+// a path given with --config or --profile.
+const LegacyConfigPath = "$HOME/.config/ghc/ghc.conf"
+
+// DefaultOrgEnv names the environment variable that overrides which organization
+// is treated as the default, without changing the configuration file.
+const DefaultOrgEnv = "GHC_DEFAULT_ORG"
+
+// OrgKeyEnvPrefix and OrgKeyEnvSuffix surround the environment variable that
+// overrides an organization's key path at runtime. See OrgKeyEnv.
+const (
+	OrgKeyEnvPrefix = "GHC_ORG_"
+	OrgKeyEnvSuffix = "_KEY"
+)
+
+// ProfileEnv names the environment variable that selects a profile, like --profile.
+const ProfileEnv = "GHC_PROFILE"
+
+// profileExt is the extension of a profile's configuration file.
+const profileExt = ".conf"
+
 var (
-	ErrConfigNotFound  = errors.New("config file not found")
-	ErrHomeDirNotFound = errors.New("home directory not found")
+	ErrConfigExists      = errors.New("config file already exists")
+	ErrConfigNotFound    = errors.New("config file not found")
+	ErrConfigPermissions = errors.New("config file is accessible by other users")
+	ErrHomeDirNotFound   = errors.New("home directory not found")
+	ErrInvalidProfile    = errors.New("invalid profile name, expected letters, digits, '.', '_' or '-'")
+	ErrProfileWithConfig = errors.New("--profile and --config cannot be used together")
 )
 
+// profileRegex matches valid profile names, which must stay in the config directory.
+var profileRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
 // defaultConfigPath is the active path to the configuration file.
 var defaultConfigPath = DefaultConfigPath
 
+// ResolvePath returns the configuration file path to use. If override is set,
+// such as from the --config flag, it is expanded and returned; otherwise the
+// default path is expanded to the user's home directory.
+// It returns ErrHomeDirNotFound if the default is needed and there is no home directory.
+func ResolvePath(override string) (string, error) {
+	if override != "" {
+		return utils.ExpandPath(override), nil
+	}
+	if !homeDirExists() {
+		return "", ErrHomeDirNotFound
+	}
+	return utils.ExpandPath(defaultConfigPath), nil
+}
+
+// ResolveProfilePath returns the configuration file path to use, like ResolvePath,
+// or, if profile is set, the profile's own file next to the default configuration,
+// such as ~/.config/ghc/work.conf for the "work" profile.
+// It returns ErrProfileWithConfig if both override and profile are set, or
+// ErrInvalidProfile if the profile name isn't valid.
+func ResolveProfilePath(override, profile string) (string, error) {
+	if profile == "" {
+		return ResolvePath(override)
+	}
+	if override != "" {
+		return "", ErrProfileWithConfig
+	}
+	if !profileRegex.MatchString(profile) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidProfile, profile)
+	}
+	defaultPath, err := ResolvePath("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(defaultPath), profile+profileExt), nil
+}
+
 // LoadConfig loads the configuration from the default path.
 // It returns the configuration or an error if the file is not found or invalid.
 func LoadConfig() (*domain.Config, error) {
-	if !homeDirExists() {
-		return nil, ErrHomeDirNotFound
+	configPath, err := ResolvePath("")
+	if err != nil {
+		return nil, err
 	}
+	return LoadConfigFrom(configPath)
+}
 
-	// Expand the default config path to the user's home directory
-	configPath := utils.ExpandPath(defaultConfigPath)
+// Snapshot loads the configuration from the default path, like LoadConfig, and
+// returns a deep copy of it, for embedders: changing the snapshot never affects
+// ghc's own copy of the configuration, or the file.
+func Snapshot() (*domain.Config, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Clone(), nil
+}
 
+// LoadConfigFrom loads the configuration from the given path.
+// The runtime overrides are taken from the environment, as by ApplyEnvOverrides.
+// It returns the configuration or an error if the file is not found or invalid.
+func LoadConfigFrom(configPath string) (*domain.Config, error) {
 	// Check if the config file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return nil, ErrConfigNotFound
 	}
 
+	f, err := os.Open(configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg, err := ParseConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	ApplyEnvOverrides(cfg)
+	return cfg, nil
+}
+
+// OrgKeyEnv returns the name of the environment variable that overrides the
+// key path of the named organization: the name in upper case, with dashes
+// turned into underscores, between OrgKeyEnvPrefix and OrgKeyEnvSuffix, such
+// as GHC_ORG_MY_ORG_KEY for "my-org".
+func OrgKeyEnv(name string) string {
+	return OrgKeyEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + OrgKeyEnvSuffix
+}
+
+// ApplyEnvOverrides sets the runtime overrides of cfg from the environment:
+// the default organization from DefaultOrgEnv, and the key path of each
+// organization from its OrgKeyEnv, such as to inject a key in a CI job. The
+// overrides are never saved.
+func ApplyEnvOverrides(cfg *domain.Config) {
+	cfg.DefaultOverride = os.Getenv(DefaultOrgEnv)
+	for _, org := range cfg.Organizations {
+		if keyPath := os.Getenv(OrgKeyEnv(org.Name)); keyPath != "" {
+			org.KeyOverride = utils.ExpandPath(keyPath)
+		}
+	}
+}
+
+// LoadConfigChecked loads the configuration from the given path, like LoadConfigFrom,
+// after checking that the file is not accessible by other users, as ssh does for keys.
+// If the file is too open, a warning is written to warn, or, if strict is set, the
+// configuration is not loaded and an error wrapping ErrConfigPermissions is returned.
+// A warning is also written if more than one organization is marked as the
+// default, such as after hand-editing the file; the configuration still loads,
+// and the first of them is the effective default.
+func LoadConfigChecked(configPath string, strict bool, warn io.Writer) (*domain.Config, error) {
+	if err := CheckPermissions(configPath); err != nil {
+		if strict || !errors.Is(err, ErrConfigPermissions) {
+			return nil, err
+		}
+		utils.NewColor(color.FgYellow, color.Bold).Fprintf(warn, "WARNING: %v\n", err)
+	}
+	cfg, err := LoadConfigFrom(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if defaults := cfg.MarkedDefaults(); len(defaults) > 1 {
+		utils.NewColor(color.FgYellow, color.Bold).Fprintf(warn, "WARNING: %v: %s; using %s\n", domain.ErrMultipleDefaults, strings.Join(defaults, ", "), defaults[0])
+	}
+	return cfg, nil
+}
+
+// CheckPermissions returns an error wrapping ErrConfigPermissions if the
+// configuration file at configPath can be accessed by its group or by others.
+// A missing file is not an error here; loading it reports that.
+func CheckPermissions(configPath string) error {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if mode := info.Mode().Perm(); mode&0077 != 0 {
+		return fmt.Errorf("%w: %s has mode %04o, run: chmod 600 %s", ErrConfigPermissions, configPath, mode, configPath)
+	}
+	return nil
+}
+
+// ParseConfig parses a JSON configuration from the provided reader.
+// It returns the configuration or an error if the content is invalid.
+func ParseConfig(r io.Reader) (*domain.Config, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
 	k := koanf.New(".")
-	if err := k.Load(file.Provider(configPath), kjson.Parser()); err != nil {
+	if err := k.Load(rawbytes.Provider(b), kjson.Parser()); err != nil {
 		return nil, err
 	}
 
@@ -57,14 +229,157 @@ func LoadConfig() (*domain.Config, error) {
 }
 
 // WriteConfig writes the provided configuration to the default config path.
-// It creates the necessary directories if they do not exist.
+// It creates the necessary directories if they do not exist, and holds an
+// advisory lock on the config for the duration of the write.
 func WriteConfig(cfg *domain.Config) error {
-	if !homeDirExists() {
-		return ErrHomeDirNotFound
+	configPath, err := ResolvePath("")
+	if err != nil {
+		return err
+	}
+	return WriteConfigTo(cfg, configPath)
+}
+
+// WriteConfigTo writes the provided configuration to the given path.
+// It creates the necessary directories if they do not exist, and holds an
+// advisory lock on the config for the duration of the write.
+func WriteConfigTo(cfg *domain.Config, configPath string) error {
+	unlock, err := lockConfig(configPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writeConfig(cfg, configPath)
+}
+
+// UpdateConfig performs a locked read-modify-write of the configuration at the default path.
+// See UpdateConfigAt.
+func UpdateConfig(fn func(cfg *domain.Config) error) error {
+	configPath, err := ResolvePath("")
+	if err != nil {
+		return err
+	}
+	return UpdateConfigAt(configPath, fn)
+}
+
+// UpdateConfigAt performs a locked read-modify-write of the configuration at the given path.
+// It loads the current configuration (or an empty one if no config file exists),
+// passes it to fn, and writes the result back if fn succeeds. The advisory lock
+// is held for the whole operation so concurrent ghc processes don't clobber
+// each other's changes. See ApplyConfigAt.
+func UpdateConfigAt(configPath string, fn func(cfg *domain.Config) error) error {
+	_, err := ApplyConfigAt(configPath, fn)
+	return err
+}
+
+// ApplyConfigAt performs a locked read-modify-write of the configuration at the
+// given path, like UpdateConfigAt, and reports whether the file changed. If the
+// result encodes to exactly the bytes already in the file, nothing is written,
+// so redundant updates don't touch the file at all.
+func ApplyConfigAt(configPath string, fn func(cfg *domain.Config) error) (bool, error) {
+	unlock, err := lockConfig(configPath)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	// keep the current bytes, to tell whether anything changed
+	before, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
 	}
-	// Expand the default config path to the user's home directory
-	configPath := utils.ExpandPath(defaultConfigPath)
 
+	var cfg *domain.Config
+	if before == nil {
+		// start from an empty config
+		cfg = &domain.Config{
+			Organizations: []*domain.Organization{},
+		}
+	} else {
+		cfg, err = ParseConfig(bytes.NewReader(before))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err := fn(cfg); err != nil {
+		return false, err
+	}
+
+	after, err := Marshal(cfg)
+	if err != nil {
+		return false, err
+	}
+	if before != nil && bytes.Equal(before, after) {
+		return false, nil
+	}
+
+	return true, writeConfigData(after, configPath)
+}
+
+// PlanConfigAt applies fn to the configuration at the given path without writing
+// anything, and returns what would change, as computed by domain.Plan. A missing
+// file is treated as an empty configuration, as it is by ApplyConfigAt.
+func PlanConfigAt(configPath string, fn func(cfg *domain.Config) error) ([]domain.Change, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	before := &domain.Config{Organizations: []*domain.Organization{}}
+	if data != nil {
+		before, err = ParseConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+	}
+	// fn changes a copy, so it can't touch the "before" configuration
+	after := before.Clone()
+
+	if err := fn(after); err != nil {
+		return nil, err
+	}
+	return domain.Plan(before, after), nil
+}
+
+// DryRunConfigAt applies fn to the configuration at the given path without
+// writing anything, and returns the configuration encoded exactly as
+// ApplyConfigAt would write it. A missing file is treated as an empty
+// configuration, as it is by ApplyConfigAt.
+func DryRunConfigAt(configPath string, fn func(cfg *domain.Config) error) ([]byte, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	cfg := &domain.Config{Organizations: []*domain.Organization{}}
+	if data != nil {
+		cfg, err = ParseConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fn(cfg); err != nil {
+		return nil, err
+	}
+	return Marshal(cfg)
+}
+
+// writeConfig writes the configuration without taking the config lock.
+// Callers must hold the lock from lockConfig.
+func writeConfig(cfg *domain.Config, configPath string) error {
+	// Encode the config to its canonical JSON form
+	data, err := Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return writeConfigData(data, configPath)
+}
+
+// writeConfigData writes encoded configuration data without taking the config lock.
+// Callers must hold the lock from lockConfig.
+func writeConfigData(data []byte, configPath string) error {
 	// ensure the config directory exists
 	dir := filepath.Dir(configPath)
 	if err := os.MkdirAll(dir, 0700); err != nil {
@@ -78,14 +393,196 @@ func WriteConfig(cfg *domain.Config) error {
 	}
 	defer file.Close()
 
-	// Encode the config to JSON and write it to the file
-	encoder := json.NewEncoder(file)
+	_, err = file.Write(data)
+	return err
+}
+
+// Migrate moves the configuration file at from to to, validating it on the way,
+// and reports whether anything was moved. If keep is set, the file at from is
+// copied instead and left in place. It is a no-op if from and to are the same
+// path. The file at from is written to to unchanged, holding the lock at to.
+// It returns an error wrapping ErrConfigNotFound if there is no file at from,
+// ErrConfigExists if there already is one at to, or the validation error if
+// the configuration is invalid, in which case nothing is written.
+func Migrate(from, to string, keep bool) (bool, error) {
+	if filepath.Clean(from) == filepath.Clean(to) {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(from)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("%w: %s", ErrConfigNotFound, from)
+	} else if err != nil {
+		return false, err
+	}
+	cfg, err := ParseConfig(bytes.NewReader(data))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", from, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return false, fmt.Errorf("%s: %w", from, err)
+	}
+
+	unlock, err := lockConfig(to)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(to); err == nil {
+		return false, fmt.Errorf("%w: %s", ErrConfigExists, to)
+	}
+	if err := writeConfigData(data, to); err != nil {
+		return false, err
+	}
+	if !keep {
+		if err := os.Remove(from); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Marshal encodes the configuration in its canonical, diff-friendly form:
+// organizations sorted by name, fields in a fixed order, two-space indentation
+// and a trailing newline. Semantically equal configurations always encode to
+// identical bytes. If the organization order was set by hand (ManualOrder),
+// that order is kept instead of sorting. The provided configuration is not modified.
+func Marshal(cfg *domain.Config) ([]byte, error) {
+	// sort a copy, so the caller's ordering is left alone
+	sorted := *cfg
+	if !cfg.ManualOrder {
+		sorted.Organizations = slices.Clone(cfg.Organizations)
+		slices.SortStableFunc(sorted.Organizations, func(a, b *domain.Organization) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(cfg); err != nil {
-		return err
+	if err := encoder.Encode(&sorted); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return buf.Bytes(), nil
+}
+
+// WriteManagedKey writes key material to a file managed by ghc, next to the
+// configuration file at the default path. See WriteManagedKeyAt.
+func WriteManagedKey(name string, data []byte) (string, error) {
+	configPath, err := ResolvePath("")
+	if err != nil {
+		return "", err
+	}
+	return WriteManagedKeyAt(configPath, name, data)
+}
+
+// WriteManagedKeyAt writes key material to a file managed by ghc, in a "keys"
+// directory next to the configuration file at configPath. The file is created
+// with 0600 permissions, and its path is returned so it can be stored in the config.
+func WriteManagedKeyAt(configPath, name string, data []byte) (string, error) {
+	// ensure the keys directory exists
+	keysDir := filepath.Join(filepath.Dir(configPath), "keys")
+	if err := os.MkdirAll(keysDir, 0700); err != nil {
+		return "", err
+	}
+
+	keyPath := filepath.Join(keysDir, filepath.Base(name))
+	if err := os.WriteFile(keyPath, data, 0600); err != nil {
+		return "", err
+	}
+
+	// WriteFile doesn't change the mode of an existing file, so enforce it
+	if err := os.Chmod(keyPath, 0600); err != nil {
+		return "", err
+	}
+
+	return keyPath, nil
+}
+
+// StagedKey is key material waiting in a temporary file in ghc's "keys"
+// directory, so it can be checked, and the change confirmed, before it
+// replaces anything at its managed path. See StageManagedKeyAt.
+type StagedKey struct {
+	// Path is the managed path the key is moved to by Commit.
+	Path string
+	// TempPath is the temporary file holding the key until then.
+	TempPath string
+}
+
+// StageManagedKeyAt writes key material to a temporary file with 0600
+// permissions in the "keys" directory next to the configuration file at
+// configPath, leaving the managed file for name untouched until Commit.
+// The temporary file ends in name, so a ".pub" extension is kept.
+func StageManagedKeyAt(configPath, name string, data []byte) (*StagedKey, error) {
+	// ensure the keys directory exists
+	keysDir := filepath.Join(filepath.Dir(configPath), "keys")
+	if err := os.MkdirAll(keysDir, 0700); err != nil {
+		return nil, err
+	}
+
+	// CreateTemp creates the file with 0600 permissions
+	f, err := os.CreateTemp(keysDir, ".tmp-*-"+filepath.Base(name))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &StagedKey{Path: filepath.Join(keysDir, filepath.Base(name)), TempPath: f.Name()}, nil
+}
+
+// Replaces reports whether a different key is already at the managed path,
+// which Commit overwrites.
+func (k *StagedKey) Replaces() bool {
+	old, err := os.ReadFile(k.Path)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(k.TempPath)
+	return err == nil && !bytes.Equal(old, data)
+}
+
+// Commit moves the key to its managed path, replacing any key there.
+func (k *StagedKey) Commit() error {
+	return os.Rename(k.TempPath, k.Path)
+}
+
+// Discard removes the temporary file, if the key wasn't committed.
+func (k *StagedKey) Discard() {
+	os.Remove(k.TempPath)
+}
+
+// lockConfig takes an exclusive advisory lock on a lock file next to the config file.
+// It returns a function that releases the lock.
+func lockConfig(configPath string) (func(), error) {
+	// ensure the config directory exists so the lock file can be created
+	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+		return nil, err
+	}
+
+	lock, err := os.OpenFile(configPath+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	return func() {
+		unlockFile(lock)
+		lock.Close()
+	}, nil
 }
 
 // SetDefaultConfigPath sets the default configuration path for testing purposes.
@@ -1,11 +1,18 @@
 package configfile
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"ghc/internal/domain"
+	"ghc/internal/utils"
 )
 
 func TestLoadConfig_FileNotFound(t *testing.T) {
@@ -92,6 +99,49 @@ func TestLoadConfig_Success(t *testing.T) {
 	}
 }
 
+func TestSnapshot(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	SetDefaultConfigPath(configPath)
+
+	cfg := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "org1", SSHKeyPath: "/path/to/key", IsDefault: true, Keys: map[string]string{"read": "/path/to/read"}},
+		},
+	}
+	if err := WriteConfig(cfg); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	snap, err := Snapshot()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if !snap.Equal(cfg) {
+		t.Fatalf("expected the snapshot to match the written config, got %+v", snap)
+	}
+
+	// change everything an embedder could reach
+	snap.Organizations[0].Name = "changed"
+	snap.Organizations[0].Keys["read"] = "/changed"
+	snap.Organizations = append(snap.Organizations, &domain.Organization{Name: "org2"})
+	snap.Proxy = "socks5://127.0.0.1:1080"
+
+	for _, load := range []func() (*domain.Config, error){LoadConfig, Snapshot} {
+		loaded, err := load()
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		if !loaded.Equal(cfg) {
+			t.Errorf("expected changes to the snapshot to be ignored, got %+v", loaded)
+		}
+	}
+
+	SetDefaultConfigPath(filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := Snapshot(); !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("expected %v, got %v", ErrConfigNotFound, err)
+	}
+}
+
 func TestWriteConfig_MkdirAllError(t *testing.T) {
 	// Set an invalid directory path to simulate MkdirAll error
 	SetDefaultConfigPath("/invalid/path/to/config.json")
@@ -151,3 +201,706 @@ func TestWriteConfig_EncoderError(t *testing.T) {
 		t.Errorf("expected error, got nil")
 	}
 }
+
+func TestUpdateConfig_Concurrent(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	SetDefaultConfigPath(configPath)
+
+	// run several read-modify-writes at once
+	const nsets = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, nsets)
+	for i := range nsets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- UpdateConfig(func(cfg *domain.Config) error {
+				return cfg.SetOrganization(fmt.Sprintf("org%d", i), privateKey, false)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+
+	// every org must have made it into the final config
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if len(cfg.Organizations) != nsets {
+		t.Errorf("expected %d organizations, got %d", nsets, len(cfg.Organizations))
+	}
+}
+
+func TestUpdateConfigAt_ConcurrentPaths(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	dir := t.TempDir()
+
+	// the default path must never be touched when explicit paths are used
+	defaultPath := filepath.Join(dir, "default.json")
+	SetDefaultConfigPath(defaultPath)
+
+	// update several configs at once, each through its own path
+	const nconfigs = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, nconfigs)
+	for i := range nconfigs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			configPath := filepath.Join(dir, fmt.Sprintf("config%d.json", i))
+			errs <- UpdateConfigAt(configPath, func(cfg *domain.Config) error {
+				return cfg.SetOrganization(fmt.Sprintf("org%d", i), privateKey, false)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+
+	// each config must hold only its own org
+	for i := range nconfigs {
+		cfg, err := LoadConfigFrom(filepath.Join(dir, fmt.Sprintf("config%d.json", i)))
+		if err != nil {
+			t.Fatalf("failed to load config %d: %v", i, err)
+		}
+		if len(cfg.Organizations) != 1 || cfg.Organizations[0].Name != fmt.Sprintf("org%d", i) {
+			t.Errorf("expected only org%d in config %d, got %v", i, i, cfg.OrganizationNames())
+		}
+	}
+	if _, err := os.Stat(defaultPath); !os.IsNotExist(err) {
+		t.Errorf("expected the default config not to be written, got %v", err)
+	}
+}
+
+func TestApplyConfigAt_Unchanged(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	setOrg := func(cfg *domain.Config) error {
+		return cfg.SetOrganization("org1", privateKey, true)
+	}
+
+	// the first update creates the file
+	changed, err := ApplyConfigAt(configPath, setOrg)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if !changed {
+		t.Errorf("expected the first update to change the config")
+	}
+
+	// backdate the file, so a rewrite would be visible
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(configPath, old, old); err != nil {
+		t.Fatalf("failed to set file times: %v", err)
+	}
+
+	// a redundant update must leave the file alone
+	changed, err = ApplyConfigAt(configPath, setOrg)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if changed {
+		t.Errorf("expected a redundant update not to change the config")
+	}
+	info, err := os.Stat(configPath)
+	if err != nil {
+		t.Fatalf("failed to stat config: %v", err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("expected mtime %v, got %v", old, info.ModTime())
+	}
+}
+
+func TestDryRunConfigAt(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	setOrg := func(cfg *domain.Config) error {
+		return cfg.SetOrganization("org1", privateKey, true)
+	}
+
+	// a missing file is dry-run as an empty config, and not created
+	data, err := DryRunConfigAt(configPath, setOrg)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if _, err := os.Stat(configPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no config file, got %v", err)
+	}
+
+	// the output matches what a real update writes
+	if err := UpdateConfigAt(configPath, setOrg); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	written, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if !bytes.Equal(data, written) {
+		t.Errorf("expected %s, got %s", written, data)
+	}
+
+	// errors from fn are returned
+	fnErr := errors.New("fn failed")
+	if _, err := DryRunConfigAt(configPath, func(*domain.Config) error { return fnErr }); !errors.Is(err, fnErr) {
+		t.Errorf("expected %v, got %v", fnErr, err)
+	}
+}
+
+func TestSetOrg_NoWrite(t *testing.T) {
+	key1, _ := utils.GenerateTestSSHKey(t)
+	key2, _ := utils.GenerateTestSSHKey(t)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+
+	// batch several sets without writing anything
+	cfg, err := SetOrg(configPath, "org1", key1, SetOrgOptions{Default: true, NoWrite: true})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	for _, set := range []struct{ name, key string }{{"org2", key2}, {"org1", key2}} {
+		cfg, err = SetOrg(configPath, set.name, set.key, SetOrgOptions{Config: cfg, NoWrite: true})
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+	if _, err := os.Stat(configPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no config file, got %v", err)
+	}
+
+	// an invalid set fails without touching the batch
+	if _, err := SetOrg(configPath, "org3", "/nonexistent/key", SetOrgOptions{Config: cfg, NoWrite: true}); err == nil {
+		t.Fatal("expected an error for a missing key")
+	}
+
+	// writing once yields the batched config
+	if err := WriteConfigTo(cfg, configPath); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	got, err := LoadConfigFrom(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	want := &domain.Config{Organizations: []*domain.Organization{
+		{Name: "org1", SSHKeyPath: key2, IsDefault: true},
+		{Name: "org2", SSHKeyPath: key2},
+	}}
+	if !got.Equal(want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	// without NoWrite, the set is written straight away
+	if _, err := SetOrg(configPath, "org3", key1, SetOrgOptions{}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	got, err = LoadConfigFrom(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if _, err := got.GetOrganization("org3"); err != nil {
+		t.Errorf("expected org3 to be written, got %v", err)
+	}
+}
+
+func TestSetOrg_FailureLeavesConfig(t *testing.T) {
+	key1, _ := utils.GenerateTestSSHKey(t)
+	key2, _ := utils.GenerateTestSSHKey(t)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+
+	tests := []struct {
+		name string
+		org  string
+		opts SetOrgOptions
+	}{
+		{name: "existing default", org: "org1", opts: SetOrgOptions{Default: true}},
+		{name: "new default", org: "org3", opts: SetOrgOptions{Default: true}},
+		{name: "existing", org: "org2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &domain.Config{Organizations: []*domain.Organization{
+				{Name: "org1", SSHKeyPath: key1, IsDefault: true},
+				{Name: "org2", SSHKeyPath: key2},
+			}}
+			want := cfg.Clone()
+
+			tt.opts.Config = cfg
+			tt.opts.NoWrite = true
+			if _, err := SetOrg(configPath, tt.org, "/nonexistent/key", tt.opts); err == nil {
+				t.Fatal("expected an error for a missing key")
+			}
+			if !cfg.Equal(want) {
+				t.Errorf("expected the config to be unchanged, got %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestSetOrg_EnforceDefault(t *testing.T) {
+	key, _ := utils.GenerateTestSSHKey(t)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+
+	cfg := &domain.Config{EnforceDefault: true, Organizations: []*domain.Organization{}}
+	if _, err := SetOrg(configPath, "org1", key, SetOrgOptions{Config: cfg, NoWrite: true}); !errors.Is(err, domain.ErrDefaultRequired) {
+		t.Fatalf("expected %v, got %v", domain.ErrDefaultRequired, err)
+	}
+	if len(cfg.Organizations) != 0 {
+		t.Errorf("expected the config to be unchanged, got %+v", cfg.Organizations)
+	}
+	if _, err := SetOrg(configPath, "org1", key, SetOrgOptions{Config: cfg, NoWrite: true, Default: true}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+}
+
+func TestLoadConfigChecked(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       os.FileMode
+		strict     bool
+		expectErr  error
+		expectWarn bool
+	}{
+		{name: "private", mode: 0600, strict: true},
+		{name: "world readable warns", mode: 0644, expectWarn: true},
+		{name: "world readable strict", mode: 0644, strict: true, expectErr: ErrConfigPermissions},
+		{name: "group readable strict", mode: 0640, strict: true, expectErr: ErrConfigPermissions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			utils.WriteConfigFileForTest(t, configPath, []byte(`{"organizations":[{"name":"org1","ssh_key_path":"/path/to/key","is_default":true}]}`))
+			if err := os.Chmod(configPath, tt.mode); err != nil {
+				t.Fatalf("failed to set config permissions: %v", err)
+			}
+
+			var warn bytes.Buffer
+			cfg, err := LoadConfigChecked(configPath, tt.strict, &warn)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr == nil && len(cfg.Organizations) != 1 {
+				t.Errorf("expected the config to load, got %+v", cfg)
+			}
+			warned := strings.Contains(warn.String(), "WARNING: "+ErrConfigPermissions.Error())
+			if warned != tt.expectWarn {
+				t.Errorf("expected warning %v, got %q", tt.expectWarn, warn.String())
+			}
+		})
+	}
+}
+
+func TestLoadConfigChecked_MultipleDefaults(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		expectWarn string
+		expectOrg  string
+	}{
+		{
+			name:      "single default",
+			config:    `{"organizations":[{"name":"org1","ssh_key_path":"/path/to/key1","is_default":true},{"name":"org2","ssh_key_path":"/path/to/key2"}]}`,
+			expectOrg: "org1",
+		},
+		{
+			name:       "two defaults",
+			config:     `{"organizations":[{"name":"org1","ssh_key_path":"/path/to/key1","is_default":true},{"name":"org2","ssh_key_path":"/path/to/key2","is_default":true}]}`,
+			expectWarn: "WARNING: " + domain.ErrMultipleDefaults.Error() + ": org1, org2; using org1\n",
+			expectOrg:  "org1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			utils.WriteConfigFileForTest(t, configPath, []byte(tt.config))
+			if err := os.Chmod(configPath, 0600); err != nil {
+				t.Fatalf("failed to set config permissions: %v", err)
+			}
+
+			// the config still loads, with the first default in effect
+			var warn bytes.Buffer
+			cfg, err := LoadConfigChecked(configPath, true, &warn)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if warn.String() != tt.expectWarn {
+				t.Errorf("expected warning %q, got %q", tt.expectWarn, warn.String())
+			}
+			def, err := cfg.DefaultOrg()
+			if err != nil || def.Name != tt.expectOrg {
+				t.Errorf("expected default %s, got %v (%v)", tt.expectOrg, def, err)
+			}
+		})
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	SetDefaultConfigPath("/default/config.json")
+
+	tests := []struct {
+		name     string
+		override string
+		expected string
+	}{
+		{name: "default", override: "", expected: "/default/config.json"},
+		{name: "override", override: "/custom/ghc.conf", expected: "/custom/ghc.conf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolvePath(tt.override)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOrgKeyEnv(t *testing.T) {
+	tests := map[string]string{
+		"work":    "GHC_ORG_WORK_KEY",
+		"my-org":  "GHC_ORG_MY_ORG_KEY",
+		"default": "GHC_ORG_DEFAULT_KEY",
+	}
+	for name, expected := range tests {
+		if got := OrgKeyEnv(name); got != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, got)
+		}
+	}
+}
+
+func TestLoadConfigFrom_OrgKeyEnv(t *testing.T) {
+	fileKey, _ := utils.GenerateTestSSHKey(t)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	if err := UpdateConfigAt(configPath, func(cfg *domain.Config) error {
+		if err := cfg.SetOrganization("work", fileKey, true); err != nil {
+			return err
+		}
+		return cfg.SetOrganization("other", fileKey, false)
+	}); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("GHC_ORG_WORK_KEY", "/tmp/ci_key")
+
+	cfg, err := LoadConfigFrom(configPath)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	// the env override wins over the file value, for that organization only
+	if keyPath, err := cfg.GetKeyPathForOrg("work", ""); err != nil || keyPath != "/tmp/ci_key" {
+		t.Errorf("expected /tmp/ci_key, got %q (%v)", keyPath, err)
+	}
+	if keyPath, err := cfg.GetKeyPathForOrg("other", ""); err != nil || keyPath != fileKey {
+		t.Errorf("expected %s, got %q (%v)", fileKey, keyPath, err)
+	}
+
+	// the override is never saved
+	data, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if bytes.Contains(data, []byte("ci_key")) {
+		t.Errorf("expected the override not to be saved, got %s", data)
+	}
+}
+
+func TestResolveProfilePath(t *testing.T) {
+	SetDefaultConfigPath("/default/ghc.conf")
+
+	tests := []struct {
+		name      string
+		override  string
+		profile   string
+		expected  string
+		expectErr error
+	}{
+		{name: "no profile", expected: "/default/ghc.conf"},
+		{name: "no profile with override", override: "/custom/ghc.conf", expected: "/custom/ghc.conf"},
+		{name: "profile", profile: "work", expected: "/default/work.conf"},
+		{name: "profile with dots and dashes", profile: "client-a.prod", expected: "/default/client-a.prod.conf"},
+		{name: "profile with override", override: "/custom/ghc.conf", profile: "work", expectErr: ErrProfileWithConfig},
+		{name: "profile with a slash", profile: "../work", expectErr: ErrInvalidProfile},
+		{name: "hidden profile", profile: ".work", expectErr: ErrInvalidProfile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveProfilePath(tt.override, tt.profile)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		expectErr bool
+		expectLen int
+	}{
+		{
+			name:      "valid JSON",
+			content:   `{"organizations":[{"name":"org1","ssh_key_path":"/path/to/key","is_default":true}]}`,
+			expectLen: 1,
+		},
+		{
+			name:      "invalid JSON",
+			content:   "invalid json",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseConfig(strings.NewReader(tt.content))
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if len(cfg.Organizations) != tt.expectLen {
+				t.Errorf("expected %d organizations, got %d", tt.expectLen, len(cfg.Organizations))
+			}
+		})
+	}
+}
+
+func TestMarshal_Canonical(t *testing.T) {
+	a := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "org2", SSHKeyPath: "/path/to/key2"},
+			{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true},
+		},
+	}
+	b := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true},
+			{Name: "org2", SSHKeyPath: "/path/to/key2"},
+		},
+	}
+
+	aBytes, err := Marshal(a)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	bBytes, err := Marshal(b)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+
+	if string(aBytes) != string(bBytes) {
+		t.Errorf("expected identical output, got:\n%s\nand:\n%s", aBytes, bBytes)
+	}
+
+	// the caller's ordering must be left alone
+	if a.Organizations[0].Name != "org2" {
+		t.Errorf("expected input config to be unmodified, got %s first", a.Organizations[0].Name)
+	}
+}
+
+func TestWriteConfig_Canonical(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	SetDefaultConfigPath(configPath)
+
+	cfg := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "org2", SSHKeyPath: "/path/to/key2"},
+			{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true},
+		},
+	}
+	if err := WriteConfig(cfg); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	expected := `{
+  "organizations": [
+    {
+      "name": "org1",
+      "ssh_key_path": "/path/to/key1",
+      "is_default": true
+    },
+    {
+      "name": "org2",
+      "ssh_key_path": "/path/to/key2",
+      "is_default": false
+    }
+  ]
+}
+`
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
+
+func TestMarshal_ManualOrder(t *testing.T) {
+	cfg := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "org2", SSHKeyPath: "/path/to/key2"},
+			{Name: "org1", SSHKeyPath: "/path/to/key1"},
+		},
+		ManualOrder: true,
+	}
+
+	data, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+
+	loaded, err := ParseConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if loaded.Organizations[0].Name != "org2" || !loaded.ManualOrder {
+		t.Errorf("expected manual order to be preserved, got:\n%s", data)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	valid := fmt.Sprintf(`{"organizations":[{"name":"org1","ssh_key_path":%q,"is_default":true}]}`, privateKey)
+
+	tests := []struct {
+		name         string
+		legacy       string // legacy file content, none if empty
+		existing     bool   // whether a config already exists at the new path
+		samePath     bool
+		keep         bool
+		expectErr    error
+		expectMoved  bool
+		expectLegacy bool // whether the legacy file is still there afterwards
+	}{
+		{name: "move", legacy: valid, expectMoved: true},
+		{name: "copy", legacy: valid, keep: true, expectMoved: true, expectLegacy: true},
+		{name: "already at the new path", legacy: valid, samePath: true, expectLegacy: true},
+		{name: "no legacy config", expectErr: ErrConfigNotFound},
+		{name: "new path taken", legacy: valid, existing: true, expectErr: ErrConfigExists, expectLegacy: true},
+		{name: "invalid config", legacy: `{"organizations":[{"name":"org1","ssh_key_path":"/does/not/exist"}]}`, expectErr: os.ErrNotExist, expectLegacy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			from := filepath.Join(dir, "old", "ghc.conf")
+			to := filepath.Join(dir, "new", "ghc", "config.json")
+			if tt.samePath {
+				to = from
+			}
+			if tt.legacy != "" {
+				if err := os.MkdirAll(filepath.Dir(from), 0700); err != nil {
+					t.Fatal(err)
+				}
+				utils.WriteConfigFileForTest(t, from, []byte(tt.legacy))
+			}
+			if tt.existing {
+				if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+					t.Fatal(err)
+				}
+				utils.WriteConfigFileForTest(t, to, []byte("{}"))
+			}
+
+			moved, err := Migrate(from, to, tt.keep)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if moved != tt.expectMoved {
+				t.Errorf("expected moved %v, got %v", tt.expectMoved, moved)
+			}
+
+			if _, err := os.Stat(from); (err == nil) != tt.expectLegacy {
+				t.Errorf("expected legacy file kept to be %v, got %v", tt.expectLegacy, err)
+			}
+			if tt.expectMoved {
+				data, err := os.ReadFile(to)
+				if err != nil {
+					t.Fatalf("expected the config at %s: %v", to, err)
+				}
+				if string(data) != tt.legacy {
+					t.Errorf("expected the config to be unchanged, got %s", data)
+				}
+			} else if !tt.existing && !tt.samePath {
+				if _, err := os.Stat(to); err == nil {
+					t.Errorf("expected nothing to be written to %s", to)
+				}
+			}
+		})
+	}
+}
+
+func TestStageManagedKeyAt(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	keyPath, err := WriteManagedKeyAt(configPath, "org1", []byte("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := StageManagedKeyAt(configPath, "org1", []byte("new"))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if staged.Path != keyPath {
+		t.Errorf("expected path %s, got %s", keyPath, staged.Path)
+	}
+	if info, err := os.Stat(staged.TempPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected a 0600 staged key, got %v, %v", info, err)
+	}
+	if !staged.Replaces() {
+		t.Error("expected the staged key to replace the old one")
+	}
+	// the managed key is untouched until Commit
+	if data, _ := os.ReadFile(keyPath); string(data) != "old" {
+		t.Errorf("expected the old key, got %q", data)
+	}
+
+	if err := staged.Commit(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	staged.Discard()
+	if data, _ := os.ReadFile(keyPath); string(data) != "new" {
+		t.Errorf("expected the new key, got %q", data)
+	}
+	if _, err := os.Stat(staged.TempPath); !os.IsNotExist(err) {
+		t.Errorf("expected the staged key to be gone, got %v", err)
+	}
+
+	// the same content replaces nothing, and a discarded key is removed
+	staged, err = StageManagedKeyAt(configPath, "org1", []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if staged.Replaces() {
+		t.Error("expected the same content to replace nothing")
+	}
+	staged.Discard()
+	if _, err := os.Stat(staged.TempPath); !os.IsNotExist(err) {
+		t.Errorf("expected the staged key to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package configfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive advisory lock on the given file, blocking until
+// the lock is available. If the filesystem does not support locking, the file
+// is left unlocked rather than failing the operation.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	if errors.Is(err, syscall.ENOLCK) || errors.Is(err, syscall.ENOTSUP) {
+		// graceful fallback for filesystems without flock support (e.g. some NFS mounts)
+		return nil
+	}
+	return err
+}
+
+// unlockFile releases an advisory lock taken by lockFile.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,82 @@
+package configfile
+
+import (
+	"errors"
+
+	"ghc/internal/domain"
+)
+
+// SetOrgOptions are the options of SetOrg.
+type SetOrgOptions struct {
+	// Default makes the organization the default.
+	Default bool
+	// Config is the configuration to change. If nil, the configuration at the
+	// path is loaded, or an empty one if there is none.
+	Config *domain.Config
+	// NoWrite returns the changed configuration without writing it, like
+	// "org set --dry-run", so several changes can be batched and written once
+	// with WriteConfigTo.
+	NoWrite bool
+}
+
+// SetOrg adds or updates an organization with the SSH key at sshKeyPath, and
+// returns the changed configuration. Unless opts.NoWrite is set, the result is
+// written to configPath. Without opts.Config, that's a locked read-modify-write,
+// as with UpdateConfigAt. Otherwise opts.Config is only changed if the set
+// succeeds.
+//
+// It is narrower than "ghc org set": the key and name are validated, and a
+// config that enforces a default must keep one, but none of the command's
+// other options, such as --key-type, --replace or --expire, are applied.
+//
+// Returns an error if the configuration can't be read or written, or the
+// organization is invalid, in which case nothing is written.
+func SetOrg(configPath, name, sshKeyPath string, opts SetOrgOptions) (*domain.Config, error) {
+	if opts.Config == nil && !opts.NoWrite {
+		var result *domain.Config
+		err := UpdateConfigAt(configPath, func(cfg *domain.Config) error {
+			result = cfg
+			return setOrg(cfg, name, sshKeyPath, opts.Default)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// change a copy of the caller's config, so a failed set leaves it untouched
+	var cfg *domain.Config
+	if opts.Config != nil {
+		cfg = opts.Config.Clone()
+	} else {
+		var err error
+		cfg, err = LoadConfigFrom(configPath)
+		if errors.Is(err, ErrConfigNotFound) {
+			cfg = &domain.Config{Organizations: []*domain.Organization{}}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if err := setOrg(cfg, name, sshKeyPath, opts.Default); err != nil {
+		return nil, err
+	}
+	if !opts.NoWrite {
+		if err := WriteConfigTo(cfg, configPath); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Config == nil {
+		return cfg, nil
+	}
+	*opts.Config = *cfg
+	return opts.Config, nil
+}
+
+// setOrg sets the organization in cfg, which must still have a default
+// afterwards if it enforces one.
+func setOrg(cfg *domain.Config, name, sshKeyPath string, isDefault bool) error {
+	if err := cfg.SetOrganization(name, sshKeyPath, isDefault); err != nil {
+		return err
+	}
+	return cfg.CheckEnforceDefault()
+}
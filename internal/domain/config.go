@@ -5,38 +5,209 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"ghc/internal/sshkey"
+	"ghc/internal/utils"
+
+	"golang.org/x/crypto/ssh"
 )
 
 var (
 	ErrNoDefaultOrg = errors.New("no default organization found")
 )
 
+// now returns the current time, and can be replaced in tests.
+var now = time.Now
+
 // Config holds the configuration details for the application.
 // It contains a list of organizations and their associated SSH keys.
 type Config struct {
-	Organizations []*Organization `json:"organizations" koanf:"organizations"` // List of organizations and their SSH keys
+	Organizations     []*Organization `json:"organizations" koanf:"organizations"`                       // List of organizations and their SSH keys
+	ManualOrder       bool            `json:"manual_order,omitempty" koanf:"manual_order"`               // Indicates the organization order was set by hand and must be preserved
+	Proxy             string          `json:"proxy,omitempty" koanf:"proxy"`                             // Default proxy to clone through, as socks5://host:port or http://host:port
+	MinRSABits        int             `json:"min_rsa_bits,omitempty" koanf:"min_rsa_bits"`               // Minimum size of RSA keys, in bits; 0 allows any size
+	PostClone         string          `json:"post_clone,omitempty" koanf:"post_clone"`                   // Command run in each cloned repository, unless the organization has its own
+	SSHConfigTemplate string          `json:"ssh_config_template,omitempty" koanf:"ssh_config_template"` // Path to a text/template rendering the generated SSH config, instead of the built-in one
+	EnforceDefault    bool            `json:"enforce_default,omitempty" koanf:"enforce_default"`         // Refuse changes that leave organizations without a default
+
+	DefaultOverride string `json:"-" koanf:"-"` // Organization to treat as the default for this invocation only; never saved
 }
 
 func (c *Config) JSON() ([]byte, error) {
 	return json.Marshal(c)
 }
 
-func (c *Config) GetKeyPathForOrg(name string) (string, error) {
-	// if the org exists, return the SSH key path
+// Clone returns a deep copy of the configuration, sharing nothing with c,
+// so changes to either never show up in the other.
+func (c *Config) Clone() *Config {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	if c.Organizations != nil {
+		clone.Organizations = make([]*Organization, len(c.Organizations))
+		for i, org := range c.Organizations {
+			clone.Organizations[i] = org.Clone()
+		}
+	}
+	return &clone
+}
+
+// GetOrganization returns the organization with the given name.
+// It returns an ErrOrganizationNotFound error if no organization matches.
+func (c *Config) GetOrganization(name string) (*Organization, error) {
 	for _, org := range c.Organizations {
 		if org.Name == name {
-			return org.SSHKeyPath, nil
+			return org, nil
 		}
 	}
-	// otherwise, return the default SSH key path
+	return nil, fmt.Errorf("%w: %s", ErrOrganizationNotFound, name)
+}
+
+// Equal reports whether two configurations hold the same settings and organizations.
+// Organizations are matched by name, so their order doesn't matter, unless either
+// configuration has ManualOrder set, in which case the order must match too.
+// DefaultOverride is ignored, as it only applies at runtime and is never saved.
+func (c *Config) Equal(other *Config) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if c.ManualOrder != other.ManualOrder || c.Proxy != other.Proxy ||
+		c.MinRSABits != other.MinRSABits || c.PostClone != other.PostClone ||
+		c.SSHConfigTemplate != other.SSHConfigTemplate || c.EnforceDefault != other.EnforceDefault {
+		return false
+	}
+	if len(c.Organizations) != len(other.Organizations) {
+		return false
+	}
+
+	if c.ManualOrder {
+		return slices.EqualFunc(c.Organizations, other.Organizations, (*Organization).Equal)
+	}
+	// compare name-sorted copies, so the order doesn't matter
+	byName := func(a, b *Organization) int { return strings.Compare(a.Name, b.Name) }
+	mine := slices.SortedStableFunc(slices.Values(c.Organizations), byName)
+	theirs := slices.SortedStableFunc(slices.Values(other.Organizations), byName)
+	return slices.EqualFunc(mine, theirs, (*Organization).Equal)
+}
+
+// OrganizationNames returns the names of all configured organizations, sorted.
+// It returns an empty slice if no organizations are configured.
+func (c *Config) OrganizationNames() []string {
+	names := make([]string, 0, len(c.Organizations))
+	for _, org := range c.Organizations {
+		names = append(names, org.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Len returns the number of configured organizations, or 0 for a nil Config.
+func (c *Config) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.Organizations)
+}
+
+// Filter returns the organizations for which pred returns true, in order.
+// The returned slice is newly allocated, so the Config's own slice is never
+// reordered or resized through it; it is nil if nothing matches.
+func (c *Config) Filter(pred func(*Organization) bool) []*Organization {
+	var matched []*Organization
+	for _, org := range c.Organizations {
+		if pred(org) {
+			matched = append(matched, org)
+		}
+	}
+	return matched
+}
+
+// GetKeyPathForOrg returns the SSH key path for the named organization,
+// falling back to the default organization's key if the name is not configured.
+// label selects one of the organization's labeled keys; an empty label selects
+// its primary key.
+// It returns ErrNoDefaultOrg if there is no match and no default, or an error
+// wrapping ErrKeyLabelNotFound if the organization has no key with that label.
+func (c *Config) GetKeyPathForOrg(name, label string) (string, error) {
+	org, _, err := c.ResolveOrganization(name)
+	if err != nil {
+		return "", err
+	}
+	return org.KeyPath(label)
+}
+
+// ResolveOrganization returns the organization to use for the given name.
+// If an organization with the exact name exists it is returned with fallback
+// set to false. Otherwise the default organization is returned with fallback
+// set to true, so callers can tell the user a different identity is in use.
+// It returns ErrNoDefaultOrg if there is no match and no default.
+func (c *Config) ResolveOrganization(name string) (org *Organization, fallback bool, err error) {
+	// if the org exists, return it
+	for _, org := range c.Organizations {
+		if org.Name == name {
+			return org, false, nil
+		}
+	}
+	// otherwise, return the default org
+	def, err := c.DefaultOrg()
+	if err != nil {
+		return nil, false, err
+	}
+	return def, true, nil
+}
+
+// DefaultOrg returns the default organization.
+// If more than one organization is marked as the default, the first one wins,
+// so every caller agrees on which organization is the effective default.
+// If DefaultOverride is set, that organization is returned instead, or
+// ErrDefaultOverrideNotFound if it is not configured.
+// It returns ErrNoDefaultOrg if no organization is marked as the default.
+func (c *Config) DefaultOrg() (*Organization, error) {
+	// a runtime override takes precedence over the persisted default
+	if c.DefaultOverride != "" {
+		for _, org := range c.Organizations {
+			if org.Name == c.DefaultOverride {
+				return org, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", ErrDefaultOverrideNotFound, c.DefaultOverride)
+	}
+
 	for _, org := range c.Organizations {
 		if org.IsDefault {
-			return org.SSHKeyPath, nil
+			return org, nil
 		}
 	}
-	return "", ErrNoDefaultOrg
+	return nil, ErrNoDefaultOrg
+}
+
+// MarkedDefaults returns the names of every organization marked as the default,
+// in order. More than one can only come from a hand-edited file; DefaultOrg
+// then uses the first.
+func (c *Config) MarkedDefaults() []string {
+	var names []string
+	for _, org := range c.Filter(func(o *Organization) bool { return o.IsDefault }) {
+		names = append(names, org.Name)
+	}
+	return names
+}
+
+// CheckEnforceDefault returns an error wrapping ErrDefaultRequired if
+// EnforceDefault is set and there are organizations, but none is marked as the
+// default. Without EnforceDefault, or without organizations, it returns nil.
+func (c *Config) CheckEnforceDefault() error {
+	if !c.EnforceDefault || len(c.Organizations) == 0 || len(c.MarkedDefaults()) > 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: mark one with ghc org set --default", ErrDefaultRequired)
 }
 
 // RemoveOrganization removes an organization from the Config by its name.
@@ -79,23 +250,95 @@ func (c *Config) RemoveOrganization(name string) error {
 	return nil
 }
 
+// MoveDirection is a direction an organization can be moved in the Organizations slice.
+type MoveDirection string
+
+const (
+	MoveUp     MoveDirection = "up"     // one position towards the start
+	MoveDown   MoveDirection = "down"   // one position towards the end
+	MoveTop    MoveDirection = "top"    // to the start
+	MoveBottom MoveDirection = "bottom" // to the end
+)
+
+// MoveOrganization moves an organization within the Organizations slice.
+// Moving an organization past either end of the slice is a no-op rather than
+// an error. Because the order now has meaning, ManualOrder is set so the order
+// is preserved when the configuration is written.
+//
+// Parameters:
+//   - name: The name of the organization to move.
+//   - direction: The direction to move it in.
+//
+// Returns:
+//   - error: ErrOrganizationNotFound if the organization does not exist, or
+//     ErrInvalidMoveDirection if the direction is unknown, otherwise nil.
+func (c *Config) MoveOrganization(name string, direction MoveDirection) error {
+	from := -1
+	for idx, org := range c.Organizations {
+		if org.Name == name {
+			from = idx
+			break
+		}
+	}
+	if from == -1 {
+		return fmt.Errorf("%w: %s", ErrOrganizationNotFound, name)
+	}
+
+	var to int
+	switch direction {
+	case MoveUp:
+		to = max(from-1, 0)
+	case MoveDown:
+		to = min(from+1, len(c.Organizations)-1)
+	case MoveTop:
+		to = 0
+	case MoveBottom:
+		to = len(c.Organizations) - 1
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidMoveDirection, direction)
+	}
+
+	// take the organization out, then insert it at its new position
+	org := c.Organizations[from]
+	c.Organizations = slices.Delete(c.Organizations, from, from+1)
+	c.Organizations = slices.Insert(c.Organizations, to, org)
+	c.ManualOrder = true
+	return nil
+}
+
 // SetOrganization sets or updates an organization in the configuration.
 // If the `isDefault` flag is true, it unsets the default status of all other organizations
 // and sets the specified organization as the default. If the organization already exists,
-// it updates its SSH key path and default status. If it does not exist, it adds a new
+// it updates its SSH key path, clearing any host alias, since cloning would use the
+// alias instead of the key, and makes it the default if `isDefault` is true. A false
+// `isDefault` means "don't change": an existing default organization stays the default,
+// since updating a key should never silently demote it. To move the default, make another
+// organization the default. If the organization does not exist, it adds a new
 // organization with the provided details.
 //
-// If there is only one organization in the configuration after the operation, it is
-// automatically set as the default regardless of the `isDefault` flag.
+// A sole organization is not made the default automatically; use
+// PromoteSoleOrganization for that.
 //
 // Parameters:
 //   - name: The name of the organization.
 //   - sshKeyPath: The file path to the SSH key associated with the organization.
-//   - isDefault: A boolean indicating whether the organization should be set as the default.
+//   - isDefault: A boolean indicating whether the organization should be made the default.
 //
 // Returns:
 //   - error: Returns an error if any issue occurs during the operation, otherwise nil.
 func (c *Config) SetOrganization(name, sshKeyPath string, isDefault bool) error {
+	return c.setOrganization(name, sshKeyPath, isDefault, true)
+}
+
+// SetOrganizationUnchecked works like SetOrganization, but stores the organization
+// without checking its SSH key, which may not be in place yet. The name and any
+// other settings are still validated. Cloning fails until the key is in place.
+func (c *Config) SetOrganizationUnchecked(name, sshKeyPath string, isDefault bool) error {
+	return c.setOrganization(name, sshKeyPath, isDefault, false)
+}
+
+// setOrganization implements SetOrganization, checking the SSH key only if checkKey is set.
+func (c *Config) setOrganization(name, sshKeyPath string, isDefault, checkKey bool) error {
 	// if the default flag is set, unset all other organizations
 	if isDefault {
 		for _, org := range c.Organizations {
@@ -111,13 +354,16 @@ func (c *Config) SetOrganization(name, sshKeyPath string, isDefault bool) error
 	exists := false
 	for _, org := range c.Organizations {
 		if org.Name == name {
-			// update the SSH key path
+			// update the SSH key path, keeping the default status unless it's being set,
+			// and drop any host alias, which would otherwise win over the new key
 			org.SSHKeyPath = sshKeyPath
-			org.IsDefault = isDefault
+			org.HostAlias = ""
+			if isDefault {
+				org.IsDefault = true
+			}
 			exists = true
 			// validate the organization
-			err := org.Validate()
-			if err != nil {
+			if err := c.validateOrganization(org, checkKey); err != nil {
 				return err
 			}
 			break
@@ -133,18 +379,173 @@ func (c *Config) SetOrganization(name, sshKeyPath string, isDefault bool) error
 			IsDefault:  isDefault,
 		}
 		// validate the new organization
-		err := newOrg.Validate()
-		if err != nil {
+		if err := c.validateOrganization(newOrg, checkKey); err != nil {
 			return err
 		}
 		c.Organizations = append(c.Organizations, newOrg)
 	}
 
-	// if there is only one organization, set it as default regardless of the flag
-	if len(c.Organizations) == 1 {
+	return nil
+}
+
+// ResetOrganization clears every setting of the organization with the given
+// name, keeping only its name, place in the list and default status, so that
+// setting it again replaces its definition rather than merging into it. The
+// cleared organization has no key, so it must be set again before the
+// configuration is saved. It reports whether the organization exists.
+func (c *Config) ResetOrganization(name string) bool {
+	for idx, org := range c.Organizations {
+		if org.Name == name {
+			c.Organizations[idx] = &Organization{Name: name, IsDefault: org.IsDefault}
+			return true
+		}
+	}
+	return false
+}
+
+// SetHostAlias adds an organization, or updates an existing one, to clone
+// through a Host alias from the user's own SSH config instead of a generated
+// one, so no SSH key path is needed. isDefault works as for SetOrganization.
+// It returns an error wrapping ErrInvalidHost if the alias is not a plausible host name.
+func (c *Config) SetHostAlias(name, alias string, isDefault bool) error {
+	org, err := c.GetOrganization(name)
+	isNew := errors.Is(err, ErrOrganizationNotFound)
+	if isNew {
+		org = &Organization{Name: name}
+	} else if err != nil {
+		return err
+	}
+
+	// validate a copy, so a bad alias doesn't leave the organization half-updated
+	updated := *org
+	updated.HostAlias = alias
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+
+	if isDefault {
+		for _, other := range c.Organizations {
+			other.IsDefault = false
+		}
+		org.IsDefault = true
+	}
+	org.HostAlias = alias
+	if isNew {
+		c.Organizations = append(c.Organizations, org)
+	}
+	return nil
+}
+
+// PromoteSoleOrganization makes the organization the default if it is the only
+// one in the configuration, and reports whether it did.
+func (c *Config) PromoteSoleOrganization() bool {
+	if len(c.Organizations) != 1 || c.Organizations[0].IsDefault {
+		return false
+	}
+	c.Organizations[0].IsDefault = true
+	return true
+}
+
+// RepairDefault makes sure exactly one organization is marked as the default:
+// with none marked, the first organization becomes the default, and with more
+// than one, only the first of them stays the default, as DefaultOrg already
+// treats it. It returns the name of the default if it changed anything, or ""
+// if there was nothing to repair.
+func (c *Config) RepairDefault() string {
+	if len(c.Organizations) == 0 {
+		return ""
+	}
+	defaults := c.MarkedDefaults()
+	switch len(defaults) {
+	case 0:
 		c.Organizations[0].IsDefault = true
+		return c.Organizations[0].Name
+	case 1:
+		return ""
+	}
+	for _, org := range c.Organizations {
+		org.IsDefault = org.Name == defaults[0]
+	}
+	return defaults[0]
+}
+
+// ReplaceKey updates the SSH key path of an existing organization, leaving
+// everything else about it, including whether it is the default, untouched,
+// except for a host alias, which is cleared since cloning would use it instead
+// of the key. The organization is left unchanged if the new key is invalid.
+// It returns ErrOrganizationNotFound if the organization does not exist.
+func (c *Config) ReplaceKey(name, sshKeyPath string) error {
+	org, err := c.GetOrganization(name)
+	if err != nil {
+		return err
+	}
+
+	// validate a copy, so a bad key doesn't leave the organization half-updated
+	updated := *org
+	updated.SSHKeyPath = sshKeyPath
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+	if err := c.checkKeySize(sshKeyPath); err != nil {
+		return err
+	}
+
+	org.SSHKeyPath = sshKeyPath
+	org.HostAlias = ""
+	return nil
+}
+
+// SetHost sets the SSH host of an existing organization, such as a GitHub
+// Enterprise server. An empty host clears it, so github.com is used again.
+// It returns ErrOrganizationNotFound if the organization does not exist, or an
+// error wrapping ErrInvalidHost if the host is not a plausible hostname.
+func (c *Config) SetHost(name, host string) error {
+	org, err := c.GetOrganization(name)
+	if err != nil {
+		return err
+	}
+	if host != "" {
+		if err := validateHost(host); err != nil {
+			return err
+		}
+	}
+	org.Host = host
+	return nil
+}
+
+// SetExpiry sets when an existing organization expires, after which its keys
+// are no longer used. A nil time clears it, so the organization never expires.
+// It returns ErrOrganizationNotFound if the organization does not exist.
+func (c *Config) SetExpiry(name string, expiresAt *time.Time) error {
+	org, err := c.GetOrganization(name)
+	if err != nil {
+		return err
+	}
+	org.ExpiresAt = expiresAt
+	return nil
+}
+
+// RenameOrganization renames an organization in place, so everything else
+// about it, including whether it is the default, is kept intact.
+// It returns ErrOrganizationNotFound if the organization does not exist,
+// ErrDuplicateOrganization if the new name is already taken, or an error
+// wrapping ErrInvalidOrgName if the new name is invalid.
+func (c *Config) RenameOrganization(oldName, newName string) error {
+	org, err := c.GetOrganization(oldName)
+	if err != nil {
+		return err
+	}
+	if oldName == newName {
+		return nil
+	}
+	if err := validateOrganizationName(newName); err != nil {
+		return err
+	}
+	if _, err := c.GetOrganization(newName); err == nil {
+		return fmt.Errorf("%w: %s", ErrDuplicateOrganization, newName)
 	}
 
+	org.Name = newName
 	return nil
 }
 
@@ -153,6 +554,7 @@ func (c *Config) SetOrganization(name, sshKeyPath string, isDefault bool) error
 //   - There are no duplicate organization names; otherwise, it returns an error
 //     wrapping ErrDuplicateOrganization with the duplicate name.
 //   - Each organization in the Organizations slice is valid by calling its Validate method.
+//   - No organization uses an RSA key smaller than MinRSABits, if it is set.
 //
 // If any validation fails, an appropriate error is returned.
 func (c *Config) Validate() error {
@@ -168,6 +570,42 @@ func (c *Config) Validate() error {
 		if err := org.Validate(); err != nil {
 			return err
 		}
+		if err := c.checkKeySize(org.SSHKeyPath); err != nil {
+			return err
+		}
+		for _, keyPath := range org.Keys {
+			if err := c.checkKeySize(keyPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateOrganization validates org and the size of its SSH key. If checkKey
+// is not set, the key isn't read and only the other settings are validated.
+func (c *Config) validateOrganization(org *Organization, checkKey bool) error {
+	if err := org.validate(checkKey); err != nil {
+		return err
+	}
+	if !checkKey {
+		return nil
+	}
+	return c.checkKeySize(org.SSHKeyPath)
+}
+
+// checkKeySize rejects RSA keys smaller than MinRSABits.
+// Keys of other types, and any key when MinRSABits is not set, are accepted.
+func (c *Config) checkKeySize(sshKeyPath string) error {
+	if c.MinRSABits <= 0 || sshKeyPath == "" {
+		return nil
+	}
+	info, err := sshkey.Inspect(sshKeyPath)
+	if err != nil {
+		return err
+	}
+	if info.Type == "rsa" && info.Bits < c.MinRSABits {
+		return fmt.Errorf("%w: %s is %d bits, the minimum is %d", ErrRSAKeyTooSmall, sshKeyPath, info.Bits, c.MinRSABits)
 	}
 	return nil
 }
@@ -175,46 +613,397 @@ func (c *Config) Validate() error {
 // Organization represents a GitHub organization and its associated SSH key.
 // The IsDefault field indicates if this is the default organization.
 type Organization struct {
-	Name       string `json:"name" koanf:"name"`                 // Name of the organization
-	SSHKeyPath string `json:"ssh_key_path" koanf:"ssh_key_path"` // Path to the SSH key for the organization
-	IsDefault  bool   `json:"is_default" koanf:"is_default"`     // Indicates if this is the default organization
+	Name          string            `json:"name" koanf:"name" yaml:"name"`                                                   // Name of the organization
+	SSHKeyPath    string            `json:"ssh_key_path" koanf:"ssh_key_path" yaml:"ssh_key_path"`                           // Path to the SSH key for the organization
+	Keys          map[string]string `json:"keys,omitempty" koanf:"keys" yaml:"keys,omitempty"`                               // Optional additional SSH key paths, by label, such as "read" and "write"
+	PrimaryKey    string            `json:"primary_key,omitempty" koanf:"primary_key" yaml:"primary_key,omitempty"`          // Optional label of the key in Keys to use by default, instead of SSHKeyPath
+	IsDefault     bool              `json:"is_default" koanf:"is_default" yaml:"is_default"`                                 // Indicates if this is the default organization
+	Host          string            `json:"host,omitempty" koanf:"host" yaml:"host,omitempty"`                               // Optional SSH host, such as a GitHub Enterprise server, used instead of github.com
+	HostAlias     string            `json:"host_alias,omitempty" koanf:"host_alias" yaml:"host_alias,omitempty"`             // Optional Host alias from ~/.ssh/config to clone through, instead of generating an SSH config
+	KnownHosts    string            `json:"known_hosts,omitempty" koanf:"known_hosts" yaml:"known_hosts,omitempty"`          // Optional known_hosts file path, or inline known_hosts content, to pin host keys
+	PostClone     string            `json:"post_clone,omitempty" koanf:"post_clone" yaml:"post_clone,omitempty"`             // Optional command run in each cloned repository, such as "make setup"
+	ExpiresAt     *time.Time        `json:"expires_at,omitempty" koanf:"expires_at" yaml:"expires_at,omitempty"`             // Optional time after which the organization's keys are no longer used, for temporary access
+	IdentityAgent string            `json:"identity_agent,omitempty" koanf:"identity_agent" yaml:"identity_agent,omitempty"` // Optional ssh-agent socket to use instead of SSH_AUTH_SOCK, such as a hardware token's agent
+
+	ConnectTimeout      int `json:"connect_timeout,omitempty" koanf:"connect_timeout" yaml:"connect_timeout,omitempty"`                   // Optional seconds to wait for the SSH connection, instead of the system's TCP timeout
+	ServerAliveInterval int `json:"server_alive_interval,omitempty" koanf:"server_alive_interval" yaml:"server_alive_interval,omitempty"` // Optional seconds between SSH keepalives, so a stalled connection is noticed
+
+	KeyOverride string `json:"-" koanf:"-" yaml:"-"` // Key path to use instead of the primary key for this invocation only; never saved
+}
+
+// Clone returns a deep copy of the organization, including its labeled keys and expiry.
+func (o *Organization) Clone() *Organization {
+	if o == nil {
+		return nil
+	}
+	clone := *o
+	clone.Keys = maps.Clone(o.Keys)
+	if o.ExpiresAt != nil {
+		expiresAt := *o.ExpiresAt
+		clone.ExpiresAt = &expiresAt
+	}
+	return &clone
+}
+
+// KnownHostsInline reports whether KnownHosts holds inline known_hosts content
+// rather than a path. A file that exists is always a path, even with a space
+// in its name, and anything else is only inline content if it parses as a
+// known_hosts entry, so a mistyped path is reported as missing.
+func (o *Organization) KnownHostsInline() bool {
+	if o.KnownHosts == "" {
+		return false
+	}
+	if _, err := os.Stat(utils.ExpandPath(o.KnownHosts)); err == nil {
+		return false
+	}
+	_, _, _, _, _, err := ssh.ParseKnownHosts([]byte(o.KnownHosts))
+	return err == nil
+}
+
+// KeyPath returns the path of the organization's key with the given label.
+// An empty label selects the primary key, which is KeyOverride if it is set,
+// the key labeled PrimaryKey if one is designated, or SSHKeyPath otherwise.
+// It returns an error wrapping ErrOrgExpired if the organization has expired,
+// or ErrKeyLabelNotFound if there is no key with that label.
+func (o *Organization) KeyPath(label string) (string, error) {
+	if err := o.CheckExpiry(); err != nil {
+		return "", err
+	}
+	return o.keyPath(label)
+}
+
+// keyPath implements KeyPath, without checking the expiry.
+func (o *Organization) keyPath(label string) (string, error) {
+	if label == "" && o.KeyOverride != "" {
+		return o.KeyOverride, nil
+	}
+	if label == "" {
+		label = o.PrimaryKey
+	}
+	if label == "" {
+		return o.SSHKeyPath, nil
+	}
+	keyPath, ok := o.Keys[label]
+	if !ok {
+		return "", fmt.Errorf("%w: organization %s has no key labeled %q", ErrKeyLabelNotFound, o.Name, label)
+	}
+	return keyPath, nil
+}
+
+// Expired reports whether the organization has an expiry time that has been reached.
+func (o *Organization) Expired() bool {
+	return o.ExpiresAt != nil && !now().Before(*o.ExpiresAt)
+}
+
+// CheckExpiry returns an error wrapping ErrOrgExpired if the organization has expired.
+func (o *Organization) CheckExpiry() error {
+	if o.Expired() {
+		return fmt.Errorf("%w: %s expired at %s", ErrOrgExpired, o.Name, o.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// FingerprintSHA256 returns the SHA256 fingerprint of the organization's SSH
+// key, like ssh-keygen -lf, such as "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8".
+// The key is the primary one cloning uses, as returned by KeyPath(""), even
+// if the organization has expired. Encrypted keys are fingerprinted from their
+// public half.
+func (o *Organization) FingerprintSHA256() (string, error) {
+	keyPath, err := o.keyPath("")
+	if err != nil {
+		return "", err
+	}
+	return sshkey.Fingerprint(utils.ExpandPath(keyPath))
+}
+
+// CheckKey reports whether the organization's SSH keys are usable: its key
+// path and each labeled key must exist as regular files with 0600 permissions,
+// as checked by ValidateSSHKeyPath. An organization using a host alias without
+// a key of its own takes its key from the user's SSH config, so it always passes.
+func (o *Organization) CheckKey() error {
+	if o.HostAlias == "" || o.SSHKeyPath != "" {
+		if err := ValidateSSHKeyPath(o.SSHKeyPath); err != nil {
+			return err
+		}
+	}
+	for _, label := range slices.Sorted(maps.Keys(o.Keys)) {
+		if err := ValidateSSHKeyPath(o.Keys[label]); err != nil {
+			return fmt.Errorf("key %q: %w", label, err)
+		}
+	}
+	return nil
+}
+
+// KeyExists reports whether the organization's key path exists, after
+// expanding ~ and environment variables in it.
+func (o *Organization) KeyExists() bool {
+	if o.SSHKeyPath == "" {
+		return false
+	}
+	_, err := os.Stat(utils.ExpandPath(o.SSHKeyPath))
+	return err == nil
+}
+
+// KeyMode returns the file mode of the organization's key, after expanding ~
+// and environment variables in its path, such as to check it is 0600.
+// It returns ErrEmptySSHKeyPath if the organization has no key path, or the
+// error from os.Stat if the key can't be read.
+func (o *Organization) KeyMode() (os.FileMode, error) {
+	if o.SSHKeyPath == "" {
+		return 0, ErrEmptySSHKeyPath
+	}
+	info, err := os.Stat(utils.ExpandPath(o.SSHKeyPath))
+	if err != nil {
+		return 0, err
+	}
+	return info.Mode(), nil
+}
+
+// Equal reports whether two organizations have identical fields. KeyOverride
+// is ignored, as it only applies at runtime and is never saved.
+func (o *Organization) Equal(other *Organization) bool {
+	if o == nil || other == nil {
+		return o == other
+	}
+	return o.Name == other.Name &&
+		o.SSHKeyPath == other.SSHKeyPath &&
+		maps.Equal(o.Keys, other.Keys) &&
+		o.PrimaryKey == other.PrimaryKey &&
+		o.IsDefault == other.IsDefault &&
+		o.Host == other.Host &&
+		o.HostAlias == other.HostAlias &&
+		o.KnownHosts == other.KnownHosts &&
+		o.PostClone == other.PostClone &&
+		o.IdentityAgent == other.IdentityAgent &&
+		o.ConnectTimeout == other.ConnectTimeout &&
+		o.ServerAliveInterval == other.ServerAliveInterval &&
+		equalTimes(o.ExpiresAt, other.ExpiresAt)
+}
+
+// equalTimes reports whether two optional times are both unset, or the same instant.
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// JSON returns the JSON encoding of a single organization.
+func (o *Organization) JSON() ([]byte, error) {
+	return json.Marshal(o)
 }
 
 // Validate checks the validity of the Organization object.
 // It performs the following validations:
 //  1. Ensures the organization name is not empty. Returns ErrEmptyOrganizationName if empty.
-//  2. Validates the organization name against a specific pattern unless it is "default".
-//     Returns ErrInvalidOrgName if the name does not match the pattern.
-//  3. Ensures the SSH key path is not empty. Returns ErrEmptySSHKeyPath if empty.
-//  4. Checks if the SSH key path exists and has the correct file permissions (0600).
-//     Returns an appropriate error if the file does not exist or has incorrect permissions.
+//  2. Validates the organization name against GitHub's naming rules unless it is "default".
+//     Returns an error wrapping ErrInvalidOrgName if the name does not match the rules.
+//  3. Ensures the SSH key path is not empty, unless the organization uses a
+//     HostAlias. Returns ErrEmptySSHKeyPath if empty.
+//  4. Checks if the SSH key path exists, is a regular file, and has the correct file
+//     permissions (0600). Returns ErrSSHKeyNotRegularFile if the path is a directory or
+//     other non-regular file, or an appropriate error if the file does not exist or has
+//     incorrect permissions.
+//  5. If KnownHosts is a path, checks that it exists. Returns an error wrapping
+//     ErrKnownHostsNotFound if it does not.
+//  6. Checks each labeled key in Keys like the SSH key path, and that PrimaryKey,
+//     if set, is one of their labels. Returns an error wrapping ErrKeyLabelNotFound
+//     if it is not.
+//  7. If IdentityAgent is set, checks that it is a plausible agent socket. Returns
+//     an error wrapping ErrInvalidIdentityAgent if it is not.
+//  8. Checks that ConnectTimeout and ServerAliveInterval are not negative. Returns
+//     an error wrapping ErrInvalidSSHTimeout if either is.
 //
 // Returns an error if any of the validations fail, otherwise returns nil.
 func (o *Organization) Validate() error {
+	return o.validate(true)
+}
+
+// validate implements Validate, checking the SSH keys only if checkKey is set.
+func (o *Organization) validate(checkKey bool) error {
+	// check the organization name
+	if err := validateOrganizationName(o.Name); err != nil {
+		return err
+	}
+	// check the SSH keys, which an organization using a host alias doesn't need
+	if checkKey {
+		if err := o.CheckKey(); err != nil {
+			return err
+		}
+	}
+	// check the host alias, if one is set
+	if o.HostAlias != "" {
+		if err := validateHost(o.HostAlias); err != nil {
+			return err
+		}
+	}
+	// check that the primary key is one of the labeled keys
+	if o.PrimaryKey != "" {
+		if _, ok := o.Keys[o.PrimaryKey]; !ok {
+			return fmt.Errorf("%w: primary key %q", ErrKeyLabelNotFound, o.PrimaryKey)
+		}
+	}
+	// check the host, if one is set
+	if o.Host != "" {
+		if err := validateHost(o.Host); err != nil {
+			return err
+		}
+	}
+	// check the known_hosts file, if one is pinned by path
+	if o.KnownHosts != "" && !o.KnownHostsInline() {
+		if _, err := os.Stat(utils.ExpandPath(o.KnownHosts)); err != nil {
+			return fmt.Errorf("%w: %s", ErrKnownHostsNotFound, o.KnownHosts)
+		}
+	}
+	// check the agent socket, if one is set
+	if o.IdentityAgent != "" {
+		if err := validateIdentityAgent(o.IdentityAgent); err != nil {
+			return err
+		}
+	}
+	// check the SSH timeouts; zero means ssh's default
+	if o.ConnectTimeout < 0 {
+		return fmt.Errorf("%w: connect_timeout is %d", ErrInvalidSSHTimeout, o.ConnectTimeout)
+	}
+	if o.ServerAliveInterval < 0 {
+		return fmt.Errorf("%w: server_alive_interval is %d", ErrInvalidSSHTimeout, o.ServerAliveInterval)
+	}
+	return nil
+}
+
+// identityAgentEnvRegex matches an environment variable reference, such as
+// $SSH_AUTH_SOCK, which ssh expands in IdentityAgent.
+var identityAgentEnvRegex = regexp.MustCompile(`^\$[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateIdentityAgent checks that agent is something ssh accepts for
+// IdentityAgent: "none", "SSH_AUTH_SOCK", an environment variable reference,
+// or an absolute or ~/ socket path. Paths must not contain whitespace or
+// control characters, which ssh would split or which could inject directives.
+// The socket itself needn't exist yet, as the agent may not be running.
+func validateIdentityAgent(agent string) error {
+	if agent == "none" || agent == "SSH_AUTH_SOCK" || identityAgentEnvRegex.MatchString(agent) {
+		return nil
+	}
+	if !strings.HasPrefix(agent, "/") && !strings.HasPrefix(agent, "~/") {
+		return fmt.Errorf("%w: %q is not an absolute path", ErrInvalidIdentityAgent, agent)
+	}
+	if strings.ContainsFunc(agent, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) {
+		return fmt.Errorf("%w: %q contains whitespace or control characters", ErrInvalidIdentityAgent, agent)
+	}
+	return nil
+}
+
+// CanonicalOrganizationName returns the canonical form of an organization
+// name: trimmed of surrounding whitespace and lowercased, as GitHub matches
+// names regardless of case, so "MyOrg" and "myorg" are stored as one.
+func CanonicalOrganizationName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// validateOrganizationName checks that an organization name is not empty and,
+// unless it is "default", that it follows GitHub's naming rules.
+func validateOrganizationName(name string) error {
 	// check if the organization name is empty
-	if o.Name == "" {
+	if name == "" {
 		return ErrEmptyOrganizationName
 	}
 	// check if the organization name matches the requirements | default
-	reg := regexp.MustCompile(`^[a-z0-9](?:[a-z0-9\-]{0,37}[a-z0-9])?$`)
-	if o.Name != "default" {
-		if !reg.MatchString(o.Name) {
-			return ErrInvalidOrgName
+	if name != "default" {
+		if err := validateOrgName(name); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// hostLabelRegex matches a single DNS label: letters, digits and hyphens,
+// neither starting nor ending with a hyphen.
+var hostLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// validateHost checks that host is a plausible hostname: dot-separated DNS
+// labels, no longer than 253 characters in total, with no scheme, user or port.
+func validateHost(host string) error {
+	if len(host) > 253 {
+		return fmt.Errorf("%w: %s", ErrInvalidHost, host)
+	}
+	for label := range strings.SplitSeq(host, ".") {
+		if !hostLabelRegex.MatchString(label) {
+			return fmt.Errorf("%w: %s", ErrInvalidHost, host)
+		}
+	}
+	return nil
+}
+
+// ValidateSSHKeyPath checks that an SSH key path is usable. It ensures that:
+//   - The path is not empty; otherwise, it returns ErrEmptySSHKeyPath.
+//   - The path is a regular file; otherwise, it returns ErrSSHKeyNotRegularFile.
+//   - The file has the correct permissions (0600); otherwise, it returns an error
+//     wrapping os.ErrPermission.
+//   - The file exists; otherwise, it returns an error wrapping os.ErrNotExist.
+func ValidateSSHKeyPath(sshKeyPath string) error {
 	// check if the SSH key path is empty
-	if o.SSHKeyPath == "" {
+	if sshKeyPath == "" {
 		return ErrEmptySSHKeyPath
 	}
 	// check if the SSH key path is valid
-	if fileInfo, err := os.Stat(o.SSHKeyPath); err == nil {
+	if fileInfo, err := os.Stat(sshKeyPath); err == nil {
+		// check the key is a regular file, not a directory or device
+		if !fileInfo.Mode().IsRegular() {
+			return fmt.Errorf("%w: %s", ErrSSHKeyNotRegularFile, sshKeyPath)
+		}
 		// check permissions are secure and correct
 		if fileInfo.Mode().Perm() != 0600 {
-			return fmt.Errorf("%w: %s has incorrect permissions: %v", os.ErrPermission, o.SSHKeyPath, fileInfo.Mode().Perm())
+			return fmt.Errorf("%w: %s has incorrect permissions: %v", os.ErrPermission, sshKeyPath, fileInfo.Mode().Perm())
 		}
 	} else if os.IsNotExist(err) {
-		return fmt.Errorf("%w: %s", os.ErrNotExist, o.SSHKeyPath)
+		return fmt.Errorf("%w: %s", os.ErrNotExist, sshKeyPath)
 	}
 
 	return nil
 }
+
+// orgNamePattern matches GitHub organization and user names: lowercase alphanumerics
+// separated by single hyphens, up to 39 characters.
+var orgNamePattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// reservedOrgNames are names GitHub reserves for its own use.
+var reservedOrgNames = map[string]struct{}{
+	"about":         {},
+	"admin":         {},
+	"api":           {},
+	"enterprise":    {},
+	"explore":       {},
+	"features":      {},
+	"github":        {},
+	"login":         {},
+	"marketplace":   {},
+	"new":           {},
+	"organizations": {},
+	"orgs":          {},
+	"pricing":       {},
+	"security":      {},
+	"settings":      {},
+	"site":          {},
+	"sponsors":      {},
+	"topics":        {},
+}
+
+// validateOrgName checks a name against GitHub's documented naming rules.
+// Every returned error wraps ErrInvalidOrgName, along with a more specific
+// error describing which rule was broken.
+func validateOrgName(name string) error {
+	switch {
+	case len(name) > 39:
+		return fmt.Errorf("%w: %w", ErrInvalidOrgName, ErrOrgNameTooLong)
+	case strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-"):
+		return fmt.Errorf("%w: %w", ErrInvalidOrgName, ErrOrgNameEdgeHyphen)
+	case strings.Contains(name, "--"):
+		return fmt.Errorf("%w: %w", ErrInvalidOrgName, ErrOrgNameDoubleHyphen)
+	case !orgNamePattern.MatchString(name):
+		return ErrInvalidOrgName
+	}
+	if _, reserved := reservedOrgNames[name]; reserved {
+		return fmt.Errorf("%w: %w", ErrInvalidOrgName, ErrOrgNameReserved)
+	}
+	return nil
+}
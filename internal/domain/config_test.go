@@ -2,9 +2,15 @@ package domain
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"ghc/internal/sshkey"
 	"ghc/internal/utils"
 )
 
@@ -24,6 +30,198 @@ func TestJSON(t *testing.T) {
 	}
 }
 
+func TestOrganizationJSON(t *testing.T) {
+	o := Organization{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true}
+	jsonData, err := o.JSON()
+	if err != nil {
+		t.Fatalf("unable to marshal organization to JSON: %v", err)
+	}
+	expectedJSON := `{"name":"org1","ssh_key_path":"/path/to/key1","is_default":true}`
+	if string(jsonData) != expectedJSON {
+		t.Errorf("expected %s, got %s", expectedJSON, string(jsonData))
+	}
+}
+
+func TestGetOrganization(t *testing.T) {
+	c := Config{
+		Organizations: []*Organization{
+			{Name: "org1", SSHKeyPath: "/path/to/key1"},
+			{Name: "org2", SSHKeyPath: "/path/to/key2"},
+		},
+	}
+
+	org, err := c.GetOrganization("org2")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if org.SSHKeyPath != "/path/to/key2" {
+		t.Errorf("expected /path/to/key2, got %s", org.SSHKeyPath)
+	}
+
+	_, err = c.GetOrganization("org3")
+	if !errors.Is(err, ErrOrganizationNotFound) {
+		t.Errorf("expected %v, got %v", ErrOrganizationNotFound, err)
+	}
+}
+
+func TestCheckEnforceDefault(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr error
+	}{
+		{name: "not enforced", config: Config{Organizations: []*Organization{{Name: "a"}}}},
+		{name: "no organizations", config: Config{EnforceDefault: true}},
+		{name: "has a default", config: Config{EnforceDefault: true, Organizations: []*Organization{{Name: "a"}, {Name: "b", IsDefault: true}}}},
+		{name: "no default", config: Config{EnforceDefault: true, Organizations: []*Organization{{Name: "a"}, {Name: "b"}}}, expectErr: ErrDefaultRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.CheckEnforceDefault(); !errors.Is(err, tt.expectErr) {
+				t.Errorf("expected %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestClone(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &Config{
+		Proxy:      "socks5://127.0.0.1:1080",
+		MinRSABits: 3072,
+		Organizations: []*Organization{
+			{Name: "alpha", SSHKeyPath: "/path/to/key", IsDefault: true, Keys: map[string]string{"read": "/path/to/read"}, ExpiresAt: &expiresAt},
+			{Name: "beta", HostAlias: "github-beta"},
+		},
+	}
+	clone := c.Clone()
+	if !clone.Equal(c) {
+		t.Fatalf("expected an equal clone, got %+v", clone)
+	}
+
+	clone.Organizations[0].Keys["read"] = "/changed"
+	*clone.Organizations[0].ExpiresAt = expiresAt.Add(time.Hour)
+	clone.Organizations[1].Name = "changed"
+	clone.Organizations = append(clone.Organizations[:1], &Organization{Name: "gamma"})
+	clone.MinRSABits = 4096
+
+	if c.Organizations[0].Keys["read"] != "/path/to/read" || !c.Organizations[0].ExpiresAt.Equal(expiresAt) {
+		t.Errorf("expected the original organization to be unchanged, got %+v", c.Organizations[0])
+	}
+	if c.Organizations[1].Name != "beta" || len(c.Organizations) != 2 || c.MinRSABits != 3072 {
+		t.Errorf("expected the original config to be unchanged, got %+v", c)
+	}
+
+	var nilConfig *Config
+	if nilConfig.Clone() != nil {
+		t.Errorf("expected a nil clone of a nil config")
+	}
+}
+
+func TestLen(t *testing.T) {
+	var nilConfig *Config
+	tests := []struct {
+		name     string
+		config   *Config
+		expected int
+	}{
+		{name: "nil", config: nilConfig, expected: 0},
+		{name: "empty", config: &Config{}, expected: 0},
+		{name: "two", config: &Config{Organizations: []*Organization{{Name: "a"}, {Name: "b"}}}, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.Len(); got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	c := Config{
+		Organizations: []*Organization{
+			{Name: "alpha", IsDefault: true},
+			{Name: "beta", HostAlias: "github-beta"},
+			{Name: "gamma", HostAlias: "github-gamma"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		pred     func(*Organization) bool
+		expected []string
+	}{
+		{name: "default", pred: func(o *Organization) bool { return o.IsDefault }, expected: []string{"alpha"}},
+		{name: "host aliases keep their order", pred: func(o *Organization) bool { return o.HostAlias != "" }, expected: []string{"beta", "gamma"}},
+		{name: "all", pred: func(*Organization) bool { return true }, expected: []string{"alpha", "beta", "gamma"}},
+		{name: "nothing", pred: func(o *Organization) bool { return o.Name == "delta" }, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, org := range c.Filter(tt.pred) {
+				names = append(names, org.Name)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+
+	// changing the result must not change the config
+	matched := c.Filter(func(*Organization) bool { return true })
+	matched[0] = &Organization{Name: "replaced"}
+	_ = append(matched[:1], matched[2:]...)
+	if got := c.OrganizationNames(); !slices.Equal(got, []string{"alpha", "beta", "gamma"}) || c.Organizations[0].Name != "alpha" {
+		t.Errorf("expected the config to be unchanged, got %v", got)
+	}
+}
+
+func TestOrganizationNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected []string
+	}{
+		{
+			name: "sorted",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "zeta"},
+					{Name: "alpha"},
+					{Name: "mid-org"},
+				},
+			},
+			expected: []string{"alpha", "mid-org", "zeta"},
+		},
+		{
+			name:     "empty config",
+			config:   Config{},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := tt.config.OrganizationNames()
+			if names == nil || !slices.Equal(names, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+
+	// the config's own order must not change
+	c := Config{Organizations: []*Organization{{Name: "b"}, {Name: "a"}}}
+	c.OrganizationNames()
+	if c.Organizations[0].Name != "b" {
+		t.Errorf("expected organizations to keep their order")
+	}
+}
+
 func TestGetKeyPathForOrg(t *testing.T) {
 	key1 := "/path/to/key1"
 	key2 := "/path/to/key2"
@@ -32,6 +230,7 @@ func TestGetKeyPathForOrg(t *testing.T) {
 		name        string
 		config      Config
 		orgName     string
+		label       string
 		expectsPath string
 		expectsErr  error
 	}{
@@ -67,12 +266,65 @@ func TestGetKeyPathForOrg(t *testing.T) {
 			orgName:    "org2",
 			expectsErr: ErrNoDefaultOrg,
 		},
+		{
+			name: "Labeled key is selected",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1", SSHKeyPath: key1, Keys: map[string]string{"read": "/path/to/read", "write": "/path/to/write"}},
+				},
+			},
+			orgName:     "org1",
+			label:       "write",
+			expectsPath: "/path/to/write",
+		},
+		{
+			name: "Primary key is the default",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1", SSHKeyPath: key1, Keys: map[string]string{"read": "/path/to/read"}, PrimaryKey: "read"},
+				},
+			},
+			orgName:     "org1",
+			expectsPath: "/path/to/read",
+		},
+		{
+			name: "SSH key path is the default without a primary key",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1", SSHKeyPath: key1, Keys: map[string]string{"read": "/path/to/read"}},
+				},
+			},
+			orgName:     "org1",
+			expectsPath: key1,
+		},
+		{
+			name: "Unknown label",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1", SSHKeyPath: key1, Keys: map[string]string{"read": "/path/to/read"}},
+				},
+			},
+			orgName:    "org1",
+			label:      "admin",
+			expectsErr: ErrKeyLabelNotFound,
+		},
+		{
+			name: "Label on an organization without labeled keys",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1", SSHKeyPath: key1},
+				},
+			},
+			orgName:    "org1",
+			label:      "read",
+			expectsErr: ErrKeyLabelNotFound,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			keyPath, err := tt.config.GetKeyPathForOrg(tt.orgName)
-			if err != nil && !errors.Is(err, ErrNoDefaultOrg) {
+			keyPath, err := tt.config.GetKeyPathForOrg(tt.orgName, tt.label)
+			if !errors.Is(err, tt.expectsErr) {
 				t.Errorf("expected %v, got %v", tt.expectsErr, err)
 			}
 			if keyPath != tt.expectsPath {
@@ -82,6 +334,144 @@ func TestGetKeyPathForOrg(t *testing.T) {
 	}
 }
 
+func TestResolveOrganization(t *testing.T) {
+	config := Config{
+		Organizations: []*Organization{
+			{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: false},
+			{Name: "org2", SSHKeyPath: "/path/to/key2", IsDefault: true},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		orgName      string
+		expectsOrg   string
+		expectsFback bool
+	}{
+		{
+			name:         "Exact match",
+			orgName:      "org1",
+			expectsOrg:   "org1",
+			expectsFback: false,
+		},
+		{
+			name:         "Exact match on default",
+			orgName:      "org2",
+			expectsOrg:   "org2",
+			expectsFback: false,
+		},
+		{
+			name:         "Fallback to default",
+			orgName:      "org3",
+			expectsOrg:   "org2",
+			expectsFback: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org, fallback, err := config.ResolveOrganization(tt.orgName)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if org.Name != tt.expectsOrg {
+				t.Errorf("expected %s, got %s", tt.expectsOrg, org.Name)
+			}
+			if fallback != tt.expectsFback {
+				t.Errorf("expected fallback %v, got %v", tt.expectsFback, fallback)
+			}
+		})
+	}
+}
+
+func TestDefaultOrg(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		expectsOrg string
+		expectsErr error
+	}{
+		{
+			name: "No default",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1"},
+					{Name: "org2"},
+				},
+			},
+			expectsErr: ErrNoDefaultOrg,
+		},
+		{
+			name: "One default",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1"},
+					{Name: "org2", IsDefault: true},
+				},
+			},
+			expectsOrg: "org2",
+		},
+		{
+			name: "Multiple defaults, first wins",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1"},
+					{Name: "org2", IsDefault: true},
+					{Name: "org3", IsDefault: true},
+				},
+			},
+			expectsOrg: "org2",
+		},
+		{
+			name: "Override wins over persisted default",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1"},
+					{Name: "org2", IsDefault: true},
+				},
+				DefaultOverride: "org1",
+			},
+			expectsOrg: "org1",
+		},
+		{
+			name: "Override without persisted default",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1"},
+					{Name: "org2"},
+				},
+				DefaultOverride: "org2",
+			},
+			expectsOrg: "org2",
+		},
+		{
+			name: "Override not configured",
+			config: Config{
+				Organizations: []*Organization{
+					{Name: "org1", IsDefault: true},
+				},
+				DefaultOverride: "org3",
+			},
+			expectsErr: ErrDefaultOverrideNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org, err := tt.config.DefaultOrg()
+			if !errors.Is(err, tt.expectsErr) {
+				t.Fatalf("expected %v, got %v", tt.expectsErr, err)
+			}
+			if tt.expectsErr != nil {
+				return
+			}
+			if org.Name != tt.expectsOrg {
+				t.Errorf("expected %s, got %s", tt.expectsOrg, org.Name)
+			}
+		})
+	}
+}
+
 func TestConfigValidate(t *testing.T) {
 	privateKey, _ := utils.GenerateTestSSHKey(t)
 
@@ -199,6 +589,41 @@ func TestOrganizationValidate_InvalidOrgName(t *testing.T) {
 			org:     Organization{Name: "default", SSHKeyPath: privateKey},
 			expects: nil,
 		},
+		{
+			name:    "Invalid organization name with double hyphen",
+			org:     Organization{Name: "invalid--org", SSHKeyPath: privateKey},
+			expects: ErrOrgNameDoubleHyphen,
+		},
+		{
+			name:    "Invalid organization name with leading hyphen",
+			org:     Organization{Name: "-invalid", SSHKeyPath: privateKey},
+			expects: ErrOrgNameEdgeHyphen,
+		},
+		{
+			name:    "Invalid organization name with trailing hyphen",
+			org:     Organization{Name: "invalid-", SSHKeyPath: privateKey},
+			expects: ErrOrgNameEdgeHyphen,
+		},
+		{
+			name:    "Invalid organization name too long",
+			org:     Organization{Name: "a123456789012345678901234567890123456789", SSHKeyPath: privateKey},
+			expects: ErrOrgNameTooLong,
+		},
+		{
+			name:    "Reserved organization name",
+			org:     Organization{Name: "github", SSHKeyPath: privateKey},
+			expects: ErrOrgNameReserved,
+		},
+		{
+			name:    "Targeted errors still wrap ErrInvalidOrgName",
+			org:     Organization{Name: "invalid--org", SSHKeyPath: privateKey},
+			expects: ErrInvalidOrgName,
+		},
+		{
+			name:    "Single character organization name",
+			org:     Organization{Name: "a", SSHKeyPath: privateKey},
+			expects: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -248,33 +673,230 @@ func TestOrganizationValidate_InvalidPermissions(t *testing.T) {
 	}
 }
 
-func TestConfigRemoveOrganization(t *testing.T) {
-	privateKey, _ := utils.GenerateTestSSHKey(t)
+func TestOrganizationValidate_Directory(t *testing.T) {
+	// Create a temporary directory to act as an SSH key path
+	dir := t.TempDir()
 
-	config := Config{
-		Organizations: []*Organization{
-			{Name: "org1", SSHKeyPath: privateKey, IsDefault: true},
-			{Name: "org2", SSHKeyPath: privateKey},
-		},
+	// Give the directory key-like permissions so only the file type check can fail
+	if err := os.Chmod(dir, 0600); err != nil {
+		t.Fatalf("failed to set directory permissions: %v", err)
+	}
+	defer os.Chmod(dir, 0700)
+
+	org := Organization{
+		Name:       "org1",
+		SSHKeyPath: dir,
+	}
+
+	err := org.Validate()
+	if !errors.Is(err, ErrSSHKeyNotRegularFile) {
+		t.Errorf("expected ErrSSHKeyNotRegularFile, got %v", err)
+	}
+}
+
+func TestOrganizationKeyExistsAndMode(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	misperm, _ := utils.GenerateTestSSHKey(t)
+	if err := os.Chmod(misperm, 0644); err != nil {
+		t.Fatalf("failed to set file permissions: %v", err)
 	}
+	// a key under $HOME, referenced with ~
+	t.Setenv("HOME", filepath.Dir(privateKey))
 
 	tests := []struct {
-		name    string
-		orgName string
-		expects error
+		name         string
+		path         string
+		expectExists bool
+		expectMode   os.FileMode
+		expectErr    error
 	}{
-		// this one has to go first because it is the default organization
-		{
-			name:    "Remove default organization when there is more than one",
-			orgName: "org1",
-			expects: ErrCantRemoveDefault,
-		},
-		{
-			name:    "Remove non-default organization",
-			orgName: "org2",
-			expects: nil,
-		},
-		{
+		{name: "existing", path: privateKey, expectExists: true, expectMode: 0600},
+		{name: "expanded", path: "~/" + filepath.Base(privateKey), expectExists: true, expectMode: 0600},
+		{name: "missing", path: "/nonexistent/path/to/ssh_key", expectErr: os.ErrNotExist},
+		{name: "mis-permed", path: misperm, expectExists: true, expectMode: 0644},
+		{name: "empty", path: "", expectErr: ErrEmptySSHKeyPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := &Organization{Name: "org1", SSHKeyPath: tt.path}
+			if exists := org.KeyExists(); exists != tt.expectExists {
+				t.Errorf("expected exists %v, got %v", tt.expectExists, exists)
+			}
+			mode, err := org.KeyMode()
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if mode.Perm() != tt.expectMode {
+				t.Errorf("expected mode %v, got %v", tt.expectMode, mode.Perm())
+			}
+		})
+	}
+}
+
+func TestOrganizationValidate_KnownHosts(t *testing.T) {
+	privateKey, publicKey := utils.GenerateTestSSHKey(t)
+	// a path under the home directory, as clone expands it
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "known_hosts_work"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "known hosts work"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		knownHosts string
+		inline     bool
+		expects    error
+	}{
+		{
+			name:       "Existing known_hosts path",
+			knownHosts: publicKey,
+			inline:     false,
+			expects:    nil,
+		},
+		{
+			name:       "Missing known_hosts path",
+			knownHosts: "/nonexistent/known_hosts",
+			inline:     false,
+			expects:    ErrKnownHostsNotFound,
+		},
+		{
+			name:       "Existing known_hosts path under ~",
+			knownHosts: "~/.ssh/known_hosts_work",
+			inline:     false,
+			expects:    nil,
+		},
+		{
+			name:       "Missing known_hosts path under ~",
+			knownHosts: "~/.ssh/known_hosts_missing",
+			inline:     false,
+			expects:    ErrKnownHostsNotFound,
+		},
+		{
+			name:       "Existing known_hosts path with spaces",
+			knownHosts: "~/.ssh/known hosts work",
+			inline:     false,
+			expects:    nil,
+		},
+		{
+			name:       "Missing known_hosts path with spaces",
+			knownHosts: "~/.ssh/known hosts missing",
+			inline:     false,
+			expects:    ErrKnownHostsNotFound,
+		},
+		{
+			name:       "Inline known_hosts",
+			knownHosts: "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl",
+			inline:     true,
+			expects:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := Organization{Name: "org1", SSHKeyPath: privateKey, KnownHosts: tt.knownHosts}
+			if org.KnownHostsInline() != tt.inline {
+				t.Errorf("expected inline %v, got %v", tt.inline, org.KnownHostsInline())
+			}
+			err := org.Validate()
+			if !errors.Is(err, tt.expects) {
+				t.Errorf("expected %v, got %v", tt.expects, err)
+			}
+		})
+	}
+}
+
+func TestOrganizationValidate_IdentityAgent(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name    string
+		agent   string
+		expects error
+	}{
+		{name: "Unset", agent: "", expects: nil},
+		{name: "Absolute path", agent: "/run/user/1000/gnupg/S.gpg-agent.ssh", expects: nil},
+		{name: "Home path", agent: "~/.1password/agent.sock", expects: nil},
+		{name: "Environment variable", agent: "$MY_AGENT_SOCK", expects: nil},
+		{name: "SSH_AUTH_SOCK", agent: "SSH_AUTH_SOCK", expects: nil},
+		{name: "None", agent: "none", expects: nil},
+		{name: "Relative path", agent: "agent.sock", expects: ErrInvalidIdentityAgent},
+		{name: "Path with spaces", agent: "/tmp/my agent.sock", expects: ErrInvalidIdentityAgent},
+		{name: "Path with newline", agent: "/tmp/agent.sock\nProxyCommand evil", expects: ErrInvalidIdentityAgent},
+		{name: "Invalid variable", agent: "$1AGENT", expects: ErrInvalidIdentityAgent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := Organization{Name: "org1", SSHKeyPath: privateKey, IdentityAgent: tt.agent}
+			err := org.Validate()
+			if !errors.Is(err, tt.expects) {
+				t.Errorf("expected %v, got %v", tt.expects, err)
+			}
+		})
+	}
+}
+
+func TestOrganizationValidate_SSHTimeouts(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name                string
+		connectTimeout      int
+		serverAliveInterval int
+		expects             error
+	}{
+		{name: "Unset", expects: nil},
+		{name: "Both set", connectTimeout: 10, serverAliveInterval: 15, expects: nil},
+		{name: "Negative connect timeout", connectTimeout: -1, expects: ErrInvalidSSHTimeout},
+		{name: "Negative keepalive interval", serverAliveInterval: -30, expects: ErrInvalidSSHTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := Organization{Name: "org1", SSHKeyPath: privateKey, ConnectTimeout: tt.connectTimeout, ServerAliveInterval: tt.serverAliveInterval}
+			err := org.Validate()
+			if !errors.Is(err, tt.expects) {
+				t.Errorf("expected %v, got %v", tt.expects, err)
+			}
+		})
+	}
+}
+
+func TestConfigRemoveOrganization(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	config := Config{
+		Organizations: []*Organization{
+			{Name: "org1", SSHKeyPath: privateKey, IsDefault: true},
+			{Name: "org2", SSHKeyPath: privateKey},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		orgName string
+		expects error
+	}{
+		// this one has to go first because it is the default organization
+		{
+			name:    "Remove default organization when there is more than one",
+			orgName: "org1",
+			expects: ErrCantRemoveDefault,
+		},
+		{
+			name:    "Remove non-default organization",
+			orgName: "org2",
+			expects: nil,
+		},
+		{
 			name:    "Remove default organization when its the last one",
 			orgName: "org1",
 			expects: nil,
@@ -296,6 +918,57 @@ func TestConfigRemoveOrganization(t *testing.T) {
 	}
 }
 
+func TestConfigMoveOrganization(t *testing.T) {
+	tests := []struct {
+		name      string
+		orgName   string
+		direction MoveDirection
+		expects   []string
+		expectErr error
+	}{
+		{name: "Move up", orgName: "org3", direction: MoveUp, expects: []string{"org1", "org3", "org2", "org4"}},
+		{name: "Move up at top", orgName: "org1", direction: MoveUp, expects: []string{"org1", "org2", "org3", "org4"}},
+		{name: "Move down", orgName: "org2", direction: MoveDown, expects: []string{"org1", "org3", "org2", "org4"}},
+		{name: "Move down at bottom", orgName: "org4", direction: MoveDown, expects: []string{"org1", "org2", "org3", "org4"}},
+		{name: "Move top", orgName: "org3", direction: MoveTop, expects: []string{"org3", "org1", "org2", "org4"}},
+		{name: "Move bottom", orgName: "org2", direction: MoveBottom, expects: []string{"org1", "org3", "org4", "org2"}},
+		{name: "Invalid direction", orgName: "org2", direction: "sideways", expectErr: ErrInvalidMoveDirection},
+		{name: "Organization not found", orgName: "org5", direction: MoveUp, expectErr: ErrOrganizationNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Organizations: []*Organization{
+					{Name: "org1"},
+					{Name: "org2"},
+					{Name: "org3"},
+					{Name: "org4"},
+				},
+			}
+
+			err := config.MoveOrganization(tt.orgName, tt.direction)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				return
+			}
+
+			var names []string
+			for _, org := range config.Organizations {
+				names = append(names, org.Name)
+			}
+			if !slices.Equal(names, tt.expects) {
+				t.Errorf("expected %v, got %v", tt.expects, names)
+			}
+			if !config.ManualOrder {
+				t.Errorf("expected ManualOrder to be set")
+			}
+		})
+	}
+}
+
 func TestConfigSetOrganization(t *testing.T) {
 	privateKey, _ := utils.GenerateTestSSHKey(t)
 
@@ -343,7 +1016,7 @@ func TestConfigSetOrganization(t *testing.T) {
 			expects:    nil,
 		},
 		{
-			name: "Automatically set single organization as default",
+			name: "Single organization is not made default automatically",
 			config: Config{
 				Organizations: []*Organization{},
 			},
@@ -384,6 +1057,9 @@ func TestConfigSetOrganization(t *testing.T) {
 			}
 
 			// Additional checks for specific scenarios
+			if tt.name == "Single organization is not made default automatically" && tt.config.Organizations[0].IsDefault {
+				t.Errorf("expected organization %s not to be default", tt.orgName)
+			}
 			if tt.name == "Set organization as default" {
 				for _, org := range tt.config.Organizations {
 					if org.Name == tt.orgName && !org.IsDefault {
@@ -397,3 +1073,674 @@ func TestConfigSetOrganization(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigPromoteSoleOrganization(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected bool
+	}{
+		{
+			name:     "sole organization is promoted",
+			config:   Config{Organizations: []*Organization{{Name: "org1"}}},
+			expected: true,
+		},
+		{
+			name:     "already default",
+			config:   Config{Organizations: []*Organization{{Name: "org1", IsDefault: true}}},
+			expected: false,
+		},
+		{
+			name:     "more than one organization",
+			config:   Config{Organizations: []*Organization{{Name: "org1"}, {Name: "org2"}}},
+			expected: false,
+		},
+		{
+			name:     "no organizations",
+			config:   Config{},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.PromoteSoleOrganization(); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+			if len(tt.config.Organizations) == 1 && !tt.config.Organizations[0].IsDefault {
+				t.Errorf("expected the sole organization to be the default")
+			}
+		})
+	}
+}
+
+func TestConfigMinRSABits(t *testing.T) {
+	// GenerateTestSSHKey makes 2048-bit RSA keys
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name       string
+		minRSABits int
+		expects    error
+	}{
+		{name: "no minimum", minRSABits: 0, expects: nil},
+		{name: "adequate key", minRSABits: 2048, expects: nil},
+		{name: "under-size key", minRSABits: 3072, expects: ErrRSAKeyTooSmall},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{MinRSABits: tt.minRSABits}
+			err := config.SetOrganization("org1", privateKey, true)
+			if !errors.Is(err, tt.expects) {
+				t.Errorf("SetOrganization: expected %v, got %v", tt.expects, err)
+			}
+
+			// an existing config is held to the same minimum
+			config = Config{
+				MinRSABits:    tt.minRSABits,
+				Organizations: []*Organization{{Name: "org1", SSHKeyPath: privateKey, IsDefault: true}},
+			}
+			if err := config.Validate(); !errors.Is(err, tt.expects) {
+				t.Errorf("Validate: expected %v, got %v", tt.expects, err)
+			}
+			issues := config.Lint()
+			reported := slices.ContainsFunc(issues, func(i Issue) bool { return errors.Is(i.Err, ErrRSAKeyTooSmall) })
+			if reported != (tt.expects != nil) {
+				t.Errorf("Lint: expected ErrRSAKeyTooSmall reported %v, got %v", tt.expects != nil, issues)
+			}
+		})
+	}
+}
+
+func TestConfigSetOrganization_KeepsDefault(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	config := Config{
+		Organizations: []*Organization{
+			{Name: "org1", SSHKeyPath: "/old/path", IsDefault: true},
+			{Name: "org2", SSHKeyPath: privateKey, IsDefault: false},
+		},
+	}
+
+	// updating the default org's key without the default flag must not demote it
+	if err := config.SetOrganization("org1", privateKey, false); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	def, err := config.DefaultOrg()
+	if err != nil {
+		t.Fatalf("expected a default org, got %v", err)
+	}
+	if def.Name != "org1" || def.SSHKeyPath != privateKey {
+		t.Errorf("expected org1 with the new key to remain the default, got %+v", def)
+	}
+
+	// making another org the default still moves it
+	if err := config.SetOrganization("org2", privateKey, true); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if config.Organizations[0].IsDefault || !config.Organizations[1].IsDefault {
+		t.Errorf("expected org2 to be the only default, got %+v %+v", config.Organizations[0], config.Organizations[1])
+	}
+}
+
+func TestConfigReplaceKey(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name       string
+		orgName    string
+		sshKeyPath string
+		expects    error
+		expectKey  string
+	}{
+		{name: "replace key", orgName: "org1", sshKeyPath: privateKey, expectKey: privateKey},
+		{name: "org not found", orgName: "org3", sshKeyPath: privateKey, expects: ErrOrganizationNotFound, expectKey: "/old/key1"},
+		{name: "invalid key", orgName: "org1", sshKeyPath: "/does/not/exist", expects: os.ErrNotExist, expectKey: "/old/key1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Organizations: []*Organization{
+					{Name: "org1", SSHKeyPath: "/old/key1", IsDefault: true, KnownHosts: "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"},
+					{Name: "org2", SSHKeyPath: "/old/key2"},
+				},
+			}
+			err := config.ReplaceKey(tt.orgName, tt.sshKeyPath)
+			if !errors.Is(err, tt.expects) {
+				t.Fatalf("expected %v, got %v", tt.expects, err)
+			}
+
+			// only the key may change
+			org := config.Organizations[0]
+			if org.SSHKeyPath != tt.expectKey {
+				t.Errorf("expected key %s, got %s", tt.expectKey, org.SSHKeyPath)
+			}
+			if !org.IsDefault || org.KnownHosts != "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl" {
+				t.Errorf("expected other settings to be kept, got %+v", org)
+			}
+		})
+	}
+}
+
+func TestOrganizationValidateKeys(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	readKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name       string
+		keys       map[string]string
+		primaryKey string
+		expects    error
+	}{
+		{name: "no labeled keys", expects: nil},
+		{name: "valid labeled keys", keys: map[string]string{"read": readKey}, expects: nil},
+		{name: "valid primary key", keys: map[string]string{"read": readKey}, primaryKey: "read", expects: nil},
+		{name: "missing labeled key", keys: map[string]string{"read": "/does/not/exist"}, expects: os.ErrNotExist},
+		{name: "unknown primary key", keys: map[string]string{"read": readKey}, primaryKey: "write", expects: ErrKeyLabelNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := Organization{Name: "org1", SSHKeyPath: privateKey, Keys: tt.keys, PrimaryKey: tt.primaryKey}
+			if err := org.Validate(); !errors.Is(err, tt.expects) {
+				t.Errorf("expected %v, got %v", tt.expects, err)
+			}
+		})
+	}
+}
+
+func TestOrganizationFingerprintSHA256(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	org := Organization{Name: "org1", SSHKeyPath: privateKey}
+	fingerprint, err := org.FingerprintSHA256()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(fingerprint, "SHA256:") {
+		t.Errorf("expected a SHA256 fingerprint, got %s", fingerprint)
+	}
+
+	// the primary key is fingerprinted, even if the organization has expired
+	otherKey, _ := utils.GenerateTestSSHKey(t)
+	want, err := sshkey.Fingerprint(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-time.Hour)
+	for _, primary := range []Organization{
+		{Name: "org1", SSHKeyPath: privateKey, KeyOverride: otherKey},
+		{Name: "org1", SSHKeyPath: privateKey, Keys: map[string]string{"work": otherKey}, PrimaryKey: "work"},
+		{Name: "org1", SSHKeyPath: otherKey, ExpiresAt: &expired},
+	} {
+		if got, err := primary.FingerprintSHA256(); err != nil || got != want {
+			t.Errorf("expected %s, got %s (%v)", want, got, err)
+		}
+	}
+
+	org.SSHKeyPath = "/does/not/exist"
+	if _, err := org.FingerprintSHA256(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected %v, got %v", os.ErrNotExist, err)
+	}
+}
+
+func TestConfigSetOrganizationUnchecked(t *testing.T) {
+	tests := []struct {
+		name    string
+		orgName string
+		keyPath string
+		expects error
+	}{
+		{name: "missing key", orgName: "org1", keyPath: "/does/not/exist", expects: nil},
+		{name: "invalid name", orgName: "-org1", keyPath: "/does/not/exist", expects: ErrInvalidOrgName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the key size isn't checked either
+			c := &Config{MinRSABits: 4096}
+			err := c.SetOrganizationUnchecked(tt.orgName, tt.keyPath, true)
+			if !errors.Is(err, tt.expects) {
+				t.Fatalf("expected %v, got %v", tt.expects, err)
+			}
+			if tt.expects != nil {
+				return
+			}
+			org, err := c.GetOrganization(tt.orgName)
+			if err != nil || org.SSHKeyPath != tt.keyPath || !org.IsDefault {
+				t.Errorf("unexpected organization %+v (%v)", org, err)
+			}
+			// the checked version still rejects it
+			if err := c.SetOrganization(tt.orgName, tt.keyPath, true); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("expected %v, got %v", os.ErrNotExist, err)
+			}
+		})
+	}
+}
+
+func TestOrganizationExpiry(t *testing.T) {
+	expiresAt := time.Date(2026, 11, 16, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		now       time.Time
+		expects   error
+	}{
+		{name: "no expiry", now: expiresAt.Add(365 * 24 * time.Hour), expects: nil},
+		{name: "just before expiry", expiresAt: &expiresAt, now: expiresAt.Add(-time.Nanosecond), expects: nil},
+		{name: "at expiry", expiresAt: &expiresAt, now: expiresAt, expects: ErrOrgExpired},
+		{name: "just after expiry", expiresAt: &expiresAt, now: expiresAt.Add(time.Nanosecond), expects: ErrOrgExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldNow := now
+			now = func() time.Time { return tt.now }
+			t.Cleanup(func() { now = oldNow })
+
+			c := &Config{Organizations: []*Organization{
+				{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true, ExpiresAt: tt.expiresAt},
+			}}
+			if got := c.Organizations[0].Expired(); got != (tt.expects != nil) {
+				t.Errorf("expected expired %v, got %v", tt.expects != nil, got)
+			}
+			// the key of an expired org is refused, including as the default
+			for _, name := range []string{"org1", "unconfigured"} {
+				keyPath, err := c.GetKeyPathForOrg(name, "")
+				if !errors.Is(err, tt.expects) {
+					t.Errorf("%s: expected %v, got %v", name, tt.expects, err)
+				}
+				if err == nil && keyPath != "/path/to/key1" {
+					t.Errorf("%s: expected /path/to/key1, got %s", name, keyPath)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigSetExpiry(t *testing.T) {
+	expiresAt := time.Date(2026, 11, 16, 10, 0, 0, 0, time.UTC)
+	c := &Config{Organizations: []*Organization{{Name: "org1", SSHKeyPath: "/path/to/key1"}}}
+
+	if err := c.SetExpiry("org1", &expiresAt); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if got := c.Organizations[0].ExpiresAt; got == nil || !got.Equal(expiresAt) {
+		t.Errorf("expected %v, got %v", expiresAt, got)
+	}
+	// the change shows up when comparing and planning
+	before := &Config{Organizations: []*Organization{{Name: "org1", SSHKeyPath: "/path/to/key1"}}}
+	if c.Equal(before) {
+		t.Error("expected configs with different expiries not to be equal")
+	}
+	if changes := Plan(before, c); len(changes) != 1 || changes[0].Field != "expires_at" {
+		t.Errorf("expected an expires_at change, got %v", changes)
+	}
+
+	// nil clears it
+	if err := c.SetExpiry("org1", nil); err != nil || c.Organizations[0].ExpiresAt != nil {
+		t.Errorf("expected the expiry to be cleared, got %v (%v)", c.Organizations[0].ExpiresAt, err)
+	}
+	if err := c.SetExpiry("missing", &expiresAt); !errors.Is(err, ErrOrganizationNotFound) {
+		t.Errorf("expected %v, got %v", ErrOrganizationNotFound, err)
+	}
+}
+
+func TestConfigSetHost(t *testing.T) {
+	tests := []struct {
+		name       string
+		orgName    string
+		host       string
+		expects    error
+		expectHost string
+	}{
+		{name: "set host", orgName: "org1", host: "github.corp.example.com", expectHost: "github.corp.example.com"},
+		{name: "single label", orgName: "org1", host: "ghe", expectHost: "ghe"},
+		{name: "clear host", orgName: "org1", host: "", expectHost: ""},
+		{name: "org not found", orgName: "org3", host: "ghe.example.com", expects: ErrOrganizationNotFound, expectHost: "old.example.com"},
+		{name: "scheme", orgName: "org1", host: "https://ghe.example.com", expects: ErrInvalidHost, expectHost: "old.example.com"},
+		{name: "port", orgName: "org1", host: "ghe.example.com:22", expects: ErrInvalidHost, expectHost: "old.example.com"},
+		{name: "user", orgName: "org1", host: "git@ghe.example.com", expects: ErrInvalidHost, expectHost: "old.example.com"},
+		{name: "empty label", orgName: "org1", host: "ghe..example.com", expects: ErrInvalidHost, expectHost: "old.example.com"},
+		{name: "edge hyphen", orgName: "org1", host: "-ghe.example.com", expects: ErrInvalidHost, expectHost: "old.example.com"},
+		{name: "too long", orgName: "org1", host: strings.Repeat("a.", 127) + "com", expects: ErrInvalidHost, expectHost: "old.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Organizations: []*Organization{
+					{Name: "org1", SSHKeyPath: "/path/to/key1", Host: "old.example.com"},
+				},
+			}
+			err := config.SetHost(tt.orgName, tt.host)
+			if !errors.Is(err, tt.expects) {
+				t.Fatalf("expected %v, got %v", tt.expects, err)
+			}
+			if got := config.Organizations[0].Host; got != tt.expectHost {
+				t.Errorf("expected host %q, got %q", tt.expectHost, got)
+			}
+		})
+	}
+}
+
+func TestConfigSetHostAlias(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name        string
+		orgName     string
+		alias       string
+		isDefault   bool
+		expects     error
+		expectAlias string
+	}{
+		{name: "new org without a key", orgName: "org3", alias: "github-work", expectAlias: "github-work"},
+		{name: "existing org keeps its key", orgName: "org1", alias: "github-work", expectAlias: "github-work"},
+		{name: "new default org", orgName: "org3", alias: "github-work", isDefault: true, expectAlias: "github-work"},
+		{name: "invalid alias", orgName: "org3", alias: "github work", expects: ErrInvalidHost},
+		{name: "clearing the alias of an org without a key", orgName: "org2", alias: "", expects: ErrEmptySSHKeyPath, expectAlias: "old-alias"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Organizations: []*Organization{
+					{Name: "org1", SSHKeyPath: privateKey, IsDefault: true},
+					{Name: "org2", HostAlias: "old-alias"},
+				},
+			}
+			err := config.SetHostAlias(tt.orgName, tt.alias, tt.isDefault)
+			if !errors.Is(err, tt.expects) {
+				t.Fatalf("expected %v, got %v", tt.expects, err)
+			}
+
+			org, getErr := config.GetOrganization(tt.orgName)
+			if err != nil {
+				// a failed new org must not be added, and an existing one must be unchanged
+				if getErr == nil && org.HostAlias != tt.expectAlias {
+					t.Errorf("expected alias %q, got %q", tt.expectAlias, org.HostAlias)
+				}
+				if getErr != nil && len(config.Organizations) != 2 {
+					t.Errorf("expected no org to be added, got %d", len(config.Organizations))
+				}
+				return
+			}
+			if getErr != nil {
+				t.Fatalf("expected %s to exist, got %v", tt.orgName, getErr)
+			}
+			if org.HostAlias != tt.expectAlias {
+				t.Errorf("expected alias %q, got %q", tt.expectAlias, org.HostAlias)
+			}
+			if tt.orgName == "org1" && org.SSHKeyPath != privateKey {
+				t.Errorf("expected key %s to be kept, got %s", privateKey, org.SSHKeyPath)
+			}
+			if org.IsDefault != (tt.isDefault || tt.orgName == "org1") {
+				t.Errorf("expected default %v, got %v", tt.isDefault, org.IsDefault)
+			}
+			if tt.isDefault && config.Organizations[0].IsDefault {
+				t.Errorf("expected the previous default to be unset")
+			}
+		})
+	}
+}
+
+func TestConfigSetKeyClearsHostAlias(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name string
+		set  func(c *Config) error
+	}{
+		{name: "SetOrganization", set: func(c *Config) error { return c.SetOrganization("org1", privateKey, false) }},
+		{name: "SetOrganizationUnchecked", set: func(c *Config) error { return c.SetOrganizationUnchecked("org1", privateKey, false) }},
+		{name: "ReplaceKey", set: func(c *Config) error { return c.ReplaceKey("org1", privateKey) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Organizations: []*Organization{{Name: "org1", HostAlias: "github-work", IsDefault: true}},
+			}
+			if err := tt.set(&config); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			org, err := config.GetOrganization("org1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the alias would win over the key when cloning
+			if org.HostAlias != "" || org.SSHKeyPath != privateKey {
+				t.Errorf("expected key %s without an alias, got %+v", privateKey, org)
+			}
+		})
+	}
+}
+
+func TestCanonicalOrganizationName(t *testing.T) {
+	tests := map[string]string{
+		"myorg":       "myorg",
+		"MyOrg":       "myorg",
+		"  My-Org \n": "my-org",
+		"":            "",
+	}
+	for input, expected := range tests {
+		if got := CanonicalOrganizationName(input); got != expected {
+			t.Errorf("%q: expected %q, got %q", input, expected, got)
+		}
+	}
+}
+
+func TestConfigRepairDefault(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaults      []bool
+		expected      string
+		expectDefault []bool
+	}{
+		{name: "no organizations", expected: ""},
+		{name: "no default", defaults: []bool{false, false}, expected: "org1", expectDefault: []bool{true, false}},
+		{name: "one default", defaults: []bool{false, true}, expected: "", expectDefault: []bool{false, true}},
+		{name: "several defaults", defaults: []bool{false, true, true}, expected: "org2", expectDefault: []bool{false, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{Organizations: []*Organization{}}
+			for i, isDefault := range tt.defaults {
+				config.Organizations = append(config.Organizations, &Organization{Name: fmt.Sprintf("org%d", i+1), IsDefault: isDefault})
+			}
+
+			if got := config.RepairDefault(); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+			for i, org := range config.Organizations {
+				if org.IsDefault != tt.expectDefault[i] {
+					t.Errorf("expected %s default %v, got %v", org.Name, tt.expectDefault[i], org.IsDefault)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigResetOrganization(t *testing.T) {
+	config := Config{
+		Organizations: []*Organization{
+			{Name: "org1", SSHKeyPath: "/path/to/key1"},
+			{Name: "org2", SSHKeyPath: "/path/to/key2", IsDefault: true, Host: "ghe.example.com", Keys: map[string]string{"read": "/path/to/read"}},
+		},
+	}
+
+	if !config.ResetOrganization("org2") {
+		t.Fatal("expected org2 to exist")
+	}
+	expected := &Organization{Name: "org2", IsDefault: true}
+	if !config.Organizations[1].Equal(expected) {
+		t.Errorf("expected %+v, got %+v", *expected, *config.Organizations[1])
+	}
+	if config.Organizations[0].SSHKeyPath != "/path/to/key1" {
+		t.Errorf("expected org1 to be untouched, got %+v", *config.Organizations[0])
+	}
+
+	if config.ResetOrganization("org3") {
+		t.Error("expected org3 not to exist")
+	}
+	if len(config.Organizations) != 2 {
+		t.Errorf("expected 2 organizations, got %d", len(config.Organizations))
+	}
+}
+
+func TestConfigRenameOrganization(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldName string
+		newName string
+		expects error
+	}{
+		{name: "rename default org", oldName: "org1", newName: "renamed-org", expects: nil},
+		{name: "same name", oldName: "org1", newName: "org1", expects: nil},
+		{name: "org not found", oldName: "org3", newName: "renamed-org", expects: ErrOrganizationNotFound},
+		{name: "name taken", oldName: "org1", newName: "org2", expects: ErrDuplicateOrganization},
+		{name: "invalid name", oldName: "org1", newName: "-bad-", expects: ErrInvalidOrgName},
+		{name: "empty name", oldName: "org1", newName: "", expects: ErrEmptyOrganizationName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := Organization{
+				Name:       "org1",
+				SSHKeyPath: "/path/to/key1",
+				IsDefault:  true,
+				KnownHosts: "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl",
+				PostClone:  "make setup",
+			}
+			org := original
+			config := Config{
+				Organizations: []*Organization{&org, {Name: "org2", SSHKeyPath: "/path/to/key2"}},
+			}
+
+			err := config.RenameOrganization(tt.oldName, tt.newName)
+			if !errors.Is(err, tt.expects) {
+				t.Fatalf("expected %v, got %v", tt.expects, err)
+			}
+
+			// every other field must carry over, and the org must stay the default
+			expected := original
+			if err == nil {
+				expected.Name = tt.newName
+			}
+			if !config.Organizations[0].Equal(&expected) {
+				t.Errorf("expected %+v, got %+v", expected, *config.Organizations[0])
+			}
+			def, err := config.DefaultOrg()
+			if err != nil || def.Name != expected.Name {
+				t.Errorf("expected %s to be the default, got %v (%v)", expected.Name, def, err)
+			}
+		})
+	}
+}
+
+func TestConfigEqual(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			Proxy: "socks5://proxy:1080",
+			Organizations: []*Organization{
+				{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true},
+				{Name: "org2", SSHKeyPath: "/path/to/key2"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		expected bool
+	}{
+		{
+			name:     "equal",
+			modify:   func(c *Config) {},
+			expected: true,
+		},
+		{
+			name: "reordered",
+			modify: func(c *Config) {
+				slices.Reverse(c.Organizations)
+			},
+			expected: true,
+		},
+		{
+			name: "different key",
+			modify: func(c *Config) {
+				c.Organizations[1].SSHKeyPath = "/path/to/other"
+			},
+			expected: false,
+		},
+		{
+			name: "different default",
+			modify: func(c *Config) {
+				c.Organizations[0].IsDefault = false
+			},
+			expected: false,
+		},
+		{
+			name: "different labeled keys",
+			modify: func(c *Config) {
+				c.Organizations[0].Keys = map[string]string{"read": "/path/to/read"}
+			},
+			expected: false,
+		},
+		{
+			name: "extra organization",
+			modify: func(c *Config) {
+				c.Organizations = append(c.Organizations, &Organization{Name: "org3"})
+			},
+			expected: false,
+		},
+		{
+			name: "different setting",
+			modify: func(c *Config) {
+				c.Proxy = ""
+			},
+			expected: false,
+		},
+		{
+			name: "runtime override ignored",
+			modify: func(c *Config) {
+				c.DefaultOverride = "org2"
+			},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := base()
+			tt.modify(other)
+			if got := base().Equal(other); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+			if got := other.Equal(base()); got != tt.expected {
+				t.Errorf("expected %v in reverse, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// with a manual order, the order matters
+	manual, reordered := base(), base()
+	manual.ManualOrder, reordered.ManualOrder = true, true
+	if !manual.Equal(reordered) {
+		t.Errorf("expected identical manually ordered configs to be equal")
+	}
+	slices.Reverse(reordered.Organizations)
+	if manual.Equal(reordered) {
+		t.Errorf("expected reordered manually ordered configs to differ")
+	}
+
+	// nil configs are only equal to each other
+	var nilConfig *Config
+	if !nilConfig.Equal(nil) || nilConfig.Equal(base()) || base().Equal(nil) {
+		t.Errorf("expected nil configs to be equal only to nil")
+	}
+}
@@ -3,12 +3,29 @@ package domain
 import "errors"
 
 var (
-	ErrCantRemoveDefault     = errors.New("cannot remove the default organization")
-	ErrDuplicateOrganization = errors.New("duplicate organization name found")
-	ErrEmptyOrganizationName = errors.New("organization name cannot be empty")
-	ErrEmptySSHKeyPath       = errors.New("SSH key path cannot be empty")
-	ErrInvalidOrgName        = errors.New("invalid organization name")
-	ErrNoOrganizations       = errors.New("no organizations found in the configuration")
-	ErrOrganizationNotFound  = errors.New("organization not found")
-	ErrOrgNotFound           = errors.New("organization not found")
+	ErrCantRemoveDefault       = errors.New("cannot remove the default organization")
+	ErrDefaultOverrideNotFound = errors.New("the default organization override is not configured")
+	ErrDefaultRequired         = errors.New("enforce_default is set, but no organization is the default")
+	ErrDuplicateOrganization   = errors.New("duplicate organization name found")
+	ErrEmptyOrganizationName   = errors.New("organization name cannot be empty")
+	ErrEmptySSHKeyPath         = errors.New("SSH key path cannot be empty")
+	ErrInvalidHost             = errors.New("invalid host name")
+	ErrInvalidIdentityAgent    = errors.New("invalid identity agent socket")
+	ErrInvalidMoveDirection    = errors.New("invalid move direction, expected up, down, top or bottom")
+	ErrInvalidOrgName          = errors.New("invalid organization name")
+	ErrInvalidSSHTimeout       = errors.New("SSH timeouts cannot be negative")
+	ErrKeyLabelNotFound        = errors.New("SSH key label not found")
+	ErrKnownHostsNotFound      = errors.New("known_hosts file not found")
+	ErrMultipleDefaults        = errors.New("more than one organization is marked as the default")
+	ErrNoOrganizations         = errors.New("no organizations found in the configuration")
+	ErrOrganizationNotFound    = errors.New("organization not found")
+	ErrOrgNameDoubleHyphen     = errors.New("organization name cannot contain consecutive hyphens")
+	ErrOrgNameEdgeHyphen       = errors.New("organization name cannot begin or end with a hyphen")
+	ErrOrgNameReserved         = errors.New("organization name is reserved by GitHub")
+	ErrOrgExpired              = errors.New("organization has expired")
+	ErrOrgNameTooLong          = errors.New("organization name cannot be longer than 39 characters")
+	ErrOrgNotFound             = errors.New("organization not found")
+	ErrRSAKeyTooSmall          = errors.New("RSA key is smaller than the minimum size")
+	ErrSharedSSHKey            = errors.New("SSH key is shared with another organization")
+	ErrSSHKeyNotRegularFile    = errors.New("SSH key path is not a regular file")
 )
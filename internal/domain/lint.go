@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"fmt"
+
+	"ghc/internal/sshkey"
+	"ghc/internal/utils"
+)
+
+// Severity indicates how serious an Issue found by Lint is.
+type Severity int
+
+const (
+	// SeverityWarning is a problem that doesn't stop ghc from working, but is likely a mistake.
+	SeverityWarning Severity = iota
+	// SeverityError is a problem that makes the configuration invalid.
+	SeverityError
+)
+
+// String returns the lowercase name of the severity.
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Issue is a single problem found in the configuration by Lint.
+type Issue struct {
+	Severity Severity // how serious the problem is
+	Org      string   // name of the offending organization, empty for config-wide issues
+	Err      error    // the problem, wrapping one of the domain sentinel errors
+}
+
+// String formats the issue as "severity: org: problem".
+func (i Issue) String() string {
+	if i.Org == "" {
+		return fmt.Sprintf("%s: %v", i.Severity, i.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", i.Severity, i.Org, i.Err)
+}
+
+// Lint checks the configuration and returns every problem it finds, rather than
+// stopping at the first one like Validate. It reports:
+//   - An empty configuration, as an error.
+//   - Duplicate organization names, as errors.
+//   - Invalid organization names, as errors.
+//   - Missing, non-regular, or mis-permissioned SSH keys, as errors.
+//   - A ".pub" file that doesn't match its SSH key, as a warning.
+//   - No default organization, or more than one, as warnings.
+//   - SSH keys shared between organizations, as warnings.
+//
+// An empty slice means no problems were found.
+func (c *Config) Lint() []Issue {
+	issues := []Issue{}
+	if len(c.Organizations) == 0 {
+		return append(issues, Issue{Severity: SeverityError, Err: ErrNoOrganizations})
+	}
+
+	nameSet := make(map[string]struct{})
+	keyOwners := make(map[string]string)
+	var defaultOrg string
+	for _, org := range c.Organizations {
+		// duplicate names
+		if _, exists := nameSet[org.Name]; exists {
+			issues = append(issues, Issue{Severity: SeverityError, Org: org.Name, Err: ErrDuplicateOrganization})
+		}
+		nameSet[org.Name] = struct{}{}
+
+		// name rules
+		if org.Name == "" {
+			issues = append(issues, Issue{Severity: SeverityError, Err: ErrEmptyOrganizationName})
+		} else if org.Name != "default" {
+			if err := validateOrgName(org.Name); err != nil {
+				issues = append(issues, Issue{Severity: SeverityError, Org: org.Name, Err: err})
+			}
+		}
+
+		// key checks
+		if err := org.CheckKey(); err != nil {
+			issues = append(issues, Issue{Severity: SeverityError, Org: org.Name, Err: err})
+		} else if err := c.checkKeySize(org.SSHKeyPath); err != nil {
+			issues = append(issues, Issue{Severity: SeverityError, Org: org.Name, Err: err})
+		} else if org.SSHKeyPath == "" {
+			// a host alias takes its key from the user's SSH config
+		} else if err := sshkey.CheckPair(utils.ExpandPath(org.SSHKeyPath)); err != nil {
+			// ssh offers the key from the .pub, so a mismatch fails in confusing ways
+			issues = append(issues, Issue{Severity: SeverityWarning, Org: org.Name, Err: err})
+		}
+
+		// shared keys
+		if org.SSHKeyPath != "" {
+			if owner, shared := keyOwners[org.SSHKeyPath]; shared {
+				issues = append(issues, Issue{
+					Severity: SeverityWarning,
+					Org:      org.Name,
+					Err:      fmt.Errorf("%w: also used by %s", ErrSharedSSHKey, owner),
+				})
+			} else {
+				keyOwners[org.SSHKeyPath] = org.Name
+			}
+		}
+
+		// multiple defaults
+		if org.IsDefault {
+			if defaultOrg != "" {
+				issues = append(issues, Issue{
+					Severity: SeverityWarning,
+					Org:      org.Name,
+					Err:      fmt.Errorf("%w: %s is already the default", ErrMultipleDefaults, defaultOrg),
+				})
+			} else {
+				defaultOrg = org.Name
+			}
+		}
+	}
+
+	if defaultOrg == "" {
+		issues = append(issues, Issue{Severity: SeverityWarning, Err: ErrNoDefaultOrg})
+	}
+
+	return issues
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"ghc/internal/sshkey"
+	"ghc/internal/utils"
+)
+
+func TestLint_EmptyConfig(t *testing.T) {
+	config := Config{}
+
+	issues := config.Lint()
+	if len(issues) != 1 {
+		t.Fatalf("expected 1 issue, got %d: %v", len(issues), issues)
+	}
+	if !errors.Is(issues[0].Err, ErrNoOrganizations) || issues[0].Severity != SeverityError {
+		t.Errorf("expected error %v, got %v", ErrNoOrganizations, issues[0])
+	}
+}
+
+func TestLint_ValidConfig(t *testing.T) {
+	key1, _ := utils.GenerateTestSSHKey(t)
+	key2, _ := utils.GenerateTestSSHKey(t)
+
+	config := Config{
+		Organizations: []*Organization{
+			{Name: "org1", SSHKeyPath: key1, IsDefault: true},
+			{Name: "org2", SSHKeyPath: key2},
+		},
+	}
+
+	if issues := config.Lint(); len(issues) != 0 {
+		t.Errorf("expected no issues, got %v", issues)
+	}
+}
+
+func TestLint_ReportsAllIssues(t *testing.T) {
+	sharedKey, _ := utils.GenerateTestSSHKey(t)
+	badPermKey, _ := utils.GenerateTestSSHKey(t)
+	if err := os.Chmod(badPermKey, 0644); err != nil {
+		t.Fatalf("failed to set key permissions: %v", err)
+	}
+
+	config := Config{
+		Organizations: []*Organization{
+			{Name: "org1", SSHKeyPath: sharedKey, IsDefault: true},
+			{Name: "org1", SSHKeyPath: "/nonexistent/key"},
+			{Name: "org2", SSHKeyPath: sharedKey, IsDefault: true},
+			{Name: "Invalid!Org", SSHKeyPath: badPermKey},
+		},
+	}
+
+	expected := []struct {
+		severity Severity
+		org      string
+		err      error
+	}{
+		{SeverityError, "org1", ErrDuplicateOrganization},
+		{SeverityError, "org1", os.ErrNotExist},
+		{SeverityWarning, "org2", ErrSharedSSHKey},
+		{SeverityWarning, "org2", ErrMultipleDefaults},
+		{SeverityError, "Invalid!Org", ErrInvalidOrgName},
+		{SeverityError, "Invalid!Org", os.ErrPermission},
+	}
+
+	issues := config.Lint()
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %d: %v", len(expected), len(issues), issues)
+	}
+	for i, want := range expected {
+		got := issues[i]
+		if got.Severity != want.severity || got.Org != want.org || !errors.Is(got.Err, want.err) {
+			t.Errorf("issue %d: expected %s %s %v, got %v", i, want.severity, want.org, want.err, got)
+		}
+	}
+}
+
+func TestLint_NoDefault(t *testing.T) {
+	key, _ := utils.GenerateTestSSHKey(t)
+
+	config := Config{
+		Organizations: []*Organization{
+			{Name: "org1", SSHKeyPath: key},
+		},
+	}
+
+	issues := config.Lint()
+	if len(issues) != 1 {
+		t.Fatalf("expected 1 issue, got %d: %v", len(issues), issues)
+	}
+	if !errors.Is(issues[0].Err, ErrNoDefaultOrg) || issues[0].Severity != SeverityWarning {
+		t.Errorf("expected warning %v, got %v", ErrNoDefaultOrg, issues[0])
+	}
+}
+
+func TestLint_KeyPairMismatch(t *testing.T) {
+	key, _ := utils.GenerateTestSSHKey(t)
+	_, otherPub := utils.GenerateTestSSHKey(t)
+	// a .pub left over from another key
+	data, err := os.ReadFile(otherPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(key+".pub", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{
+		Organizations: []*Organization{
+			{Name: "org1", SSHKeyPath: key, IsDefault: true},
+		},
+	}
+
+	issues := config.Lint()
+	if len(issues) != 1 {
+		t.Fatalf("expected 1 issue, got %d: %v", len(issues), issues)
+	}
+	if !errors.Is(issues[0].Err, sshkey.ErrPairMismatch) || issues[0].Severity != SeverityWarning || issues[0].Org != "org1" {
+		t.Errorf("expected warning %v for org1, got %v", sshkey.ErrPairMismatch, issues[0])
+	}
+}
@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ChangeAction is the kind of a Change found by Plan.
+type ChangeAction int
+
+const (
+	// ChangeAdd is an organization that would be added.
+	ChangeAdd ChangeAction = iota
+	// ChangeRemove is an organization that would be removed.
+	ChangeRemove
+	// ChangeUpdate is a setting, of an organization or the whole config, that would change.
+	ChangeUpdate
+	// ChangeDefault is a different organization that would become the default.
+	ChangeDefault
+)
+
+// Change is a single difference between two configurations, found by Plan.
+type Change struct {
+	Action ChangeAction // what kind of change it is
+	Org    string       // name of the organization, empty for config-wide settings
+	Field  string       // name of the changed setting, for ChangeUpdate
+	From   string       // the old value, empty if there was none
+	To     string       // the new value, empty if there is none
+}
+
+// String describes the change as something that would happen, such as
+// "would add org X" or "would change default from A to B".
+func (c Change) String() string {
+	switch c.Action {
+	case ChangeAdd:
+		return fmt.Sprintf("would add org %s", c.Org)
+	case ChangeRemove:
+		return fmt.Sprintf("would remove org %s", c.Org)
+	case ChangeDefault:
+		switch {
+		case c.From == "":
+			return fmt.Sprintf("would set default to %s", c.To)
+		case c.To == "":
+			return fmt.Sprintf("would unset default %s", c.From)
+		}
+		return fmt.Sprintf("would change default from %s to %s", c.From, c.To)
+	}
+	if c.Org == "" {
+		return fmt.Sprintf("would update %s: %q -> %q", c.Field, c.From, c.To)
+	}
+	return fmt.Sprintf("would update %s of %s: %q -> %q", c.Field, c.Org, c.From, c.To)
+}
+
+// Plan compares the configuration before and after a change and returns what
+// would change, in a stable order: config-wide settings first, then added,
+// removed and updated organizations by name, then the default organization.
+// The runtime DefaultOverride is ignored. An empty slice means nothing would change.
+func Plan(before, after *Config) []Change {
+	changes := []Change{}
+
+	// config-wide settings
+	changes = appendUpdate(changes, "", "proxy", before.Proxy, after.Proxy)
+	changes = appendUpdate(changes, "", "min_rsa_bits", fmt.Sprint(before.MinRSABits), fmt.Sprint(after.MinRSABits))
+	changes = appendUpdate(changes, "", "post_clone", before.PostClone, after.PostClone)
+	changes = appendUpdate(changes, "", "ssh_config_template", before.SSHConfigTemplate, after.SSHConfigTemplate)
+	changes = appendUpdate(changes, "", "enforce_default", fmt.Sprint(before.EnforceDefault), fmt.Sprint(after.EnforceDefault))
+
+	// organizations, by name
+	names := slices.Concat(before.OrganizationNames(), after.OrganizationNames())
+	slices.Sort(names)
+	for _, name := range slices.Compact(names) {
+		old, oldErr := before.GetOrganization(name)
+		updated, newErr := after.GetOrganization(name)
+		switch {
+		case oldErr != nil:
+			changes = append(changes, Change{Action: ChangeAdd, Org: name})
+		case newErr != nil:
+			changes = append(changes, Change{Action: ChangeRemove, Org: name})
+		default:
+			changes = appendUpdate(changes, name, "key", old.SSHKeyPath, updated.SSHKeyPath)
+			changes = appendUpdate(changes, name, "labeled keys", formatKeys(old.Keys), formatKeys(updated.Keys))
+			changes = appendUpdate(changes, name, "primary key", old.PrimaryKey, updated.PrimaryKey)
+			changes = appendUpdate(changes, name, "host", old.Host, updated.Host)
+			changes = appendUpdate(changes, name, "host alias", old.HostAlias, updated.HostAlias)
+			changes = appendUpdate(changes, name, "known_hosts", old.KnownHosts, updated.KnownHosts)
+			changes = appendUpdate(changes, name, "post_clone", old.PostClone, updated.PostClone)
+			changes = appendUpdate(changes, name, "identity_agent", old.IdentityAgent, updated.IdentityAgent)
+			changes = appendUpdate(changes, name, "connect_timeout", fmt.Sprint(old.ConnectTimeout), fmt.Sprint(updated.ConnectTimeout))
+			changes = appendUpdate(changes, name, "server_alive_interval", fmt.Sprint(old.ServerAliveInterval), fmt.Sprint(updated.ServerAliveInterval))
+			changes = appendUpdate(changes, name, "expires_at", formatTime(old.ExpiresAt), formatTime(updated.ExpiresAt))
+		}
+	}
+
+	// the default organization
+	if from, to := markedDefault(before), markedDefault(after); from != to {
+		changes = append(changes, Change{Action: ChangeDefault, From: from, To: to})
+	}
+	return changes
+}
+
+// appendUpdate appends an update of field to changes, if from and to differ.
+func appendUpdate(changes []Change, org, field, from, to string) []Change {
+	if from == to {
+		return changes
+	}
+	return append(changes, Change{Action: ChangeUpdate, Org: org, Field: field, From: from, To: to})
+}
+
+// formatKeys formats labeled keys as "label=path" pairs sorted by label.
+func formatKeys(keys map[string]string) string {
+	pairs := make([]string, 0, len(keys))
+	for _, label := range slices.Sorted(maps.Keys(keys)) {
+		pairs = append(pairs, label+"="+keys[label])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// formatTime formats an optional time as RFC 3339, or "" if it is unset.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// markedDefault returns the name of the first organization marked as the
+// default in the file, ignoring any runtime override, or "" if there is none.
+func markedDefault(c *Config) string {
+	for _, org := range c.Organizations {
+		if org.IsDefault {
+			return org.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			Organizations: []*Organization{
+				{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true},
+				{Name: "org2", SSHKeyPath: "/path/to/key2"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(c *Config)
+		expected []string
+	}{
+		{
+			name:     "no changes",
+			modify:   func(c *Config) {},
+			expected: []string{},
+		},
+		{
+			name: "add org",
+			modify: func(c *Config) {
+				c.Organizations = append(c.Organizations, &Organization{Name: "org3", SSHKeyPath: "/path/to/key3"})
+			},
+			expected: []string{"would add org org3"},
+		},
+		{
+			name: "remove org",
+			modify: func(c *Config) {
+				c.Organizations = c.Organizations[1:]
+			},
+			expected: []string{"would remove org org1", "would unset default org1"},
+		},
+		{
+			name: "update key",
+			modify: func(c *Config) {
+				c.Organizations[1].SSHKeyPath = "/path/to/new"
+			},
+			expected: []string{`would update key of org2: "/path/to/key2" -> "/path/to/new"`},
+		},
+		{
+			name: "change default",
+			modify: func(c *Config) {
+				c.Organizations[0].IsDefault = false
+				c.Organizations[1].IsDefault = true
+			},
+			expected: []string{"would change default from org1 to org2"},
+		},
+		{
+			name: "add default org",
+			modify: func(c *Config) {
+				c.Organizations[0].IsDefault = false
+				c.Organizations = append(c.Organizations, &Organization{Name: "org3", SSHKeyPath: "/path/to/key3", IsDefault: true})
+			},
+			expected: []string{"would add org org3", "would change default from org1 to org3"},
+		},
+		{
+			name: "config-wide and org settings",
+			modify: func(c *Config) {
+				c.Proxy = "socks5://proxy:1080"
+				c.Organizations[0].Host = "ghe.example.com"
+				c.Organizations[0].Keys = map[string]string{"write": "/w", "read": "/r"}
+			},
+			expected: []string{
+				`would update proxy: "" -> "socks5://proxy:1080"`,
+				`would update labeled keys of org1: "" -> "read=/r,write=/w"`,
+				`would update host of org1: "" -> "ghe.example.com"`,
+			},
+		},
+		{
+			name: "runtime override ignored",
+			modify: func(c *Config) {
+				c.DefaultOverride = "org2"
+			},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := base()
+			tt.modify(after)
+
+			got := []string{}
+			for _, change := range Plan(base(), after) {
+				got = append(got, change.String())
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
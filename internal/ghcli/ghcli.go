@@ -0,0 +1,105 @@
+// Package ghcli reads the accounts the GitHub CLI (gh) is logged in to, so
+// they can be used to bootstrap organizations.
+package ghcli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultHostsPath is where gh stores its logged in hosts, unless ConfigDirEnv is set.
+const DefaultHostsPath = "$HOME/.config/gh/hosts.yml"
+
+// ConfigDirEnv names the environment variable gh uses to move its configuration directory.
+const ConfigDirEnv = "GH_CONFIG_DIR"
+
+// githubHost is the host of github.com accounts.
+const githubHost = "github.com"
+
+var (
+	ErrHostsNotFound = errors.New("gh hosts file not found, run gh auth login first")
+	ErrNoAccounts    = errors.New("gh is not logged in to any account")
+)
+
+// Account is a GitHub account gh is logged in to.
+type Account struct {
+	Host   string // the GitHub host, such as github.com or a GitHub Enterprise server
+	User   string // the account's user name
+	Active bool   // whether gh uses this account for the host
+}
+
+// host is a host entry of gh's hosts.yml. Older releases of gh store a single
+// user per host; newer releases list every account under users, and keep the
+// active one in user.
+type host struct {
+	User  string               `yaml:"user"`
+	Users map[string]yaml.Node `yaml:"users"`
+}
+
+// HostsPath returns the path of gh's hosts file, in the directory named by
+// ConfigDirEnv if it is set, or DefaultHostsPath otherwise.
+func HostsPath() string {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+	return DefaultHostsPath
+}
+
+// ReadAccounts reads the accounts from gh's hosts file at path.
+// It returns ErrHostsNotFound if the file doesn't exist.
+func ReadAccounts(path string) ([]Account, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrHostsNotFound, path)
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseAccounts(f)
+}
+
+// ParseAccounts parses gh's hosts.yml from r and returns its accounts, sorted
+// by host and then user, with github.com first.
+// It returns ErrNoAccounts if there are none.
+func ParseAccounts(r io.Reader) ([]Account, error) {
+	var hosts map[string]host
+	if err := yaml.NewDecoder(r).Decode(&hosts); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing gh hosts: %w", err)
+	}
+
+	var accounts []Account
+	for name, h := range hosts {
+		users := slices.Collect(maps.Keys(h.Users))
+		if h.User != "" && !slices.Contains(users, h.User) {
+			users = append(users, h.User)
+		}
+		for _, user := range users {
+			accounts = append(accounts, Account{Host: name, User: user, Active: user == h.User})
+		}
+	}
+	if len(accounts) == 0 {
+		return nil, ErrNoAccounts
+	}
+
+	slices.SortFunc(accounts, func(a, b Account) int {
+		if a.Host != b.Host {
+			switch {
+			case a.Host == githubHost:
+				return -1
+			case b.Host == githubHost:
+				return 1
+			}
+			return strings.Compare(a.Host, b.Host)
+		}
+		return strings.Compare(a.User, b.User)
+	})
+	return accounts, nil
+}
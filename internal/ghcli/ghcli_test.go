@@ -0,0 +1,93 @@
+package ghcli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseAccounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		hosts     string
+		expected  []Account
+		expectErr error
+	}{
+		{
+			name: "multiple accounts",
+			hosts: `github.com:
+    git_protocol: ssh
+    users:
+        octocat:
+        hubot:
+    user: octocat
+ghe.example.com:
+    users:
+        monalisa:
+    user: monalisa
+`,
+			expected: []Account{
+				{Host: "github.com", User: "hubot"},
+				{Host: "github.com", User: "octocat", Active: true},
+				{Host: "ghe.example.com", User: "monalisa", Active: true},
+			},
+		},
+		{
+			name: "single account from older gh",
+			hosts: `github.com:
+    oauth_token: gho_secret
+    user: octocat
+    git_protocol: https
+`,
+			expected: []Account{{Host: "github.com", User: "octocat", Active: true}},
+		},
+		{name: "logged out", hosts: "", expectErr: ErrNoAccounts},
+		{name: "host without user", hosts: "github.com:\n    git_protocol: ssh\n", expectErr: ErrNoAccounts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAccounts(strings.NewReader(tt.hosts))
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+
+	// a file that isn't YAML is rejected
+	if got, err := ParseAccounts(strings.NewReader("github.com: [")); err == nil {
+		t.Errorf("expected a parse error, got %+v", got)
+	}
+}
+
+func TestReadAccounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yml")
+	if _, err := ReadAccounts(path); !errors.Is(err, ErrHostsNotFound) {
+		t.Errorf("expected %v, got %v", ErrHostsNotFound, err)
+	}
+
+	if err := os.WriteFile(path, []byte("github.com:\n    user: octocat\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := ReadAccounts(path)
+	if err != nil || len(accounts) != 1 || accounts[0].User != "octocat" {
+		t.Errorf("unexpected accounts %+v (%v)", accounts, err)
+	}
+}
+
+func TestHostsPath(t *testing.T) {
+	t.Setenv(ConfigDirEnv, "")
+	if got := HostsPath(); got != DefaultHostsPath {
+		t.Errorf("expected %s, got %s", DefaultHostsPath, got)
+	}
+	t.Setenv(ConfigDirEnv, "/custom/gh")
+	if got := HostsPath(); got != "/custom/gh/hosts.yml" {
+		t.Errorf("expected /custom/gh/hosts.yml, got %s", got)
+	}
+}
@@ -0,0 +1,273 @@
+// Package selfupdate checks GitHub for newer releases of ghc and replaces
+// the running binary with the latest release.
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// DefaultReleaseURL is the GitHub API endpoint for the latest ghc release.
+const DefaultReleaseURL = "https://api.github.com/repos/haukened/ghc/releases/latest"
+
+var (
+	ErrDevelopmentBuild = errors.New("cannot update a development build")
+	ErrInvalidVersion   = errors.New("invalid version")
+	ErrNoReleaseAsset   = errors.New("no release asset found for this platform")
+	ErrNoChecksum       = errors.New("no checksum found for the release asset")
+	ErrChecksumMismatch = errors.New("release asset doesn't match its checksum")
+)
+
+// HTTPClient is the subset of *http.Client used to talk to GitHub.
+// This allows a fake client to stand in for tests.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Release is the subset of a GitHub release used by the updater.
+type Release struct {
+	TagName string  `json:"tag_name"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a single downloadable file attached to a GitHub release.
+type Asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Updater checks for and installs new releases.
+// The network and filesystem parts are fields so they can be replaced in tests.
+type Updater struct {
+	Client     HTTPClient              // client used for all HTTP requests
+	ReleaseURL string                  // URL of the latest release in the GitHub API
+	GOOS       string                  // operating system to select the release asset for
+	GOARCH     string                  // architecture to select the release asset for
+	Replace    func(r io.Reader) error // installs the new binary read from r
+}
+
+// New returns an Updater for the running platform, using the default HTTP
+// client and replacing the running executable.
+func New() *Updater {
+	return &Updater{
+		Client:     http.DefaultClient,
+		ReleaseURL: DefaultReleaseURL,
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		Replace:    ReplaceExecutable,
+	}
+}
+
+// LatestRelease fetches the latest release from GitHub.
+func (u *Updater) LatestRelease(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.ReleaseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching latest release: unexpected status %s", resp.Status)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// Check compares the current version against the latest release.
+// It returns the latest release and whether it is newer than current.
+// It returns ErrDevelopmentBuild if current is not a release version.
+func (u *Updater) Check(ctx context.Context, current string) (*Release, bool, error) {
+	if current == "" || current == "development" {
+		return nil, false, ErrDevelopmentBuild
+	}
+
+	release, err := u.LatestRelease(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cmp, err := CompareVersions(release.TagName, current)
+	if err != nil {
+		return nil, false, err
+	}
+	return release, cmp > 0, nil
+}
+
+// Update downloads the release asset for this platform, checks it against the
+// SHA256 checksum in the asset of the same name ending in ".sha256", and only
+// then installs it with Replace.
+// It returns ErrNoReleaseAsset if the release has no asset for this platform,
+// ErrNoChecksum if that asset has no checksum, and ErrChecksumMismatch if the
+// download doesn't match it.
+func (u *Updater) Update(ctx context.Context, release *Release) error {
+	asset, checksum, err := u.selectAsset(release)
+	if err != nil {
+		return err
+	}
+
+	sum, err := u.download(ctx, checksum)
+	if err != nil {
+		return err
+	}
+	// the file holds the hex digest, optionally followed by the file name
+	fields := strings.Fields(string(sum))
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: %s is empty", ErrNoChecksum, checksum.Name)
+	}
+	want, err := hex.DecodeString(fields[0])
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("%w: %s doesn't hold a SHA256 checksum", ErrNoChecksum, checksum.Name)
+	}
+
+	binary, err := u.download(ctx, asset)
+	if err != nil {
+		return err
+	}
+	if got := sha256.Sum256(binary); !bytes.Equal(got[:], want) {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, asset.Name)
+	}
+
+	return u.Replace(bytes.NewReader(binary))
+}
+
+// download returns the content of a release asset.
+func (u *Updater) download(ctx context.Context, asset *Asset) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.BrowserDownloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: unexpected status %s", asset.Name, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// selectAsset returns the release asset built for this platform, and the
+// asset holding its checksum.
+// Assets are expected to be plain binaries named ending with the OS and
+// architecture, e.g. "ghc_linux_amd64" or "ghc_windows_amd64.exe", so
+// "ghc_linux_arm64" isn't taken for "arm". Each has a checksum asset named
+// after it, e.g. "ghc_linux_amd64.sha256".
+func (u *Updater) selectAsset(release *Release) (*Asset, *Asset, error) {
+	suffix := strings.ToLower("_" + u.GOOS + "_" + u.GOARCH)
+	var binary *Asset
+	for i, asset := range release.Assets {
+		name := strings.TrimSuffix(strings.ToLower(asset.Name), ".exe")
+		if strings.HasSuffix(name, suffix) {
+			binary = &release.Assets[i]
+			break
+		}
+	}
+	if binary == nil {
+		return nil, nil, fmt.Errorf("%w: %s/%s", ErrNoReleaseAsset, u.GOOS, u.GOARCH)
+	}
+	for i, asset := range release.Assets {
+		if strings.EqualFold(asset.Name, binary.Name+".sha256") {
+			return binary, &release.Assets[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: %s", ErrNoChecksum, binary.Name)
+}
+
+// versionPattern matches a semantic version with an optional "v" prefix and
+// ignores any pre-release or build suffix.
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)`)
+
+// CompareVersions compares two semantic versions, such as "v1.2.3" and "1.3.0".
+// It returns -1 if a is older than b, 0 if they are equal, and 1 if a is newer.
+// It returns ErrInvalidVersion if either version can't be parsed.
+func CompareVersions(a, b string) (int, error) {
+	av, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	bv, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range av {
+		if av[i] != bv[i] {
+			if av[i] < bv[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// parseVersion returns the major, minor and patch numbers of a version.
+func parseVersion(v string) ([3]int, error) {
+	matches := versionPattern.FindStringSubmatch(strings.TrimSpace(v))
+	if matches == nil {
+		return [3]int{}, fmt.Errorf("%w: %q", ErrInvalidVersion, v)
+	}
+	var parts [3]int
+	for i := range parts {
+		// the pattern guarantees these are numbers
+		parts[i], _ = strconv.Atoi(matches[i+1])
+	}
+	return parts, nil
+}
+
+// ReplaceExecutable replaces the running executable with the binary read from r.
+// The new binary is written next to the executable and renamed over it, so the
+// replacement is atomic on platforms that support it.
+func ReplaceExecutable(r io.Reader) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".ghc-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), exe)
+}
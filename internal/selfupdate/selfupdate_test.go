@@ -0,0 +1,198 @@
+package selfupdate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testAssets are the files of the release served by newTestServer: a binary
+// and its checksum for linux/amd64 and linux/arm64, where the arm64 one comes
+// first, a linux/arm binary, and an archive.
+var testAssets = map[string]string{
+	"ghc_linux_arm64":        "arm64 binary",
+	"ghc_linux_arm64.sha256": sha256Hex("arm64 binary") + "  ghc_linux_arm64\n",
+	"ghc_linux_amd64.tar.gz": "archive",
+	"ghc_linux_amd64":        "new binary",
+	"ghc_linux_amd64.sha256": sha256Hex("new binary") + "\n",
+	"ghc_linux_arm":          "arm binary",
+	"ghc_linux_arm.sha256":   sha256Hex("arm binary"),
+}
+
+// testAssetOrder is the order testAssets are listed in the release.
+var testAssetOrder = []string{
+	"ghc_linux_arm64", "ghc_linux_arm64.sha256", "ghc_linux_amd64.tar.gz",
+	"ghc_linux_amd64", "ghc_linux_amd64.sha256", "ghc_linux_arm", "ghc_linux_arm.sha256",
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTestServer serves a release with the given tag and the testAssets,
+// with any asset in assets replacing the one of the same name.
+func newTestServer(t *testing.T, tag string, assets map[string]string) *httptest.Server {
+	t.Helper()
+	files := maps.Clone(testAssets)
+	maps.Copy(files, assets)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc("/release", func(w http.ResponseWriter, r *http.Request) {
+		release := Release{TagName: tag}
+		for _, name := range testAssetOrder {
+			if _, ok := files[name]; ok {
+				release.Assets = append(release.Assets, Asset{Name: name, BrowserDownloadURL: server.URL + "/assets/" + name})
+			}
+		}
+		json.NewEncoder(w).Encode(release)
+	})
+	mux.HandleFunc("/assets/{name}", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.PathValue("name")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	})
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestUpdater returns an updater talking to server, which records the
+// replaced binary in replaced.
+func newTestUpdater(server *httptest.Server, replaced *bytes.Buffer) *Updater {
+	return &Updater{
+		Client:     server.Client(),
+		ReleaseURL: server.URL + "/release",
+		GOOS:       "linux",
+		GOARCH:     "amd64",
+		Replace: func(r io.Reader) error {
+			_, err := io.Copy(replaced, r)
+			return err
+		},
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b      string
+		expected  int
+		expectErr error
+	}{
+		{a: "v1.2.3", b: "v1.2.3", expected: 0},
+		{a: "v1.2.4", b: "1.2.3", expected: 1},
+		{a: "v1.2.3", b: "v1.10.0", expected: -1},
+		{a: "v2.0.0", b: "v1.99.99", expected: 1},
+		{a: "v1.2.3-rc1", b: "v1.2.3", expected: 0},
+		{a: "development", b: "v1.2.3", expectErr: ErrInvalidVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			got, err := CompareVersions(tt.a, tt.b)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	server := newTestServer(t, "v1.2.0", nil)
+	var replaced bytes.Buffer
+	updater := newTestUpdater(server, &replaced)
+
+	tests := []struct {
+		name      string
+		current   string
+		newer     bool
+		expectErr error
+	}{
+		{name: "older", current: "v1.1.0", newer: true},
+		{name: "same", current: "v1.2.0", newer: false},
+		{name: "newer", current: "v1.3.0", newer: false},
+		{name: "development", current: "development", expectErr: ErrDevelopmentBuild},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, newer, err := updater.Check(t.Context(), tt.current)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if newer != tt.newer {
+				t.Errorf("expected newer %v, got %v", tt.newer, newer)
+			}
+		})
+	}
+
+	if replaced.Len() != 0 {
+		t.Errorf("expected check not to replace the binary")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		goarch    string
+		assets    map[string]string
+		expected  string
+		expectErr error
+	}{
+		{name: "amd64", goarch: "amd64", expected: "new binary"},
+		{name: "arm isn't arm64", goarch: "arm", expected: "arm binary"},
+		{name: "arm64", goarch: "arm64", expected: "arm64 binary"},
+		{name: "no asset for this platform", goarch: "riscv64", expectErr: ErrNoReleaseAsset},
+		{
+			name:      "no checksum",
+			goarch:    "amd64",
+			assets:    map[string]string{"ghc_linux_amd64.sha256": ""},
+			expectErr: ErrNoChecksum,
+		},
+		{
+			name:      "invalid checksum",
+			goarch:    "amd64",
+			assets:    map[string]string{"ghc_linux_amd64.sha256": "not a checksum"},
+			expectErr: ErrNoChecksum,
+		},
+		{
+			name:      "checksum mismatch",
+			goarch:    "amd64",
+			assets:    map[string]string{"ghc_linux_amd64": "tampered binary"},
+			expectErr: ErrChecksumMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, "v1.2.0", tt.assets)
+			var replaced bytes.Buffer
+			updater := newTestUpdater(server, &replaced)
+			updater.GOARCH = tt.goarch
+
+			release, err := updater.LatestRelease(t.Context())
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			err = updater.Update(t.Context(), release)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if replaced.String() != tt.expected {
+				t.Errorf("expected %q to be installed, got %q", tt.expected, replaced.String())
+			}
+		})
+	}
+}
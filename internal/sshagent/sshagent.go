@@ -0,0 +1,57 @@
+// Package sshagent provides access to the keys loaded in a running ssh-agent.
+package sshagent
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var (
+	ErrAgentNotRunning = errors.New("SSH_AUTH_SOCK is not set, is ssh-agent running?")
+	ErrNoAgentKeys     = errors.New("no keys are loaded in ssh-agent")
+)
+
+// Agent is the subset of an ssh-agent used by ghc.
+// It is satisfied by agent.Agent, which allows a keyring to stand in for tests.
+type Agent interface {
+	List() ([]*agent.Key, error)
+}
+
+// Connect connects to the ssh-agent listening on SSH_AUTH_SOCK.
+// It returns the agent and a function that closes the connection.
+func Connect() (Agent, func() error, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, ErrAgentNotRunning
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return agent.NewClient(conn), conn.Close, nil
+}
+
+// ListKeys returns the keys loaded in the agent.
+// It returns ErrNoAgentKeys if the agent holds no keys.
+func ListKeys(a Agent) ([]*agent.Key, error) {
+	keys, err := a.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoAgentKeys
+	}
+	return keys, nil
+}
+
+// Fingerprint returns the SHA256 fingerprint of an agent key, in the same
+// format as `ssh-keygen -lf`.
+func Fingerprint(key *agent.Key) string {
+	return ssh.FingerprintSHA256(key)
+}
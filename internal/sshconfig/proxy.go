@@ -0,0 +1,59 @@
+package sshconfig
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidProxy = errors.New("invalid proxy")
+)
+
+// proxyHostPattern matches host names and IPv4 addresses. Anything else is
+// rejected, because the host ends up in a command run by ssh.
+var proxyHostPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+
+// ProxyOption returns a ProxyCommand option that tunnels SSH through the given proxy.
+// The proxy is given as socks5://host:port or http://host:port; a bare host:port
+// is treated as a SOCKS5 proxy. The command uses nc, as in:
+//
+//	ProxyCommand nc -X 5 -x proxy.example.com:1080 %h %p
+//
+// It returns ErrInvalidProxy if the scheme, host or port is invalid.
+func ProxyOption(proxy string) (Option, error) {
+	scheme, address, found := strings.Cut(proxy, "://")
+	if !found {
+		scheme, address = "socks5", proxy
+	}
+
+	// nc -X takes the proxy protocol: 5 for SOCKS5, connect for HTTPS CONNECT
+	var protocol string
+	switch strings.ToLower(scheme) {
+	case "socks5":
+		protocol = "5"
+	case "http":
+		protocol = "connect"
+	default:
+		return Option{}, fmt.Errorf("%w: unsupported scheme %q, use socks5 or http", ErrInvalidProxy, scheme)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return Option{}, fmt.Errorf("%w: %q is not in host:port form", ErrInvalidProxy, address)
+	}
+	if !proxyHostPattern.MatchString(host) {
+		return Option{}, fmt.Errorf("%w: invalid host %q", ErrInvalidProxy, host)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return Option{}, fmt.Errorf("%w: invalid port %q", ErrInvalidProxy, port)
+	}
+
+	return Option{
+		Key:   "ProxyCommand",
+		Value: fmt.Sprintf("nc -X %s -x %s %%h %%p", protocol, net.JoinHostPort(host, port)),
+	}, nil
+}
@@ -0,0 +1,61 @@
+package sshconfig
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestProxyOption(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxy     string
+		expected  string
+		expectErr error
+	}{
+		{name: "socks5", proxy: "socks5://proxy.example.com:1080", expected: "nc -X 5 -x proxy.example.com:1080 %h %p"},
+		{name: "http", proxy: "http://10.0.0.1:3128", expected: "nc -X connect -x 10.0.0.1:3128 %h %p"},
+		{name: "bare host defaults to socks5", proxy: "localhost:1080", expected: "nc -X 5 -x localhost:1080 %h %p"},
+		{name: "unsupported scheme", proxy: "ftp://proxy:21", expectErr: ErrInvalidProxy},
+		{name: "missing port", proxy: "socks5://proxy", expectErr: ErrInvalidProxy},
+		{name: "bad port", proxy: "socks5://proxy:99999", expectErr: ErrInvalidProxy},
+		{name: "shell metacharacters", proxy: "socks5://proxy;rm -rf ~:1080", expectErr: ErrInvalidProxy},
+		{name: "line break", proxy: "socks5://proxy\nHost *:1080", expectErr: ErrInvalidProxy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt, err := ProxyOption(tt.proxy)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if opt.Key != "ProxyCommand" || opt.Value != tt.expected {
+				t.Errorf("expected ProxyCommand %q, got %s %q", tt.expected, opt.Key, opt.Value)
+			}
+		})
+	}
+}
+
+func TestCreateSSHConfigFile_Proxy(t *testing.T) {
+	opt, err := ProxyOption("socks5://proxy.example.com:1080")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	configPath, err := CreateSSHConfigFile("github.com", "/path/to/key", t.TempDir(), opt)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read ssh config: %v", err)
+	}
+	expected := "\tProxyCommand nc -X 5 -x proxy.example.com:1080 %h %p\n"
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("expected ssh config to contain %q, got:\n%s", expected, content)
+	}
+}
@@ -1,41 +1,204 @@
 package sshconfig
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/google/uuid"
 )
 
-// createSSHConfigFile creates an SSH config file with a single host entry.
-// The file is created in a temporary directory and is always named "config".
+var (
+	ErrInvalidOption   = errors.New("invalid SSH option")
+	ErrInvalidTemplate = errors.New("invalid SSH config template")
+)
+
+// DefaultTemplate is the built-in template for the generated host block.
+// Custom templates given to ParseTemplate are rendered with the same TemplateData.
+const DefaultTemplate = `Host {{.Host}}
+	User {{.User}}
+	IdentityFile {{.KeyPath}}
+{{- if .IdentitiesOnly}}
+	IdentitiesOnly yes
+{{- end}}
+{{- range .Options}}
+	{{.Key}} {{.Value}}
+{{- end}}
+`
+
+// defaultTemplate is DefaultTemplate, parsed once.
+var defaultTemplate = template.Must(template.New("default").Parse(DefaultTemplate))
+
+// TemplateData is what an SSH config template is rendered with.
+type TemplateData struct {
+	Host           string   // the host to connect to, such as github.com
+	User           string   // the SSH user, which is always git for GitHub
+	KeyPath        string   // the path to the SSH key
+	IdentitiesOnly bool     // whether KeyPath is a public key, whose private half is held by an agent
+	Options        []Option // extra directives, such as proxies and known_hosts
+}
+
+// Option is an extra directive rendered in the generated host block,
+// such as "ConnectTimeout 10".
+type Option struct {
+	Key   string
+	Value string
+}
+
+// optionKeyPattern matches SSH config keywords, which are purely alphanumeric.
+var optionKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// ParseOption parses a KEY=VALUE string into an Option.
+// It returns ErrInvalidOption if the string is not a valid KEY=VALUE pair.
+func ParseOption(s string) (Option, error) {
+	key, value, found := strings.Cut(s, "=")
+	if !found {
+		return Option{}, fmt.Errorf("%w: %q is not in KEY=VALUE form", ErrInvalidOption, s)
+	}
+	opt := Option{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value)}
+	if err := opt.Validate(); err != nil {
+		return Option{}, err
+	}
+	return opt, nil
+}
+
+// Validate checks that the option can be safely rendered in an SSH config file.
+// The key must be an alphanumeric SSH keyword, and the value must be non-empty
+// and must not contain line breaks, which would allow injecting extra directives.
+func (o Option) Validate() error {
+	if !optionKeyPattern.MatchString(o.Key) {
+		return fmt.Errorf("%w: invalid key %q", ErrInvalidOption, o.Key)
+	}
+	if o.Value == "" {
+		return fmt.Errorf("%w: empty value for %s", ErrInvalidOption, o.Key)
+	}
+	if strings.ContainsAny(o.Value, "\r\n") {
+		return fmt.Errorf("%w: value for %s contains a line break", ErrInvalidOption, o.Key)
+	}
+	return nil
+}
+
+// createSSHConfigFile creates an SSH config file with a single host entry,
+// rendered with DefaultTemplate. See CreateSSHConfigFileFromTemplate.
+func CreateSSHConfigFile(sshHostName, sshKeyPath, configDir string, options ...Option) (string, error) {
+	return CreateSSHConfigFileFromTemplate(nil, sshHostName, sshKeyPath, configDir, options...)
+}
+
+// CreateSSHConfigFileFromTemplate creates an SSH config file with a single host
+// entry rendered with tmpl, or DefaultTemplate if tmpl is nil.
+// The file is created in configDir with a random name.
 // Parameters:
+// - tmpl: The template to render, from ParseTemplate.
 // - sshKeyPath: The path to the SSH key file.
 // - configDir: The directory where the SSH config file will be created.
-// Returns the path to the created SSH config file.
-func CreateSSHConfigFile(sshHostName, sshKeyPath, configDir string) (string, error) {
-	// create the file content
-	sshConfig := fmt.Sprintf(`Host %s
-	User git
-	IdentityFile %s`, sshHostName, sshKeyPath)
+// - options: Extra directives to render in the host block.
+// Returns the path to the created SSH config file. A template that fails to
+// render returns an error wrapping ErrInvalidTemplate, and no file is written.
+func CreateSSHConfigFileFromTemplate(tmpl *template.Template, sshHostName, sshKeyPath, configDir string, options ...Option) (string, error) {
+	// validate the options before writing anything
+	for _, opt := range options {
+		if err := opt.Validate(); err != nil {
+			return "", err
+		}
+	}
+	if tmpl == nil {
+		tmpl = defaultTemplate
+	}
 
-	// Check if the SSH key path ends with ".pub"
-	// If it does, add the "IdentitiesOnly yes" line
-	if strings.HasSuffix(sshKeyPath, ".pub") {
-		sshConfig += "\n\tIdentitiesOnly yes\n"
+	// create the file content
+	// a key path ending with ".pub" is a public key, so "IdentitiesOnly yes" is added
+	data := TemplateData{
+		Host:           sshHostName,
+		User:           "git",
+		KeyPath:        sshKeyPath,
+		IdentitiesOnly: strings.HasSuffix(sshKeyPath, ".pub"),
+		Options:        options,
+	}
+	var sshConfig strings.Builder
+	if err := tmpl.Execute(&sshConfig, data); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
 	}
 
 	// create the file path
 	sshConfigFilePath := filepath.Join(configDir, generateUUID())
 
 	// create the file
-	err := os.WriteFile(sshConfigFilePath, []byte(sshConfig), 0600)
+	err := os.WriteFile(sshConfigFilePath, []byte(sshConfig.String()), 0600)
 
 	return sshConfigFilePath, err
 }
 
+// ParseTemplate reads and parses the SSH config template at path, a Go
+// text/template rendered with TemplateData, such as:
+//
+//	Host {{.Host}}
+//		User {{.User}}
+//		IdentityFile {{.KeyPath}}
+//
+// Unknown fields fail when the template is parsed, rather than when a clone renders it.
+// It returns an error wrapping ErrInvalidTemplate if the template can't be parsed.
+func ParseTemplate(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	// render sample data, so mistakes such as unknown fields are caught up front
+	sample := TemplateData{Host: "github.com", User: "git", KeyPath: "/key", Options: []Option{{Key: "Key", Value: "value"}}}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return tmpl, nil
+}
+
+// KnownHostsOptions returns the options that pin host keys to the given known_hosts.
+// If inline is true, knownHosts holds known_hosts content, which is written to a
+// file in configDir; otherwise it is the path to an existing known_hosts file.
+// Strict host key checking is enabled so a host presenting any other key is rejected.
+// It returns the options and the path of the file written for inline content,
+// which is empty for a path. The caller is responsible for removing that file.
+func KnownHostsOptions(knownHosts string, inline bool, configDir string) ([]Option, string, error) {
+	knownHostsPath := knownHosts
+	var written string
+	if inline {
+		knownHostsPath = filepath.Join(configDir, generateUUID()+".known_hosts")
+		content := strings.TrimRight(knownHosts, "\n") + "\n"
+		if err := os.WriteFile(knownHostsPath, []byte(content), 0600); err != nil {
+			return nil, "", err
+		}
+		written = knownHostsPath
+	}
+
+	options := []Option{
+		{Key: "UserKnownHostsFile", Value: knownHostsPath},
+		{Key: "StrictHostKeyChecking", Value: "yes"},
+	}
+	return options, written, nil
+}
+
+// TimeoutOptions returns the options for an SSH connect timeout and keepalive
+// interval, both in seconds. Zero leaves ssh's own default in place, so no
+// option is returned for it.
+func TimeoutOptions(connectTimeout, serverAliveInterval int) []Option {
+	var options []Option
+	if connectTimeout > 0 {
+		options = append(options, Option{Key: "ConnectTimeout", Value: strconv.Itoa(connectTimeout)})
+	}
+	if serverAliveInterval > 0 {
+		options = append(options, Option{Key: "ServerAliveInterval", Value: strconv.Itoa(serverAliveInterval)})
+	}
+	return options
+}
+
 // extract the UUID generation logic into a variable
 // This allows for easier testing and mocking of UUID generation.
 var generateUUID = func() string {
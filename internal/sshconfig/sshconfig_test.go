@@ -0,0 +1,254 @@
+package sshconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCreateSSHConfigFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		sshKeyPath string
+		options    []Option
+		expected   string
+	}{
+		{
+			name:       "private key",
+			sshKeyPath: "/path/to/key",
+			expected:   "Host github.com\n\tUser git\n\tIdentityFile /path/to/key\n",
+		},
+		{
+			name:       "public key",
+			sshKeyPath: "/path/to/key.pub",
+			expected:   "Host github.com\n\tUser git\n\tIdentityFile /path/to/key.pub\n\tIdentitiesOnly yes\n",
+		},
+		{
+			name:       "multiple options",
+			sshKeyPath: "/path/to/key",
+			options: []Option{
+				{Key: "StrictHostKeyChecking", Value: "no"},
+				{Key: "ConnectTimeout", Value: "10"},
+			},
+			expected: "Host github.com\n\tUser git\n\tIdentityFile /path/to/key\n\tStrictHostKeyChecking no\n\tConnectTimeout 10\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := CreateSSHConfigFile("github.com", tt.sshKeyPath, t.TempDir(), tt.options...)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read ssh config: %v", err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("expected:\n%q\ngot:\n%q", tt.expected, content)
+			}
+		})
+	}
+}
+
+func TestCreateSSHConfigFile_InvalidOption(t *testing.T) {
+	options := []Option{{Key: "ConnectTimeout", Value: "10\nProxyCommand evil"}}
+
+	_, err := CreateSSHConfigFile("github.com", "/path/to/key", t.TempDir(), options...)
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected %v, got %v", ErrInvalidOption, err)
+	}
+}
+
+func TestCreateSSHConfigFileFromTemplate(t *testing.T) {
+	tmplPath := filepath.Join(t.TempDir(), "ssh.tmpl")
+	text := "Host {{.Host}}\n\tHostName ssh.{{.Host}}\n\tPort 443\n\tUser {{.User}}\n\tIdentityFile {{.KeyPath}}\n{{range .Options}}\t{{.Key}} {{.Value}}\n{{end}}"
+	if err := os.WriteFile(tmplPath, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := ParseTemplate(tmplPath)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	path, err := CreateSSHConfigFileFromTemplate(tmpl, "github.com", "/path/to/key", t.TempDir(), Option{Key: "ConnectTimeout", Value: "10"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read ssh config: %v", err)
+	}
+	expected := "Host github.com\n\tHostName ssh.github.com\n\tPort 443\n\tUser git\n\tIdentityFile /path/to/key\n\tConnectTimeout 10\n"
+	if string(content) != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, content)
+	}
+
+	// without a template, the built-in one is used
+	path, err = CreateSSHConfigFileFromTemplate(nil, "github.com", "/path/to/key", t.TempDir())
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "Host github.com\n\tUser git\n\tIdentityFile /path/to/key\n" {
+		t.Errorf("expected the built-in template, got %q", content)
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		expects error
+	}{
+		{name: "built-in template", text: DefaultTemplate},
+		{name: "syntax error", text: "Host {{.Host", expects: ErrInvalidTemplate},
+		{name: "unknown field", text: "Host {{.Hostname}}\n", expects: ErrInvalidTemplate},
+		{name: "unknown function", text: "Host {{upper .Host}}\n", expects: ErrInvalidTemplate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ssh.tmpl")
+			if err := os.WriteFile(path, []byte(tt.text), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ParseTemplate(path)
+			if !errors.Is(err, tt.expects) {
+				t.Errorf("expected %v, got %v", tt.expects, err)
+			}
+		})
+	}
+
+	if _, err := ParseTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected %v, got %v", os.ErrNotExist, err)
+	}
+}
+
+func TestParseOption(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  Option
+		expectErr error
+	}{
+		{
+			name:     "valid option",
+			input:    "ConnectTimeout=10",
+			expected: Option{Key: "ConnectTimeout", Value: "10"},
+		},
+		{
+			name:     "value containing equals",
+			input:    "SetEnv=FOO=bar",
+			expected: Option{Key: "SetEnv", Value: "FOO=bar"},
+		},
+		{
+			name:      "missing equals",
+			input:     "ConnectTimeout",
+			expectErr: ErrInvalidOption,
+		},
+		{
+			name:      "empty value",
+			input:     "ConnectTimeout=",
+			expectErr: ErrInvalidOption,
+		},
+		{
+			name:      "invalid key",
+			input:     "Connect Timeout=10",
+			expectErr: ErrInvalidOption,
+		},
+		{
+			name:      "newline injection",
+			input:     "ConnectTimeout=10\nProxyCommand evil",
+			expectErr: ErrInvalidOption,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt, err := ParseOption(tt.input)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if opt != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, opt)
+			}
+		})
+	}
+}
+
+func TestKnownHostsOptions(t *testing.T) {
+	const inline = "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+
+	t.Run("path", func(t *testing.T) {
+		configDir := t.TempDir()
+		options, written, err := KnownHostsOptions("/path/to/known_hosts", false, configDir)
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		if written != "" {
+			t.Errorf("expected no file to be written, got %s", written)
+		}
+		expected := []Option{
+			{Key: "UserKnownHostsFile", Value: "/path/to/known_hosts"},
+			{Key: "StrictHostKeyChecking", Value: "yes"},
+		}
+		if len(options) != len(expected) || options[0] != expected[0] || options[1] != expected[1] {
+			t.Errorf("expected %v, got %v", expected, options)
+		}
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		configDir := t.TempDir()
+		options, written, err := KnownHostsOptions(inline, true, configDir)
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		if filepath.Dir(written) != configDir {
+			t.Errorf("expected file in %s, got %s", configDir, written)
+		}
+		content, err := os.ReadFile(written)
+		if err != nil {
+			t.Fatalf("failed to read known_hosts: %v", err)
+		}
+		if string(content) != inline+"\n" {
+			t.Errorf("expected %q, got %q", inline+"\n", content)
+		}
+		expected := []Option{
+			{Key: "UserKnownHostsFile", Value: written},
+			{Key: "StrictHostKeyChecking", Value: "yes"},
+		}
+		if len(options) != len(expected) || options[0] != expected[0] || options[1] != expected[1] {
+			t.Errorf("expected %v, got %v", expected, options)
+		}
+	})
+}
+
+func TestTimeoutOptions(t *testing.T) {
+	tests := []struct {
+		name                string
+		connectTimeout      int
+		serverAliveInterval int
+		expected            []Option
+	}{
+		{name: "unset"},
+		{name: "connect timeout", connectTimeout: 10, expected: []Option{{Key: "ConnectTimeout", Value: "10"}}},
+		{name: "keepalive", serverAliveInterval: 15, expected: []Option{{Key: "ServerAliveInterval", Value: "15"}}},
+		{
+			name:                "both",
+			connectTimeout:      10,
+			serverAliveInterval: 15,
+			expected:            []Option{{Key: "ConnectTimeout", Value: "10"}, {Key: "ServerAliveInterval", Value: "15"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TimeoutOptions(tt.connectTimeout, tt.serverAliveInterval)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
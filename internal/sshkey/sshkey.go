@@ -0,0 +1,225 @@
+// Package sshkey inspects SSH key files to determine their type and size.
+package sshkey
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrInvalidKeyType  = errors.New("invalid key type")
+	ErrKeyTypeMismatch = errors.New("key type does not match")
+	ErrPairMismatch    = errors.New("public key does not match the private key")
+	ErrUnreadableKey   = errors.New("unable to determine the key type")
+)
+
+// keyTypes maps SSH public key algorithms to the short names used by ghc.
+var keyTypes = map[string]string{
+	ssh.KeyAlgoRSA:        "rsa",
+	ssh.KeyAlgoED25519:    "ed25519",
+	ssh.KeyAlgoECDSA256:   "ecdsa",
+	ssh.KeyAlgoECDSA384:   "ecdsa",
+	ssh.KeyAlgoECDSA521:   "ecdsa",
+	ssh.KeyAlgoSKED25519:  "ed25519-sk",
+	ssh.KeyAlgoSKECDSA256: "ecdsa-sk",
+}
+
+// Info describes an SSH key.
+type Info struct {
+	Type string // short type name, such as "ed25519" or "rsa"
+	Bits int    // key size in bits, only set for RSA keys
+}
+
+// String returns the key type, with the size for RSA keys, such as "rsa 4096".
+func (i Info) String() string {
+	if i.Bits > 0 {
+		return fmt.Sprintf("%s %d", i.Type, i.Bits)
+	}
+	return i.Type
+}
+
+// Inspect returns the type of the SSH key at path.
+// Public keys (".pub") are read directly. For private keys, the public half is
+// taken from the key itself, even if it is passphrase protected (OpenSSH format),
+// or otherwise from the matching ".pub" file next to it.
+// It returns ErrUnreadableKey if the type cannot be determined.
+func Inspect(path string) (Info, error) {
+	pub, err := publicKey(path)
+	if err != nil {
+		return Info{}, err
+	}
+
+	keyType, ok := keyTypes[pub.Type()]
+	if !ok {
+		return Info{}, fmt.Errorf("%w: unsupported algorithm %s", ErrUnreadableKey, pub.Type())
+	}
+	info := Info{Type: keyType}
+
+	if cpk, ok := pub.(ssh.CryptoPublicKey); ok {
+		if rsaKey, ok := cpk.CryptoPublicKey().(*rsa.PublicKey); ok {
+			info.Bits = rsaKey.N.BitLen()
+		}
+	}
+	return info, nil
+}
+
+// Fingerprint returns the SHA256 fingerprint of the SSH key at path, in the
+// same "SHA256:..." form as ssh-keygen -lf. The public half is found as for
+// Inspect, so passphrase protected keys can be fingerprinted too.
+// It returns an error wrapping ErrUnreadableKey if the key cannot be read.
+func Fingerprint(path string) (string, error) {
+	pub, err := publicKey(path)
+	if err != nil {
+		return "", err
+	}
+	return ssh.FingerprintSHA256(pub), nil
+}
+
+// Parse checks that data holds an SSH key: a private key, which may be
+// encrypted, or a public key in authorized_keys format. It reports whether
+// the key is a public key.
+// It returns an error wrapping ErrUnreadableKey if data holds neither.
+func Parse(data []byte) (bool, error) {
+	_, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if err == nil || errors.As(err, &missing) {
+		return false, nil
+	}
+	if _, _, _, _, pubErr := ssh.ParseAuthorizedKey(data); pubErr == nil {
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: %w", ErrUnreadableKey, err)
+}
+
+// publicKey reads the public half of the key at path.
+func publicKey(path string) (ssh.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(path, ".pub") {
+		pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrUnreadableKey, path, err)
+		}
+		return pub, nil
+	}
+
+	pub, err := privatePublicKey(data)
+	if err == nil {
+		return pub, nil
+	}
+
+	// fall back to the public key next to the private key
+	if _, statErr := os.Stat(path + ".pub"); statErr == nil {
+		return publicKey(path + ".pub")
+	}
+	return nil, fmt.Errorf("%w: %s: %w", ErrUnreadableKey, path, err)
+}
+
+// privatePublicKey derives the public half of the private key in data.
+func privatePublicKey(data []byte) (ssh.PublicKey, error) {
+	signer, err := ssh.ParsePrivateKey(data)
+	if err == nil {
+		return signer.PublicKey(), nil
+	}
+	// OpenSSH keys carry their public half unencrypted
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		return missing.PublicKey, nil
+	}
+	return nil, err
+}
+
+// CheckPair checks that the ".pub" file next to the private key at path holds
+// the public half of that key, which a botched copy of a key pair may not.
+// Nothing is checked if there is no ".pub" file, or if the public half can't
+// be derived from the private key, such as for a passphrase protected PEM key.
+// It returns an error wrapping ErrPairMismatch, with both fingerprints, if
+// the keys don't match, or ErrUnreadableKey if the ".pub" file can't be parsed.
+func CheckPair(path string) error {
+	pubData, err := os.ReadFile(path + ".pub")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	private, err := privatePublicKey(data)
+	if err != nil {
+		return nil
+	}
+	public, _, _, _, err := ssh.ParseAuthorizedKey(pubData)
+	if err != nil {
+		return fmt.Errorf("%w: %s.pub: %w", ErrUnreadableKey, path, err)
+	}
+
+	if privateFP, publicFP := ssh.FingerprintSHA256(private), ssh.FingerprintSHA256(public); privateFP != publicFP {
+		return fmt.Errorf("%w: %s is %s, but %s.pub is %s", ErrPairMismatch, path, privateFP, path, publicFP)
+	}
+	return nil
+}
+
+// Check verifies that the SSH key at path matches the required type.
+// The requirement is a type name (rsa, ed25519, ecdsa, ed25519-sk or ecdsa-sk),
+// optionally followed by a minimum size for RSA keys, as in "rsa:3072".
+// It returns ErrKeyTypeMismatch if the key doesn't match, or ErrInvalidKeyType
+// if the requirement itself is invalid.
+func Check(path, required string) error {
+	wantType, minBits, err := parseRequirement(required)
+	if err != nil {
+		return err
+	}
+
+	info, err := Inspect(path)
+	if err != nil {
+		return err
+	}
+
+	if info.Type != wantType {
+		return fmt.Errorf("%w: expected %s, got %s", ErrKeyTypeMismatch, wantType, info)
+	}
+	if info.Bits < minBits {
+		return fmt.Errorf("%w: expected at least %d bits, got %s", ErrKeyTypeMismatch, minBits, info)
+	}
+	return nil
+}
+
+// parseRequirement parses a key type requirement, such as "ed25519" or "rsa:3072".
+func parseRequirement(required string) (string, int, error) {
+	wantType, bits, hasBits := strings.Cut(strings.ToLower(strings.TrimSpace(required)), ":")
+
+	known := false
+	for _, keyType := range keyTypes {
+		if keyType == wantType {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return "", 0, fmt.Errorf("%w: %q", ErrInvalidKeyType, required)
+	}
+
+	if !hasBits {
+		return wantType, 0, nil
+	}
+	if wantType != "rsa" {
+		return "", 0, fmt.Errorf("%w: a minimum size is only supported for rsa keys", ErrInvalidKeyType)
+	}
+	minBits, err := strconv.Atoi(bits)
+	if err != nil || minBits < 1 {
+		return "", 0, fmt.Errorf("%w: invalid size %q", ErrInvalidKeyType, bits)
+	}
+	return wantType, minBits, nil
+}
@@ -0,0 +1,285 @@
+package sshkey
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ghc/internal/utils"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeEd25519Key writes an OpenSSH ed25519 private key, protected with
+// passphrase if it is not empty, and returns its path.
+func writeEd25519Key(t *testing.T, passphrase string) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return path
+}
+
+func TestInspect(t *testing.T) {
+	rsaKey, rsaPub := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected Info
+	}{
+		{name: "rsa private key", path: rsaKey, expected: Info{Type: "rsa", Bits: 2048}},
+		{name: "rsa public key", path: rsaPub, expected: Info{Type: "rsa", Bits: 2048}},
+		{name: "ed25519 private key", path: writeEd25519Key(t, ""), expected: Info{Type: "ed25519"}},
+		{name: "encrypted ed25519 private key", path: writeEd25519Key(t, "secret"), expected: Info{Type: "ed25519"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Inspect(tt.path)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if info != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, info)
+			}
+		})
+	}
+
+	// a file that isn't a key
+	notKey := filepath.Join(t.TempDir(), "not_a_key")
+	utils.WriteConfigFileForTest(t, notKey, []byte("foo"))
+	if _, err := Inspect(notKey); !errors.Is(err, ErrUnreadableKey) {
+		t.Errorf("expected %v, got %v", ErrUnreadableKey, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rsaKey, _ := utils.GenerateTestSSHKey(t)
+	edKey := writeEd25519Key(t, "")
+
+	tests := []struct {
+		name      string
+		path      string
+		required  string
+		expectErr error
+	}{
+		{name: "ed25519 matches", path: edKey, required: "ed25519"},
+		{name: "rsa matches", path: rsaKey, required: "rsa"},
+		{name: "rsa size matches", path: rsaKey, required: "rsa:2048"},
+		{name: "case insensitive", path: edKey, required: "ED25519"},
+		{name: "rsa is not ed25519", path: rsaKey, required: "ed25519", expectErr: ErrKeyTypeMismatch},
+		{name: "ed25519 is not rsa", path: edKey, required: "rsa", expectErr: ErrKeyTypeMismatch},
+		{name: "rsa too small", path: rsaKey, required: "rsa:3072", expectErr: ErrKeyTypeMismatch},
+		{name: "unknown type", path: rsaKey, required: "dsa", expectErr: ErrInvalidKeyType},
+		{name: "size for non rsa", path: edKey, required: "ed25519:256", expectErr: ErrInvalidKeyType},
+		{name: "invalid size", path: rsaKey, required: "rsa:big", expectErr: ErrInvalidKeyType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.path, tt.required)
+			if !errors.Is(err, tt.expectErr) {
+				t.Errorf("expected %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	// a fixed key, so the fingerprint is known: ssh-keygen -lf gives the same value
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	const expected = "SHA256:lbmsoA0yIEcEiVDRnMWuzm+nV+3ZEEpVIURqFoeSspg"
+
+	dir := t.TempDir()
+	writeKey := func(name string, block *pem.Block) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("failed to write key: %v", err)
+		}
+		return path
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	plain := writeKey("id_plain", block)
+	block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	encrypted := writeKey("id_encrypted", block)
+	junk := writeKey("id_junk", &pem.Block{Type: "JUNK", Bytes: []byte("junk")})
+
+	pub, err := ssh.NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatalf("failed to create public key: %v", err)
+	}
+	pubPath := filepath.Join(dir, "id_plain.pub")
+	if err := os.WriteFile(pubPath, ssh.MarshalAuthorizedKey(pub), 0644); err != nil {
+		t.Fatalf("failed to write public key: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		expectErr error
+	}{
+		{name: "unencrypted key", path: plain},
+		{name: "encrypted key", path: encrypted},
+		{name: "public key", path: pubPath},
+		{name: "not a key", path: junk, expectErr: ErrUnreadableKey},
+		{name: "missing key", path: filepath.Join(dir, "missing"), expectErr: os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Fingerprint(tt.path)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr == nil && got != expected {
+				t.Errorf("expected %s, got %s", expected, got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	privateKey, publicKey := utils.GenerateTestSSHKey(t)
+	encrypted := writeEd25519Key(t, "secret")
+	read := func(path string) []byte {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read key: %v", err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name         string
+		data         []byte
+		expectPublic bool
+		expectErr    error
+	}{
+		{name: "private key", data: read(privateKey)},
+		{name: "encrypted private key", data: read(encrypted)},
+		{name: "public key", data: read(publicKey), expectPublic: true},
+		{name: "junk", data: []byte("<html>login required</html>"), expectErr: ErrUnreadableKey},
+		{name: "empty", data: nil, expectErr: ErrUnreadableKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			public, err := Parse(tt.data)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if public != tt.expectPublic {
+				t.Errorf("expected public %v, got %v", tt.expectPublic, public)
+			}
+		})
+	}
+}
+
+func TestCheckPair(t *testing.T) {
+	// writePub writes the public half of the private key at from next to the key at to
+	writePub := func(t *testing.T, from, to string) {
+		t.Helper()
+		pub, err := publicKey(from)
+		if err != nil {
+			t.Fatalf("failed to read key: %v", err)
+		}
+		if err := os.WriteFile(to+".pub", ssh.MarshalAuthorizedKey(pub), 0644); err != nil {
+			t.Fatalf("failed to write public key: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		setup     func(t *testing.T) string // returns the private key path
+		expectErr error
+	}{
+		{
+			name: "matching pair",
+			setup: func(t *testing.T) string {
+				key := writeEd25519Key(t, "")
+				writePub(t, key, key)
+				return key
+			},
+		},
+		{
+			name: "encrypted matching pair",
+			setup: func(t *testing.T) string {
+				key := writeEd25519Key(t, "secret")
+				writePub(t, key, key)
+				return key
+			},
+		},
+		{
+			name: "mismatched pair",
+			setup: func(t *testing.T) string {
+				key := writeEd25519Key(t, "")
+				writePub(t, writeEd25519Key(t, ""), key)
+				return key
+			},
+			expectErr: ErrPairMismatch,
+		},
+		{
+			name: "mismatched rsa pair",
+			setup: func(t *testing.T) string {
+				key, _ := utils.GenerateTestSSHKey(t)
+				other, _ := utils.GenerateTestSSHKey(t)
+				writePub(t, other, key)
+				return key
+			},
+			expectErr: ErrPairMismatch,
+		},
+		{
+			name:  "no public key",
+			setup: func(t *testing.T) string { return writeEd25519Key(t, "") },
+		},
+		{
+			name: "unreadable public key",
+			setup: func(t *testing.T) string {
+				key := writeEd25519Key(t, "")
+				if err := os.WriteFile(key+".pub", []byte("junk"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return key
+			},
+			expectErr: ErrUnreadableKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckPair(tt.setup(t)); !errors.Is(err, tt.expectErr) {
+				t.Errorf("expected %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
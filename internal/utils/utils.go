@@ -7,11 +7,17 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -91,3 +97,66 @@ func ExpandPath(path string) string {
 	// then expand the path
 	return os.ExpandEnv(path)
 }
+
+// NewColor returns color.New(attrs...), following color.NoColor as set by
+// the "color" flag. color.New turns color off whenever NO_COLOR is set, which
+// would otherwise win over an explicit "--color always".
+func NewColor(attrs ...color.Attribute) *color.Color {
+	c := color.New(attrs...)
+	if !color.NoColor {
+		c.EnableColor()
+	}
+	return c
+}
+
+// IsTerminal reports whether the reader or writer v is an interactive terminal.
+// This can be overridden in tests.
+var IsTerminal = func(v any) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// Confirm asks a yes/no question on w and reads the answer from r. Anything
+// but "y" or "yes" is a no. When r isn't a terminal, such as in scripts,
+// nobody can answer, so it doesn't ask: a destructive change, one that
+// removes something, is declined, and any other change goes ahead.
+func Confirm(r io.Reader, w io.Writer, question string, destructive bool) bool {
+	if !IsTerminal(r) {
+		return !destructive
+	}
+	fmt.Fprintf(w, "%s [y/N]: ", question)
+	var answer string
+	fmt.Fscanln(r, &answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// ParseDuration parses a positive duration, such as a --expire or --since
+// flag. Besides the units of time.ParseDuration, it accepts whole days and
+// weeks, such as 30d or 2w. It reports false unless s is such a duration.
+func ParseDuration(s string) (time.Duration, bool) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	var d time.Duration
+	var err error
+	if unit != 0 {
+		var n int
+		n, err = strconv.Atoi(s[:len(s)-1])
+		d = time.Duration(n) * unit
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"ghc/internal/clone"
+	"ghc/internal/configfile"
+
+	"github.com/urfave/cli/v3"
+)
+
+// detectOrg returns the organization of the repository in the current directory.
+// This can be overridden in tests.
+var detectOrg = clone.DetectOrgFromCWD
+
+// printKey prints the SSH key path for the repository in the current
+// directory, such as for ssh-add "$(ghc key)". The organization is detected
+// from the origin remote, or the first remote if there is no origin, and must
+// be configured: unlike clone, the default organization is never used instead,
+// as the key would not be this repository's.
+// The "key-label" flag selects one of the organization's labeled keys.
+//
+// Returns an error wrapping clone.ErrNoOriginRemote outside a clone, or
+// domain.ErrOrganizationNotFound if the organization isn't configured.
+func printKey(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 0 {
+		return fmt.Errorf("%w: expected 0, got %d", ErrNumArguments, c.NArg())
+	}
+
+	orgName, err := detectOrg()
+	if err != nil {
+		return err
+	}
+	return printOrgKey(c, orgName)
+}
+
+// printOrgKeyPath prints the SSH key path of the named organization, such as
+// for ssh-add "$(ghc org key-path my-org)". Like printKey, it never falls back
+// to the default organization. The "key-label" flag selects one of the
+// organization's labeled keys.
+//
+// Returns an error if the arguments are invalid, the configuration can't be
+// loaded, or domain.ErrOrganizationNotFound if the organization isn't configured.
+func printOrgKeyPath(ctx context.Context, c *cli.Command) error {
+	const nargs = 1
+	if c.NArg() != nargs {
+		return fmt.Errorf("%w: expected %d, got %d", ErrNumArguments, nargs, c.NArg())
+	}
+	return printOrgKey(c, c.Args().Get(0))
+}
+
+// printOrgKey prints the SSH key path of the organization named orgName, or
+// its key with the label given by the "key-label" flag. Only that organization
+// is used, never the default, as the key would not be the one asked for.
+func printOrgKey(c *cli.Command, orgName string) error {
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+	conf, err := configfile.LoadConfigChecked(configPath, c.Bool("strict-config-permissions"), c.Root().ErrWriter)
+	if err != nil {
+		return err
+	}
+
+	org, err := conf.GetOrganization(orgName)
+	if err != nil {
+		return err
+	}
+	keyPath, err := org.KeyPath(c.String("key-label"))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(c.Root().Writer, keyPath)
+	return err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"ghc/internal/clone"
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestPrintKey(t *testing.T) {
+	orgKey, _ := utils.GenerateTestSSHKey(t)
+	writeKey, _ := utils.GenerateTestSSHKey(t)
+	defaultKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		org       string
+		detectErr error
+		args      []string
+		expected  string
+		expectErr error
+	}{
+		{name: "configured org", org: "org1", args: []string{"key"}, expected: orgKey + "\n"},
+		{name: "labeled key", org: "org1", args: []string{"key", "--key-label", "write"}, expected: writeKey + "\n"},
+		{name: "not in a repository", detectErr: clone.ErrNoOriginRemote, args: []string{"key"}, expectErr: clone.ErrNoOriginRemote},
+		{name: "org not configured", org: "unknown", args: []string{"key"}, expectErr: domain.ErrOrganizationNotFound},
+		{name: "unexpected argument", org: "org1", args: []string{"key", "org1"}, expectErr: ErrNumArguments},
+	}
+
+	oldDetectOrg := detectOrg
+	t.Cleanup(func() { detectOrg = oldDetectOrg })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+			if err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+				if err := cfg.SetOrganization("default-org", defaultKey, true); err != nil {
+					return err
+				}
+				if err := cfg.SetOrganization("org1", orgKey, false); err != nil {
+					return err
+				}
+				org, err := cfg.GetOrganization("org1")
+				if err != nil {
+					return err
+				}
+				org.Keys = map[string]string{"write": writeKey}
+				return nil
+			}); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			detectOrg = func() (string, error) { return tt.org, tt.detectErr }
+
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "key",
+				Action: printKey,
+				Writer: &out,
+				Flags:  []cli.Flag{&cli.StringFlag{Name: "key-label"}},
+			}
+			err := cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestPrintOrgKeyPath(t *testing.T) {
+	orgKey, _ := utils.GenerateTestSSHKey(t)
+	writeKey, _ := utils.GenerateTestSSHKey(t)
+	defaultKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		args      []string
+		expected  string
+		expectErr error
+	}{
+		{name: "configured org", args: []string{"key-path", "org1"}, expected: orgKey + "\n"},
+		{name: "default org", args: []string{"key-path", "default-org"}, expected: defaultKey + "\n"},
+		{name: "labeled key", args: []string{"key-path", "--key-label", "write", "org1"}, expected: writeKey + "\n"},
+		{name: "unknown label", args: []string{"key-path", "--key-label", "read", "org1"}, expectErr: domain.ErrKeyLabelNotFound},
+		{name: "org not configured", args: []string{"key-path", "unknown"}, expectErr: domain.ErrOrganizationNotFound},
+		{name: "missing argument", args: []string{"key-path"}, expectErr: ErrNumArguments},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+			if err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+				if err := cfg.SetOrganization("default-org", defaultKey, true); err != nil {
+					return err
+				}
+				if err := cfg.SetOrganization("org1", orgKey, false); err != nil {
+					return err
+				}
+				org, err := cfg.GetOrganization("org1")
+				if err != nil {
+					return err
+				}
+				org.Keys = map[string]string{"write": writeKey}
+				return nil
+			}); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "key-path",
+				Action: printOrgKeyPath,
+				Writer: &out,
+				Flags:  []cli.Flag{&cli.StringFlag{Name: "key-label"}},
+			}
+			err := cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"ghc/internal/clone"
+	"ghc/internal/configfile"
+	"ghc/internal/ghcli"
 	"os"
 
 	"github.com/urfave/cli/v3"
@@ -22,13 +24,48 @@ func init() {
 }
 
 func main() {
-	app := &cli.Command{
+	app := newApp()
+	if err := app.Run(context.Background(), os.Args); err != nil {
+		writeError(os.Stderr, err, app.Bool("json-errors"))
+		os.Exit(1)
+	}
+}
+
+// newApp returns the ghc command with all of its subcommands and flags.
+func newApp() *cli.Command {
+	return &cli.Command{
 		Name:                  "ghc",
 		Version:               version,
 		Copyright:             "(c) 2025 David Haukeness, distributed under the GNU General Public License v3.0",
 		Usage:                 "Clone GitHub repositories with SSH keys for different organizations",
 		UsageText:             "ghc <command> [command options] [arguments...]",
 		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "Path to the configuration file (default: " + configfile.DefaultConfigPath + ")",
+			},
+			&cli.StringFlag{
+				Name:    "profile",
+				Usage:   "Use the named profile's configuration file, such as work.conf next to the default configuration",
+				Sources: cli.EnvVars(configfile.ProfileEnv),
+			},
+			&cli.BoolFlag{
+				Name:  "strict-config-permissions",
+				Usage: "Refuse to load a configuration file that other users can access, instead of warning",
+			},
+			&cli.BoolFlag{
+				Name:    "json-errors",
+				Usage:   "Write errors as JSON with a stable kind, for scripts",
+				Sources: cli.EnvVars("GHC_JSON_ERRORS"),
+			},
+			&cli.StringFlag{
+				Name:  "color",
+				Value: "auto",
+				Usage: "Color output: auto (only on a terminal), always or never",
+			},
+		},
+		Before: setColorMode,
 		Commands: []*cli.Command{
 			{
 				Name:     "organization",
@@ -46,14 +83,109 @@ func main() {
 								Aliases: []string{"D"},
 								Usage:   "Set this organization as the default",
 							},
+							&cli.StringFlag{
+								Name:  "from-pub",
+								Usage: "Derive the SSH key path from the given public key path",
+							},
+							&cli.BoolFlag{
+								Name:  "from-agent",
+								Usage: "Select the SSH key from the keys loaded in ssh-agent",
+							},
+							&cli.StringFlag{
+								Name:  "move-key",
+								Usage: "Move the organization's SSH key file to this path and point the organization at it",
+							},
+							&cli.StringFlag{
+								Name:  "key-url",
+								Usage: "Download the SSH key from this https:// URL into a file managed by ghc",
+							},
+							&cli.BoolFlag{
+								Name:  "default-if-first",
+								Usage: "Set this organization as the default if it is the only one",
+							},
+							&cli.BoolFlag{
+								Name:  "replace-key-only",
+								Usage: "Only replace the SSH key of an existing organization, keeping its other settings",
+							},
+							&cli.BoolFlag{
+								Name:  "replace",
+								Usage: "Replace the whole definition of an existing organization, clearing any settings not given, except its default status",
+							},
+							&cli.BoolFlag{
+								Name:  "canonicalize",
+								Usage: "Trim and lowercase the organization name before storing it, so MyOrg and myorg are one organization",
+							},
+							&cli.StringFlag{
+								Name:  "host",
+								Usage: "SSH host to clone this organization's repositories from, such as a GitHub Enterprise server",
+							},
+							&cli.StringFlag{
+								Name:  "host-alias",
+								Usage: "Clone through this Host alias from ~/.ssh/config, using its key instead of one set here",
+							},
+							&cli.BoolFlag{
+								Name:  "validate-key-remote",
+								Usage: "Confirm GitHub accepts the key with ssh -T before saving the organization",
+							},
+							&cli.BoolFlag{
+								Name:  "plan",
+								Usage: "Show what would change, without writing the configuration",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Print the resulting configuration, without writing it",
+							},
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "Replace an existing organization's SSH key without asking",
+							},
+							&cli.StringFlag{
+								Name:  "key-type",
+								Usage: "Require the key to be of this type (rsa, ed25519, ecdsa, ed25519-sk, ecdsa-sk), or rsa:BITS for a minimum RSA size",
+							},
+							&cli.StringFlag{
+								Name:  "expire",
+								Usage: "Stop using the organization's keys after this long, such as 30d, 2w or 12h, for temporary access, or never to clear an expiry",
+							},
+							&cli.BoolFlag{
+								Name:  "insecure-skip-key-check",
+								Usage: "Store the organization without checking that its SSH key exists and is usable, for keys that will be placed later",
+							},
 						},
-						ArgsUsage: "ORG_NAME SSH_KEY_PATH",
+						ArgsUsage: "ORG_NAME [SSH_KEY_PATH]",
 					},
 					{
 						Name:    "list",
 						Aliases: []string{"ls"},
 						Usage:   "List all organizations in the configuration",
 						Action:  listOrganizations,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "Output format: table, json, yaml or tsv",
+								Value: "table",
+							},
+							&cli.BoolFlag{
+								Name:  "missing",
+								Usage: "Only list organizations whose SSH keys are missing or mis-permissioned, failing if there are any",
+							},
+							&cli.BoolFlag{
+								Name:  "fingerprint",
+								Usage: "Show the SHA256 fingerprint of each organization's SSH key",
+							},
+						},
+					},
+					{
+						Name:      "diff",
+						Usage:     "Show how the organizations in a file differ from the configuration, without applying them",
+						Action:    diffOrganizations,
+						ArgsUsage: "FILE",
+					},
+					{
+						Name:   "count",
+						Usage:  "Print the number of configured organizations",
+						Action: countOrganizations,
 					},
 					{
 						Name:      "remove",
@@ -61,27 +193,273 @@ func main() {
 						Usage:     "Remove an organization from the configuration",
 						Action:    removeOrganization,
 						ArgsUsage: "ORG_NAME",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Print the resulting configuration, without writing it",
+							},
+						},
+					},
+					{
+						Name:      "move",
+						Aliases:   []string{"mv"},
+						Usage:     "Move an organization up, down, to the top or to the bottom of the list",
+						Action:    moveOrganization,
+						ArgsUsage: "ORG_NAME <up|down|top|bottom>",
+					},
+					{
+						Name:      "rename",
+						Usage:     "Rename an organization, keeping its key and settings",
+						Action:    renameOrganization,
+						ArgsUsage: "ORG_NAME NEW_NAME",
+					},
+					{
+						Name:      "export",
+						Usage:     "Export a single organization as JSON",
+						Action:    exportOrganization,
+						ArgsUsage: "ORG_NAME",
+					},
+					{
+						Name:      "key-path",
+						Usage:     "Print the SSH key path of an organization",
+						Action:    printOrgKeyPath,
+						ArgsUsage: "ORG_NAME",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "key-label",
+								Usage: "Print the organization's key with this label instead of its primary key",
+							},
+						},
 					},
 				},
 			},
 			{
 				Name:      "clone",
 				Category:  "Repository Management",
-				Usage:     "Clone a GitHub repository using the specified SSH key",
+				Usage:     "Clone GitHub repositories using the specified SSH key",
 				Action:    clone.CloneRepo,
-				ArgsUsage: "REPO_URL",
+				ArgsUsage: "REPO_URL [REPO_URL...]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "keep-config",
+						Usage: "Keep the generated SSH config file after cloning, for debugging",
+					},
+					&cli.BoolFlag{
+						Name:  "config-only",
+						Usage: "Generate the SSH config file and print its path without cloning",
+					},
+					&cli.StringFlag{
+						Name:  "ssh-config-template",
+						Usage: "Render the generated SSH config with this Go text/template file instead of the built-in one",
+					},
+					&cli.BoolFlag{
+						Name:  "stdin",
+						Usage: "Also read repository URLs from stdin, one per line",
+					},
+					&cli.BoolFlag{
+						Name:  "stdin-null",
+						Usage: "Also read repository URLs from stdin, separated by NUL bytes as from find -print0",
+					},
+					&cli.StringFlag{
+						Name:  "from-file",
+						Usage: "Also read repository URLs from this file, one per line, skipping blank lines and # comments",
+					},
+					&cli.StringFlag{
+						Name:  "since",
+						Usage: "With --from-file, only clone repositories pushed within this window, such as 30d, from URL<TAB>LAST_PUSHED lines",
+					},
+					&cli.BoolFlag{
+						Name:  "print-org",
+						Usage: "Print the organization parsed from each repository URL without cloning",
+					},
+					&cli.StringFlag{
+						Name:  "key",
+						Usage: "Clone with this SSH key, bypassing the configuration entirely",
+					},
+					&cli.StringFlag{
+						Name:  "key-label",
+						Usage: "Clone with the organization's key with this label instead of its primary key",
+					},
+					&cli.BoolFlag{
+						Name:  "config-stdin",
+						Usage: "Read the whole configuration as JSON from stdin and use it in memory, never touching the config file",
+					},
+					&cli.StringFlag{
+						Name:  "save-org",
+						Usage: "Save unconfigured organizations with this SSH key, then clone",
+					},
+					&cli.BoolFlag{
+						Name:  "dest-exists-ok",
+						Usage: "Succeed without cloning if the destination already exists, like --on-exists=skip",
+					},
+					&cli.StringFlag{
+						Name:  "on-exists",
+						Usage: "What to do when the destination already exists: error, skip, pull or overwrite",
+						Value: "error",
+					},
+					&cli.StringFlag{
+						Name:  "filter",
+						Usage: "Partial clone filter, such as blob:none, blob:limit=1m or tree:0",
+					},
+					&cli.IntFlag{
+						Name:  "depth",
+						Usage: "Only clone the most recent N commits",
+					},
+					&cli.StringFlag{
+						Name:  "checkout",
+						Usage: "Check out this branch, tag or commit after cloning",
+					},
+					&cli.StringFlag{
+						Name:  "origin",
+						Usage: "Name the remote cloned from this instead of origin, such as upstream",
+					},
+					&cli.StringFlag{
+						Name:  "report-file",
+						Usage: "Write the outcome of every repository as JSON to this file, even if some clones fail",
+					},
+					&cli.BoolFlag{
+						Name:  "ndjson",
+						Usage: "Write a JSON event to stdout as each clone starts and completes, one per line, sending the clone output to stderr",
+					},
+					&cli.StringFlag{
+						Name:  "push-url",
+						Usage: "After cloning, push to this URL, such as an internal mirror, instead of the one cloned from",
+					},
+					&cli.BoolFlag{
+						Name:  "sparse",
+						Usage: "Check out only the paths given with --sparse-path",
+					},
+					&cli.StringSliceFlag{
+						Name:  "sparse-path",
+						Usage: "Directory to check out with --sparse (repeatable)",
+					},
+					&cli.BoolFlag{
+						Name:  "print-default-branch",
+						Usage: "Print the repository's default branch after cloning",
+					},
+					&cli.BoolFlag{
+						Name:  "write-marker",
+						Usage: "Write a .ghc file into the clone recording its organization, host, key and clone time",
+					},
+					&cli.BoolFlag{
+						Name:  "no-hooks",
+						Usage: "Don't run the post-clone hook",
+					},
+					&cli.BoolFlag{
+						Name:  "ignore-hook-errors",
+						Usage: "Report a failing post-clone hook without failing the clone",
+					},
+					&cli.StringFlag{
+						Name:    "proxy",
+						Usage:   "Clone through a proxy, as socks5://HOST:PORT or http://HOST:PORT",
+						Sources: cli.EnvVars("GHC_PROXY"),
+					},
+					&cli.BoolFlag{
+						Name:  "fix-permissions",
+						Usage: "Restrict the generated SSH config directory to 0700 if other users can access it, instead of failing",
+					},
+					&cli.BoolFlag{
+						Name:  "verbose-ssh",
+						Usage: "Log SSH connection details (LogLevel DEBUG3), for debugging authentication failures",
+					},
+					&cli.BoolFlag{
+						Name:  "trace",
+						Usage: "Before running git, print its exact command, working directory, SSH command and SSH config to stderr, for bug reports",
+					},
+					&cli.StringSliceFlag{
+						Name:  "ssh-option",
+						Usage: "Extra SSH config directive as KEY=VALUE, for this clone only (repeatable)",
+					},
+					&cli.BoolFlag{
+						Name:  "recurse-submodules",
+						Usage: "Also clone the repository's submodules, or update them with --on-exists=pull",
+					},
+					&cli.IntFlag{
+						Name:  "jobs",
+						Usage: "Number of submodules to fetch at once, passed to git as --jobs",
+					},
+					&cli.IntFlag{
+						Name:  "parallel",
+						Usage: "Number of repositories to clone at once when cloning more than one",
+						Value: 1,
+					},
+				},
+			},
+			{
+				Name:     "key",
+				Category: "Repository Management",
+				Usage:    "Print the SSH key path for the repository in the current directory, detected from its origin remote, or its first remote",
+				Action:   printKey,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "key-label",
+						Usage: "Print the organization's key with this label instead of its primary key",
+					},
+				},
+			},
+			{
+				Name:     "import",
+				Category: "Configuration",
+				Usage:    "Import organizations from other tools",
+				Commands: []*cli.Command{
+					{
+						Name:   "gh",
+						Usage:  "Import the accounts the GitHub CLI (gh) is logged in to as organizations",
+						Action: importGH,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "hosts",
+								Usage: "Path to gh's hosts file (default: " + ghcli.DefaultHostsPath + ", or hosts.yml in $" + ghcli.ConfigDirEnv + ")",
+							},
+							&cli.StringFlag{
+								Name:  "key",
+								Usage: "Use this SSH key for every account, instead of asking for each one",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:     "doctor",
+				Category: "Maintenance",
+				Usage:    "Check the configuration and its SSH keys for problems",
+				Action:   runDoctor,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "fix",
+						Usage: "Fix what can be fixed: restrict key permissions to 0600, remove organizations whose key is missing, and repair the default",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "Remove organizations whose key is missing without asking",
+					},
+				},
+			},
+			{
+				Name:     "migrate",
+				Category: "Maintenance",
+				Usage:    "Move a configuration file from the legacy location to the path given with --config or --profile",
+				Action:   migrateConfig,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "keep",
+						Usage: "Copy the configuration, leaving the legacy file in place",
+					},
+				},
+			},
+			{
+				Name:     "self-update",
+				Category: "Maintenance",
+				Usage:    "Update ghc to the latest release",
+				Action:   selfUpdate,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "check",
+						Usage: "Only report whether an update is available",
+					},
+				},
 			},
 		},
 	}
-
-	if err := app.Run(context.Background(), os.Args); err != nil {
-		writeError(err)
-		os.Exit(1)
-	}
-}
-
-func writeError(err error) {
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-	}
 }
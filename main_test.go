@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"ghc/internal/clone"
+)
+
+func TestAppCloneFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr error
+	}{
+		// both flags are parsed, so clone rejects them together
+		{name: "key label with key", args: []string{"ghc", "clone", "--key", "/tmp/key", "--key-label", "work", "git@github.com:org1/repo.git"}, expectErr: clone.ErrConflictingFlags},
+		{name: "key label with print-org", args: []string{"ghc", "clone", "--key-label", "work", "--print-org", "git@github.com:org1/repo.git"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp()
+			app.Writer = io.Discard
+			app.ErrWriter = io.Discard
+			err := app.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
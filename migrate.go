@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"ghc/internal/configfile"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+var ErrNoMigrateTarget = errors.New("the legacy path is the default configuration path, pass --config or --profile to choose where to move it")
+
+// migrateConfig moves a configuration file from the legacy location to the
+// resolved configuration path, given with the --config or --profile flag.
+// The legacy location is also the default path, so without one of them there
+// is nowhere to move it.
+//
+// The configuration is validated before it is written, and an existing file at
+// the new path is never overwritten. If the "keep" flag is set, the legacy file
+// is copied and left in place. There is nothing to do if there is no legacy file.
+//
+// Returns an error wrapping ErrNoMigrateTarget if the resolved path is the
+// legacy one, or an error if the legacy configuration is invalid, a
+// configuration already exists at the new path, or the file cannot be moved.
+func migrateConfig(ctx context.Context, c *cli.Command) error {
+	to, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+	from := utils.ExpandPath(configfile.LegacyConfigPath)
+	if filepath.Clean(from) == filepath.Clean(to) {
+		return fmt.Errorf("%w: %s", ErrNoMigrateTarget, from)
+	}
+
+	_, err = configfile.Migrate(from, to, c.Bool("keep"))
+	switch {
+	case errors.Is(err, configfile.ErrConfigNotFound):
+		fmt.Fprintf(c.Root().Writer, "No configuration found at %s, nothing to migrate\n", from)
+		return nil
+	case err != nil:
+		return err
+	case c.Bool("keep"):
+		fmt.Fprintf(c.Root().Writer, "Copied configuration from %s to %s\n", from, to)
+	default:
+		fmt.Fprintf(c.Root().Writer, "Moved configuration from %s to %s\n", from, to)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ghc/internal/configfile"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	config := fmt.Sprintf(`{"organizations":[{"name":"org1","ssh_key_path":%q,"is_default":true}]}`, privateKey)
+
+	tests := []struct {
+		name         string
+		legacy       bool
+		args         []string
+		newPath      bool // whether --config points somewhere other than the legacy path
+		expectOutput string
+		expectErr    error
+	}{
+		{name: "move to --config", legacy: true, newPath: true, expectOutput: "Moved configuration from"},
+		{name: "copy to --config", legacy: true, newPath: true, args: []string{"--keep"}, expectOutput: "Copied configuration from"},
+		{name: "no destination", legacy: true, expectErr: ErrNoMigrateTarget},
+		{name: "nothing to migrate", newPath: true, expectOutput: "No configuration found at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the legacy path is under the home directory
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			legacyPath := utils.ExpandPath(configfile.LegacyConfigPath)
+			if tt.legacy {
+				if err := os.MkdirAll(filepath.Dir(legacyPath), 0700); err != nil {
+					t.Fatal(err)
+				}
+				utils.WriteConfigFileForTest(t, legacyPath, []byte(config))
+			}
+			newPath := legacyPath
+			if tt.newPath {
+				newPath = filepath.Join(t.TempDir(), "ghc", "config.json")
+			}
+
+			var stdout bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "ghc",
+				Writer: &stdout,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "config"},
+				},
+				Commands: []*cli.Command{
+					{
+						Name:   "migrate",
+						Action: migrateConfig,
+						Flags:  []cli.Flag{&cli.BoolFlag{Name: "keep"}},
+					},
+				},
+			}
+			args := append([]string{"ghc", "--config", newPath, "migrate"}, tt.args...)
+			err := cmd.Run(t.Context(), args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if !strings.HasPrefix(stdout.String(), tt.expectOutput) {
+				t.Errorf("expected output starting with %q, got %q", tt.expectOutput, stdout.String())
+			}
+
+			// the configuration must end up at the new path
+			if tt.legacy {
+				conf, err := configfile.LoadConfigFrom(newPath)
+				if err != nil {
+					t.Fatalf("failed to load the migrated config: %v", err)
+				}
+				if len(conf.Organizations) != 1 || conf.Organizations[0].Name != "org1" {
+					t.Errorf("expected org1 to be migrated, got %+v", conf.Organizations)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"ghc/internal/configfile"
+	"ghc/internal/domain"
+	"ghc/internal/utils"
+
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	ErrKeyDestExists = errors.New("destination for the SSH key already exists")
+	ErrNoKeyToMove   = errors.New("organization has no SSH key file to move")
+)
+
+// keyMove is a key file moved by moveOrganizationKey, so it can be moved back.
+type keyMove struct {
+	from, to string
+}
+
+// moveOrganizationKey moves the SSH key file of an existing organization to
+// dest, along with its ".pub" companion if there is one, and points the
+// organization at the new path, while holding the config lock.
+//
+// The move is undone if the key at its new path is rejected or the
+// configuration can't be written, so the key and the configuration always agree.
+//
+// Returns an error wrapping ErrKeyDestExists if something already exists at
+// dest, ErrNoKeyToMove if the organization uses a host alias instead of a key,
+// or domain.ErrSharedSSHKey if another organization uses the same key file.
+func moveOrganizationKey(c *cli.Command, orgName, configPath, dest string) error {
+	var moved []keyMove
+	err := configfile.UpdateConfigAt(configPath, func(conf *domain.Config) error {
+		org, err := conf.GetOrganization(orgName)
+		if err != nil {
+			return err
+		}
+		if org.SSHKeyPath == "" {
+			return fmt.Errorf("%w: %s", ErrNoKeyToMove, orgName)
+		}
+		src := utils.ExpandPath(org.SSHKeyPath)
+		for _, other := range conf.Organizations {
+			if other != org && utils.ExpandPath(other.SSHKeyPath) == src {
+				return fmt.Errorf("%w: also used by %s", domain.ErrSharedSSHKey, other.Name)
+			}
+		}
+
+		// plan every move before touching anything
+		moves := []keyMove{{from: src, to: dest}}
+		if _, err := os.Stat(src + ".pub"); err == nil {
+			moves = append(moves, keyMove{from: src + ".pub", to: dest + ".pub"})
+		}
+		for _, m := range moves {
+			if _, err := os.Lstat(m.to); err == nil {
+				return fmt.Errorf("%w: %s", ErrKeyDestExists, m.to)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return err
+		}
+		for _, m := range moves {
+			if err := moveFile(m.from, m.to); err != nil {
+				return err
+			}
+			moved = append(moved, m)
+		}
+		return conf.ReplaceKey(orgName, dest)
+	})
+	if err != nil {
+		// put back whatever was moved
+		for i := len(moved) - 1; i >= 0; i-- {
+			if undoErr := moveFile(moved[i].to, moved[i].from); undoErr != nil {
+				err = errors.Join(err, fmt.Errorf("moving %s back: %w", moved[i].to, undoErr))
+			}
+		}
+		return err
+	}
+
+	fmt.Fprintf(c.Root().Writer, "Moved the SSH key of organization '%s' to %s\n", orgName, dest)
+	return nil
+}
+
+// moveFile renames from to to, or, across filesystems, copies it with its
+// permissions and removes the original.
+func moveFile(from, to string) error {
+	err := os.Rename(from, to)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+	return os.Remove(from)
+}
@@ -10,79 +10,455 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
+	"ghc/internal/clone"
 	"ghc/internal/configfile"
 	"ghc/internal/domain"
+	"ghc/internal/sshagent"
+	"ghc/internal/sshkey"
 	"ghc/internal/utils"
 
 	"github.com/fatih/color"
-	"github.com/rodaine/table"
 	"github.com/urfave/cli/v3"
+	"golang.org/x/crypto/ssh"
 )
 
 var (
-	ErrNumArguments = fmt.Errorf("incorrect number of arguments")
+	ErrNumArguments        = fmt.Errorf("incorrect number of arguments")
+	ErrBrokenOrganizations = errors.New("organizations have missing or mis-permissioned SSH keys")
+	ErrConflictingFlags    = errors.New("conflicting flags")
+	ErrInvalidExpiry       = errors.New("invalid --expire, expected a positive duration such as 30d, 2w or 12h, or never")
+	ErrInvalidFormat       = errors.New("invalid format")
+	ErrInvalidKeyURL       = errors.New("invalid --key-url, expected an https:// URL")
+	ErrInvalidSelection    = errors.New("invalid selection")
+	ErrKeyDownload         = errors.New("unable to download the SSH key")
+	ErrNotPrivateKey       = errors.New("expected a private SSH key")
+	ErrNotPublicKey        = errors.New("public key path must end in .pub")
+	ErrPrivateKeyNotFound  = errors.New("private key for public key not found")
 )
 
+// checkKeyAccess confirms GitHub accepts an SSH key on a host.
+// This can be overridden in tests.
+var checkKeyAccess = clone.CheckAccess
+
+// connectAgent connects to the running ssh-agent.
+// This can be overridden in tests.
+var connectAgent = sshagent.Connect
+
+// keyURLClient downloads keys for --key-url.
+// This can be overridden in tests.
+var keyURLClient interface {
+	Do(req *http.Request) (*http.Response, error)
+} = &http.Client{Timeout: 30 * time.Second, CheckRedirect: httpsRedirectsOnly}
+
+// httpsRedirectsOnly refuses to follow a --key-url redirect to anything but
+// https, which would download the key in the clear, and otherwise stops after
+// 10 redirects like the default policy of http.Client.
+func httpsRedirectsOnly(req *http.Request, via []*http.Request) error {
+	if req.URL.Scheme != "https" {
+		return fmt.Errorf("%w: redirected to %s", ErrInvalidKeyURL, req.URL.Redacted())
+	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// maxKeySize limits how much of a --key-url response is read; SSH keys are
+// a few kilobytes at most.
+const maxKeySize = 64 << 10
+
 // setOrganization sets the SSH key for the specified organization.
 //
 // This function requires the organization name and the SSH key path as arguments.
 // If the "default" flag is set, the organization is marked as the default.
+// If the "from-pub" flag is set, only the organization name is required and the
+// SSH key path is derived from the given public key path.
+// If the "from-agent" flag is set, only the organization name is required and the
+// user picks one of the keys loaded in ssh-agent.
+// If the "key-url" flag is set, only the organization name is required and the
+// key is downloaded from the URL into a file managed by ghc.
+// If the SSH key path is "-", the private key content is read from stdin into a
+// file managed by ghc, such as to pipe it from a secret manager.
+// If the "move-key" flag is set, only the organization name is required, and the
+// existing organization's key file is moved to the given path.
+// If the "key-type" flag is set, the key is rejected unless it is of that type.
+// If the "default-if-first" flag is set and the organization is the only one,
+// it is made the default.
+// If the "replace-key-only" flag is set, only the key of an existing organization
+// is updated, and everything else about it, including the default, is kept.
 //
 // It performs the following steps:
 // 1. Validates the number of arguments and their values.
 // 2. Expands the SSH key path to its absolute form.
-// 3. Loads the current configuration file.
-// 4. Adds or updates the organization in the configuration.
-// 5. Writes the updated configuration back to the file.
+// 3. Checks the key type, if one is required.
+// 4. Locks and loads the current configuration file.
+// 5. Adds or updates the organization in the configuration.
+// 6. Writes the updated configuration back to the file, unless nothing changed.
 //
 // Returns an error if any of the steps fail.
 func setOrganization(ctx context.Context, c *cli.Command) error {
 	// check if the command has the correct number of arguments
 	// this will ensure neither arg is empty so we don't need to check for that
-	const nargs = 2
+	keySources := 0
+	for _, flag := range []string{"from-pub", "from-agent", "key-url"} {
+		if c.IsSet(flag) {
+			keySources++
+		}
+	}
+	if keySources > 1 {
+		return fmt.Errorf("%w: only one of --from-pub, --from-agent and --key-url may be set", ErrConflictingFlags)
+	}
+	if c.Bool("replace") && c.Bool("replace-key-only") {
+		return fmt.Errorf("%w: only one of --replace and --replace-key-only may be set", ErrConflictingFlags)
+	}
+	if c.Bool("replace-key-only") && c.Bool("default") {
+		return fmt.Errorf("%w: --replace-key-only leaves the default unchanged, so --default cannot be set", ErrConflictingFlags)
+	}
+	if c.Bool("plan") && c.Bool("from-agent") {
+		return fmt.Errorf("%w: --from-agent saves the selected key, so --plan cannot be set", ErrConflictingFlags)
+	}
+	if c.Bool("dry-run") && c.Bool("from-agent") {
+		return fmt.Errorf("%w: --from-agent saves the selected key, so --dry-run cannot be set", ErrConflictingFlags)
+	}
+	if c.String("move-key") != "" {
+		for _, flag := range []string{"from-pub", "from-agent", "key-url", "host-alias", "replace-key-only", "plan", "dry-run", "insecure-skip-key-check", "replace"} {
+			if c.IsSet(flag) {
+				return fmt.Errorf("%w: --move-key only moves the existing key, so --%s cannot be set", ErrConflictingFlags, flag)
+			}
+		}
+	}
+	if c.IsSet("key-url") {
+		for _, flag := range []string{"plan", "dry-run", "insecure-skip-key-check"} {
+			if c.Bool(flag) {
+				return fmt.Errorf("%w: --key-url saves the downloaded key, so --%s cannot be set", ErrConflictingFlags, flag)
+			}
+		}
+	}
+	if c.Bool("dry-run") && c.Bool("plan") {
+		return fmt.Errorf("%w: only one of --dry-run and --plan may be set", ErrConflictingFlags)
+	}
+	if c.Bool("replace-key-only") && c.String("host") != "" {
+		return fmt.Errorf("%w: --replace-key-only leaves the host unchanged, so --host cannot be set", ErrConflictingFlags)
+	}
+	if c.Bool("insecure-skip-key-check") {
+		for _, flag := range []string{"from-agent", "replace-key-only", "key-type", "validate-key-remote"} {
+			if c.IsSet(flag) {
+				return fmt.Errorf("%w: --insecure-skip-key-check doesn't read the key, so --%s cannot be set", ErrConflictingFlags, flag)
+			}
+		}
+	}
+	if c.Bool("replace-key-only") && c.String("expire") != "" {
+		return fmt.Errorf("%w: --replace-key-only leaves the expiry unchanged, so --expire cannot be set", ErrConflictingFlags)
+	}
+	// --expire never clears the expiry, leaving expiresAt nil
+	var expiresAt *time.Time
+	setExpiry := c.String("expire") != ""
+	if expire := c.String("expire"); setExpiry && expire != neverExpire {
+		d, err := parseExpiry(expire)
+		if err != nil {
+			return err
+		}
+		t := time.Now().Add(d).UTC().Truncate(time.Second)
+		expiresAt = &t
+	}
+	hostAlias := c.String("host-alias")
+	if hostAlias != "" {
+		for _, flag := range []string{"from-pub", "from-agent", "key-url", "replace-key-only", "key-type", "host", "validate-key-remote", "insecure-skip-key-check"} {
+			if c.IsSet(flag) {
+				return fmt.Errorf("%w: --host-alias uses the key and host from your SSH config, so --%s cannot be set", ErrConflictingFlags, flag)
+			}
+		}
+	}
+	nargs := 2
+	if c.IsSet("from-pub") || c.Bool("from-agent") || c.IsSet("key-url") || c.String("move-key") != "" || hostAlias != "" {
+		// the key path comes from a flag, or isn't needed, so only the org name is expected
+		nargs = 1
+	}
 	if c.NArg() != nargs {
 		return fmt.Errorf("%w: expected %d, got %d", ErrNumArguments, nargs, c.NArg())
 	}
+	fromStdin := nargs == 2 && c.Args().Get(1) == "-"
+	if fromStdin {
+		for _, flag := range []string{"plan", "dry-run", "insecure-skip-key-check"} {
+			if c.Bool(flag) {
+				return fmt.Errorf("%w: a key read from stdin is saved, so --%s cannot be set", ErrConflictingFlags, flag)
+			}
+		}
+	}
 
 	orgName := c.Args().Get(0)
-	sshKeyPath := c.Args().Get(1)
-
-	// expand the path to the SSH key
-	sshKeyPath = utils.ExpandPath(sshKeyPath)
+	if c.Bool("canonicalize") {
+		orgName = domain.CanonicalOrganizationName(orgName)
+	}
 
-	// read the current config
-	conf, err := configfile.LoadConfig()
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
 	if err != nil {
-		if errors.Is(err, configfile.ErrConfigNotFound) {
-			// create a new config file
-			conf = &domain.Config{
-				Organizations: []*domain.Organization{},
+		return err
+	}
+
+	// move the existing key, keeping everything else
+	if moveKey := c.String("move-key"); moveKey != "" {
+		return moveOrganizationKey(c, orgName, configPath, utils.ExpandPath(moveKey))
+	}
+
+	// an org using a host alias has no key of its own
+	if hostAlias != "" {
+		return applyOrganization(c, orgName, configPath, func(conf *domain.Config) error {
+			if c.Bool("replace") {
+				conf.ResetOrganization(orgName)
+			}
+			if err := conf.SetHostAlias(orgName, hostAlias, c.Bool("default")); err != nil {
+				return err
+			}
+			if setExpiry {
+				if err := conf.SetExpiry(orgName, expiresAt); err != nil {
+					return err
+				}
+			}
+			if c.Bool("default-if-first") {
+				conf.PromoteSoleOrganization()
+			}
+			return nil
+		})
+	}
+
+	var sshKeyPath string
+	// a downloaded, piped or agent key is staged, and only moved to its managed path once the
+	// change is confirmed and the config is saved
+	var staged *configfile.StagedKey
+	switch {
+	case c.IsSet("from-pub"):
+		privateKeyPath, err := privateKeyFromPub(utils.ExpandPath(c.String("from-pub")))
+		if err != nil {
+			return err
+		}
+		sshKeyPath = privateKeyPath
+	case c.Bool("from-agent"):
+		staged, err = keyFromAgent(configPath, c.Root().Reader, c.Root().Writer)
+		if err != nil {
+			return err
+		}
+		defer staged.Discard()
+		sshKeyPath = staged.Path
+	case c.IsSet("key-url"):
+		staged, err = keyFromURL(ctx, configPath, orgName, c.String("key-url"))
+		if err != nil {
+			return err
+		}
+		defer staged.Discard()
+		sshKeyPath = staged.Path
+	case fromStdin:
+		staged, err = keyFromStdin(configPath, orgName, c.Root().Reader)
+		if err != nil {
+			return err
+		}
+		defer staged.Discard()
+		sshKeyPath = staged.Path
+	default:
+		// expand the path to the SSH key
+		sshKeyPath = utils.ExpandPath(c.Args().Get(1))
+	}
+
+	// a staged key is checked in its temporary file
+	checkPath := sshKeyPath
+	if staged != nil {
+		checkPath = staged.TempPath
+	}
+
+	// enforce the required key type, if there is one
+	if keyType := c.String("key-type"); keyType != "" {
+		if err := sshkey.Check(checkPath, keyType); err != nil {
+			return err
+		}
+	}
+
+	// warn about a .pub that doesn't belong to the key, such as after a botched copy
+	if !c.Bool("insecure-skip-key-check") {
+		if err := sshkey.CheckPair(checkPath); errors.Is(err, sshkey.ErrPairMismatch) {
+			utils.NewColor(color.FgYellow, color.Bold).Fprintf(c.Root().ErrWriter, "WARNING: %v\n", err)
+		}
+	}
+
+	// ask before clobbering an existing organization's key; plans and dry runs change nothing
+	if !c.Bool("plan") && !c.Bool("dry-run") {
+		if err := confirmKeyChange(c, configPath, orgName, sshKeyPath, staged != nil && staged.Replaces()); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("insecure-skip-key-check") {
+		utils.NewColor(color.FgYellow, color.Bold).Fprintf(c.Root().ErrWriter, "WARNING: the SSH key %s was not checked; cloning for '%s' fails until it exists with 0600 permissions\n", sshKeyPath, orgName)
+	}
+
+	set := func(conf *domain.Config) error {
+		// start from a blank definition, so nothing stale is left behind
+		if c.Bool("replace") {
+			conf.ResetOrganization(orgName)
+		}
+		if err := setKey(c, conf, orgName, checkPath); err != nil {
+			return err
+		}
+		// a staged key is stored at the managed path it is moved to
+		if staged != nil {
+			org, err := conf.GetOrganization(orgName)
+			if err != nil {
+				return err
+			}
+			org.SSHKeyPath = staged.Path
+		}
+		if setExpiry {
+			if err := conf.SetExpiry(orgName, expiresAt); err != nil {
+				return err
 			}
-		} else {
+		}
+		return nil
+	}
+
+	// confirm GitHub accepts the key, so a wrong key is never saved
+	if c.Bool("validate-key-remote") {
+		if err := checkKeyRemote(c, configPath, orgName, checkPath, set); err != nil {
 			return err
 		}
 	}
 
-	err = conf.SetOrganization(orgName, sshKeyPath, c.Bool("default"))
+	err = applyOrganization(c, orgName, configPath, set)
+	if err != nil || staged == nil {
+		return err
+	}
+	return staged.Commit()
+}
+
+// checkKeyRemote confirms GitHub accepts the key at sshKeyPath on the host
+// orgName has once set is applied. set is applied to a copy of the config at
+// configPath first, so only a key that passes the local checks is tried, and
+// the config lock isn't held for the round-trip to GitHub.
+func checkKeyRemote(c *cli.Command, configPath, orgName, sshKeyPath string, set func(*domain.Config) error) error {
+	conf, err := configfile.LoadConfigFrom(configPath)
+	if errors.Is(err, configfile.ErrConfigNotFound) {
+		conf = &domain.Config{Organizations: []*domain.Organization{}}
+	} else if err != nil {
+		return err
+	}
+	if err := set(conf); err != nil {
+		return err
+	}
+	org, err := conf.GetOrganization(orgName)
 	if err != nil {
 		return err
 	}
+	return checkKeyAccess(org.Host, sshKeyPath, c.Root().ErrWriter)
+}
+
+// neverExpire is the --expire value that clears an organization's expiry.
+const neverExpire = "never"
+
+// parseExpiry parses the --expire duration with utils.ParseDuration, which accepts
+// whole days and weeks, such as 30d or 2w, besides the units of time.ParseDuration.
+// It returns an error wrapping ErrInvalidExpiry unless the duration is positive.
+func parseExpiry(s string) (time.Duration, error) {
+	d, ok := utils.ParseDuration(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidExpiry, s)
+	}
+	return d, nil
+}
 
-	// write the configuration back to the file
-	err = configfile.WriteConfig(conf)
+// setKey applies the key and settings given to "org set" to the organization:
+// only its key with the "replace-key-only" flag, otherwise its key, default
+// status and host. The key isn't checked with the "insecure-skip-key-check" flag.
+func setKey(c *cli.Command, conf *domain.Config, orgName, sshKeyPath string) error {
+	if c.Bool("replace-key-only") {
+		return conf.ReplaceKey(orgName, sshKeyPath)
+	}
+	set := conf.SetOrganization
+	if c.Bool("insecure-skip-key-check") {
+		set = conf.SetOrganizationUnchecked
+	}
+	if err := set(orgName, sshKeyPath, c.Bool("default")); err != nil {
+		return err
+	}
+	if host := c.String("host"); host != "" {
+		if err := conf.SetHost(orgName, host); err != nil {
+			return err
+		}
+	}
+	if c.Bool("default-if-first") {
+		conf.PromoteSoleOrganization()
+	}
+	return nil
+}
 
-	// no need to check if the error is nil, because we are going to return it anyway
+// applyOrganization applies fn to the config at configPath while holding the
+// config lock, and tells the user if it left the organization unchanged.
+// The result must still have a default, if the config enforces one.
+// With the "plan" flag, it prints what would change instead, and with the
+// "dry-run" flag the resulting configuration, writing nothing.
+func applyOrganization(c *cli.Command, orgName, configPath string, fn func(*domain.Config) error) error {
+	fn = enforceDefault(fn)
+	if c.Bool("dry-run") {
+		return dryRunConfig(c, configPath, fn)
+	}
+	if c.Bool("plan") {
+		changes, err := configfile.PlanConfigAt(configPath, fn)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			fmt.Fprintf(c.Root().Writer, "No changes to organization '%s'\n", orgName)
+		}
+		for _, change := range changes {
+			fmt.Fprintln(c.Root().Writer, change)
+		}
+		return nil
+	}
+
+	changed, err := configfile.ApplyConfigAt(configPath, fn)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		fmt.Fprintf(c.Root().Writer, "No changes to organization '%s'\n", orgName)
+	}
+	return nil
+}
+
+// enforceDefault wraps fn, so the change is rejected, and nothing is written,
+// if it leaves a config that enforces a default without one.
+func enforceDefault(fn func(*domain.Config) error) func(*domain.Config) error {
+	return func(conf *domain.Config) error {
+		if err := fn(conf); err != nil {
+			return err
+		}
+		return conf.CheckEnforceDefault()
+	}
+}
+
+// dryRunConfig applies fn to the config at configPath in memory and prints the
+// configuration exactly as it would be written, leaving the file untouched.
+func dryRunConfig(c *cli.Command, configPath string, fn func(*domain.Config) error) error {
+	data, err := configfile.DryRunConfigAt(configPath, fn)
+	if err != nil {
+		return err
+	}
+	_, err = c.Root().Writer.Write(data)
 	return err
 }
 
 // removeOrganization removes an organization from the configuration.
 //
 // This function requires the organization name as an argument.
+// With the "dry-run" flag, it prints the resulting configuration instead of writing it.
 //
 // It performs the following steps:
 // 1. Validates the number of arguments and their values.
-// 2. Loads the current configuration file.
+// 2. Locks and loads the current configuration file.
 // 3. Removes the organization from the configuration.
 // 4. Writes the updated configuration back to the file.
 //
@@ -95,34 +471,133 @@ func removeOrganization(ctx context.Context, c *cli.Command) error {
 
 	orgName := c.Args().Get(0)
 
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+
+	remove := enforceDefault(func(conf *domain.Config) error {
+		return conf.RemoveOrganization(orgName)
+	})
+	if c.Bool("dry-run") {
+		return dryRunConfig(c, configPath, remove)
+	}
+
+	// update the config while holding the config lock
+	return configfile.UpdateConfigAt(configPath, remove)
+}
+
+// moveOrganization moves an organization within the configuration.
+//
+// This function requires the organization name and a direction (up, down,
+// top or bottom) as arguments. Once an organization has been moved, the
+// configuration keeps its order rather than sorting organizations by name.
+//
+// Returns an error if the arguments are invalid, the organization does not
+// exist, or the configuration cannot be updated.
+func moveOrganization(ctx context.Context, c *cli.Command) error {
+	const nargs = 2
+	if c.NArg() != nargs {
+		return fmt.Errorf("%w: expected %d, got %d", ErrNumArguments, nargs, c.NArg())
+	}
+
+	orgName := c.Args().Get(0)
+	direction := domain.MoveDirection(c.Args().Get(1))
+
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+
+	// update the config while holding the config lock
+	return configfile.UpdateConfigAt(configPath, func(conf *domain.Config) error {
+		return conf.MoveOrganization(orgName, direction)
+	})
+}
+
+// renameOrganization renames an organization in the configuration.
+//
+// This function requires the current and the new organization name as
+// arguments. The organization keeps its SSH key, default status and any
+// other settings.
+//
+// Returns an error if the arguments are invalid, the organization does not
+// exist, the new name is invalid or taken, or the configuration cannot be updated.
+func renameOrganization(ctx context.Context, c *cli.Command) error {
+	const nargs = 2
+	if c.NArg() != nargs {
+		return fmt.Errorf("%w: expected %d, got %d", ErrNumArguments, nargs, c.NArg())
+	}
+
+	oldName := c.Args().Get(0)
+	newName := c.Args().Get(1)
+
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+
+	// update the config while holding the config lock
+	return configfile.UpdateConfigAt(configPath, func(conf *domain.Config) error {
+		return conf.RenameOrganization(oldName, newName)
+	})
+}
+
+// exportOrganization exports a single organization as JSON.
+//
+// This function requires the organization name as an argument and writes
+// the organization's definition to the standard output, suitable for
+// sharing the key mapping with another machine.
+//
+// Returns an error if the configuration cannot be loaded or the
+// organization does not exist.
+func exportOrganization(ctx context.Context, c *cli.Command) error {
+	const nargs = 1
+	if c.NArg() != nargs {
+		return fmt.Errorf("%w: expected %d, got %d", ErrNumArguments, nargs, c.NArg())
+	}
+
+	orgName := c.Args().Get(0)
+
 	// read the current config
-	conf, err := configfile.LoadConfig()
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+	conf, err := configfile.LoadConfigChecked(configPath, c.Bool("strict-config-permissions"), c.Root().ErrWriter)
 	if err != nil {
 		return err
 	}
 
-	// remove the organization from the config
-	err = conf.RemoveOrganization(orgName)
+	org, err := conf.GetOrganization(orgName)
 	if err != nil {
 		return err
 	}
 
-	// write the configuration back to the file
-	err = configfile.WriteConfig(conf)
+	data, err := org.JSON()
+	if err != nil {
+		return err
+	}
 
-	// no need to check if the error is nil, because we are going to return it anyway
+	_, err = fmt.Fprintln(c.Root().Writer, string(data))
 	return err
 }
 
 // listOrganizations lists all organizations in the configuration.
 //
 // This function retrieves the current configuration and prints
-// the list of organizations to the standard output.
+// the list of organizations to the standard output, in the format
+// given by the "format" flag: table (the default), json, yaml or tsv.
 //
-// Returns an error if the configuration cannot be loaded.
+// Returns an error if the configuration cannot be loaded or the
+// format is unknown.
 func listOrganizations(ctx context.Context, c *cli.Command) error {
 	// read the current config
-	conf, err := configfile.LoadConfig()
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+	conf, err := configfile.LoadConfigChecked(configPath, c.Bool("strict-config-permissions"), c.Root().ErrWriter)
 	if err != nil {
 		return err
 	}
@@ -131,22 +606,175 @@ func listOrganizations(ctx context.Context, c *cli.Command) error {
 		return domain.ErrNoOrganizations
 	}
 
-	// create formatters
-	header := color.New(color.FgGreen, color.Underline).SprintfFunc()
-
-	tbl := table.New("Org Name", "SSH Key Path", "Default")
-	tbl.WithHeaderFormatter(header).WithPadding(2)
+	format, ok := listFormats[c.String("format")]
+	if !ok {
+		return fmt.Errorf("%w: %q, expected one of %s", ErrInvalidFormat, c.String("format"), strings.Join(listFormatNames(), ", "))
+	}
+	opts := listOptions{fingerprint: c.Bool("fingerprint")}
+	if !c.Bool("missing") {
+		return format(c.Root().Writer, conf, opts)
+	}
 
-	// add rows to the table
-	for _, org := range conf.Organizations {
-		defChar := " "
-		if org.IsDefault {
-			defChar = "*"
+	// show only the organizations whose keys are missing or mis-permissioned,
+	// and fail if there are any, so this can gate CI
+	broken := *conf
+	broken.Organizations = conf.Filter(func(org *domain.Organization) bool {
+		if err := org.CheckKey(); err != nil {
+			fmt.Fprintf(c.Root().ErrWriter, "%s: %v\n", org.Name, err)
+			return true
 		}
-		tbl.AddRow(org.Name, org.SSHKeyPath, defChar)
+		return false
+	})
+	if len(broken.Organizations) == 0 {
+		fmt.Fprintln(c.Root().ErrWriter, "All organizations have usable SSH keys")
+		return nil
+	}
+	if err := format(c.Root().Writer, &broken, opts); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %d of %d", ErrBrokenOrganizations, len(broken.Organizations), len(conf.Organizations))
+}
+
+// countOrganizations prints the number of configured organizations, for scripts.
+// A missing or empty configuration prints 0, and is not an error.
+func countOrganizations(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 0 {
+		return fmt.Errorf("%w: expected 0, got %d", ErrNumArguments, c.NArg())
 	}
-	fmt.Println("")
-	tbl.Print()
-	fmt.Println("")
+	configPath, err := configfile.ResolveProfilePath(c.String("config"), c.String("profile"))
+	if err != nil {
+		return err
+	}
+	conf, err := configfile.LoadConfigChecked(configPath, c.Bool("strict-config-permissions"), c.Root().ErrWriter)
+	if err != nil && !errors.Is(err, configfile.ErrConfigNotFound) {
+		return err
+	}
+	fmt.Fprintln(c.Root().Writer, conf.Len())
 	return nil
 }
+
+// privateKeyFromPub derives the private key path from a public key path.
+//
+// The ".pub" suffix is stripped from the public key path, and the resulting
+// private key must exist. Permissions are checked later by Organization.Validate.
+//
+// Returns ErrNotPublicKey if the path does not end in ".pub", or
+// ErrPrivateKeyNotFound if the private counterpart does not exist.
+func privateKeyFromPub(pubKeyPath string) (string, error) {
+	if !strings.HasSuffix(pubKeyPath, ".pub") {
+		return "", fmt.Errorf("%w: %s", ErrNotPublicKey, pubKeyPath)
+	}
+
+	privateKeyPath := strings.TrimSuffix(pubKeyPath, ".pub")
+	if _, err := os.Stat(privateKeyPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %s", ErrPrivateKeyNotFound, privateKeyPath)
+	}
+
+	return privateKeyPath, nil
+}
+
+// keyFromURL downloads the SSH key for orgName from keyURL, which must use
+// https, and stages it for a ghc-managed file named after the organization,
+// with a ".pub" extension for a public key held by an agent.
+// The key is only staged once it parses as a private or public SSH key.
+//
+// Returns the staged key, or an error wrapping ErrInvalidKeyURL,
+// ErrKeyDownload or sshkey.ErrUnreadableKey.
+func keyFromURL(ctx context.Context, configPath, orgName, keyURL string) (*configfile.StagedKey, error) {
+	u, err := url.Parse(keyURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidKeyURL, keyURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := keyURLClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeyDownload, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s: unexpected status %s", ErrKeyDownload, u.Redacted(), resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxKeySize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeyDownload, err)
+	}
+
+	// never save anything that isn't a key, such as a login page
+	public, err := sshkey.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u.Redacted(), err)
+	}
+	name := orgName
+	if public {
+		name += ".pub"
+	}
+	return configfile.StageManagedKeyAt(configPath, name, data)
+}
+
+// keyFromStdin reads the private SSH key for orgName from r and stages it
+// for a ghc-managed file named after the organization.
+// The key is only staged once it parses as a private SSH key.
+//
+// Returns the staged key, or an error wrapping ErrNotPrivateKey or
+// sshkey.ErrUnreadableKey.
+func keyFromStdin(configPath, orgName string, r io.Reader) (*configfile.StagedKey, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxKeySize))
+	if err != nil {
+		return nil, err
+	}
+	public, err := sshkey.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("stdin: %w", err)
+	}
+	if public {
+		return nil, fmt.Errorf("%w: stdin holds a public key", ErrNotPrivateKey)
+	}
+	return configfile.StageManagedKeyAt(configPath, orgName, data)
+}
+
+// keyFromAgent lets the user pick one of the keys loaded in ssh-agent.
+//
+// The keys are listed to w by fingerprint, and the selection is read from r.
+// If the agent holds a single key it is selected without prompting.
+// The selected public key is staged for a ghc-managed file named after its
+// fingerprint, so the generated SSH config can reference the agent key.
+//
+// Returns the staged public key, or an error if the agent cannot be reached,
+// holds no keys, or the selection is invalid.
+func keyFromAgent(configPath string, r io.Reader, w io.Writer) (*configfile.StagedKey, error) {
+	a, closeAgent, err := connectAgent()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	keys, err := sshagent.ListKeys(a)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := keys[0]
+	if len(keys) > 1 {
+		fmt.Fprintln(w, "Keys loaded in ssh-agent:")
+		for i, key := range keys {
+			fmt.Fprintf(w, "  %d) %s %s\n", i+1, sshagent.Fingerprint(key), key.Comment)
+		}
+		fmt.Fprintf(w, "Select a key [1-%d]: ", len(keys))
+
+		var choice int
+		if _, err := fmt.Fscanln(r, &choice); err != nil || choice < 1 || choice > len(keys) {
+			return nil, ErrInvalidSelection
+		}
+		selected = keys[choice-1]
+	}
+
+	// name the managed file after the fingerprint, using filename-safe characters
+	fingerprint := strings.TrimPrefix(sshagent.Fingerprint(selected), "SHA256:")
+	name := strings.NewReplacer("/", "_", "+", "-").Replace(fingerprint) + ".pub"
+
+	return configfile.StageManagedKeyAt(configPath, name, ssh.MarshalAuthorizedKey(selected))
+}
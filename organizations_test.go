@@ -1,23 +1,44 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"ghc/internal/configfile"
 	"ghc/internal/domain"
+	"ghc/internal/sshagent"
+	"ghc/internal/sshkey"
 	"ghc/internal/utils"
 
 	"github.com/urfave/cli/v3"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"gopkg.in/yaml.v3"
 )
 
 func TestOrganization(t *testing.T) {
 	// test setup
-	privateKey, _ := utils.GenerateTestSSHKey(t)
+	privateKey, publicKey := utils.GenerateTestSSHKey(t)
 	tempDir := t.TempDir()
 
+	// create a public key without a private counterpart
+	orphanPubPath := filepath.Join(tempDir, "orphan.pub")
+	utils.WriteConfigFileForTest(t, orphanPubPath, []byte("ssh-ed25519 AAAA"))
+
 	// create an empty org
 	setConfigPath := filepath.Join(tempDir, "config.json")
 
@@ -71,15 +92,36 @@ func TestOrganization(t *testing.T) {
 			{
 				Name:   "set",
 				Action: setOrganization,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.StringFlag{Name: "from-pub"},
+					&cli.BoolFlag{Name: "from-agent"},
+					&cli.StringFlag{Name: "key-type"},
+				},
 			},
 			{
 				Name:   "list",
 				Action: listOrganizations,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "format", Value: "table"},
+				},
 			},
 			{
 				Name:   "remove",
 				Action: removeOrganization,
 			},
+			{
+				Name:   "move",
+				Action: moveOrganization,
+			},
+			{
+				Name:   "rename",
+				Action: renameOrganization,
+			},
+			{
+				Name:   "export",
+				Action: exportOrganization,
+			},
 		},
 	}
 
@@ -121,6 +163,54 @@ func TestOrganization(t *testing.T) {
 			args:       []string{"org", "set", "!-invalid!", privateKey},
 			expected:   domain.ErrInvalidOrgName,
 		},
+		{
+			name:       "set matching key type",
+			configPath: setConfigPath,
+			args:       []string{"org", "set", "--key-type", "rsa", "org1", privateKey},
+			expected:   nil,
+		},
+		{
+			name:       "set mismatched key type",
+			configPath: setConfigPath,
+			args:       []string{"org", "set", "--key-type", "ed25519", "org1", privateKey},
+			expected:   sshkey.ErrKeyTypeMismatch,
+		},
+		{
+			name:       "set rsa key too small",
+			configPath: setConfigPath,
+			args:       []string{"org", "set", "--key-type", "rsa:4096", "org1", privateKey},
+			expected:   sshkey.ErrKeyTypeMismatch,
+		},
+		{
+			name:       "set from public key",
+			configPath: setConfigPath,
+			args:       []string{"org", "set", "--from-pub", publicKey, "org1"},
+			expected:   nil,
+		},
+		{
+			name:       "set from public key bad nargs",
+			configPath: setConfigPath,
+			args:       []string{"org", "set", "--from-pub", publicKey, "org1", privateKey},
+			expected:   ErrNumArguments,
+		},
+		{
+			name:       "set from public key missing private key",
+			configPath: setConfigPath,
+			args:       []string{"org", "set", "--from-pub", orphanPubPath, "org1"},
+			expected:   ErrPrivateKeyNotFound,
+		},
+		{
+			name:       "set from public key not a public key",
+			configPath: setConfigPath,
+			args:       []string{"org", "set", "--from-pub", privateKey, "org1"},
+			expected:   ErrNotPublicKey,
+		},
+		{
+			name:       "set from public key and agent",
+			configPath: setConfigPath,
+			args:       []string{"org", "set", "--from-pub", publicKey, "--from-agent", "org1"},
+			expected:   ErrConflictingFlags,
+		},
 		{
 			name:         "set bad config file",
 			configPath:   badConfigPath,
@@ -163,6 +253,60 @@ func TestOrganization(t *testing.T) {
 			args:       []string{"org", "list"},
 			expected:   nil,
 		},
+		{
+			name:       "move valid",
+			configPath: lsConfigPath,
+			args:       []string{"org", "move", "org2", "top"},
+			expected:   nil,
+		},
+		{
+			name:       "move invalid direction",
+			configPath: lsConfigPath,
+			args:       []string{"org", "move", "org2", "sideways"},
+			expected:   domain.ErrInvalidMoveDirection,
+		},
+		{
+			name:       "move bad nargs",
+			configPath: lsConfigPath,
+			args:       []string{"org", "move", "org2"},
+			expected:   ErrNumArguments,
+		},
+		{
+			name:       "rename bad nargs",
+			configPath: rmConfigPath,
+			args:       []string{"org", "rename", "org2"},
+			expected:   ErrNumArguments,
+		},
+		{
+			name:       "rename not found",
+			configPath: rmConfigPath,
+			args:       []string{"org", "rename", "org3", "org4"},
+			expected:   domain.ErrOrganizationNotFound,
+		},
+		{
+			name:       "rename default org",
+			configPath: rmConfigPath,
+			args:       []string{"org", "rename", "org2", "renamed-org"},
+			expected:   nil,
+		},
+		{
+			name:       "export valid",
+			configPath: lsConfigPath,
+			args:       []string{"org", "export", "org1"},
+			expected:   nil,
+		},
+		{
+			name:       "export not found",
+			configPath: lsConfigPath,
+			args:       []string{"org", "export", "org3"},
+			expected:   domain.ErrOrganizationNotFound,
+		},
+		{
+			name:       "export bad nargs",
+			configPath: lsConfigPath,
+			args:       []string{"org", "export"},
+			expected:   ErrNumArguments,
+		},
 		{
 			name:       "list no organizations",
 			configPath: emptyOrgConfigPath,
@@ -191,3 +335,2057 @@ func TestOrganization(t *testing.T) {
 		}
 	}
 }
+
+func TestPrivateKeyFromPub(t *testing.T) {
+	privateKey, publicKey := utils.GenerateTestSSHKey(t)
+
+	got, err := privateKeyFromPub(publicKey)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if got != privateKey {
+		t.Errorf("expected %s, got %s", privateKey, got)
+	}
+}
+
+func TestExportOrganization(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	conf := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true},
+		},
+	}
+	confBytes, err := conf.JSON()
+	if err != nil {
+		t.Fatalf("failed to marshal test config: %v", err)
+	}
+	utils.WriteConfigFileForTest(t, configPath, confBytes)
+	configfile.SetDefaultConfigPath(configPath)
+
+	var out bytes.Buffer
+	cmd := &cli.Command{
+		Name:   "export",
+		Action: exportOrganization,
+		Writer: &out,
+	}
+	if err := cmd.Run(t.Context(), []string{"export", "org1"}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	// the output must be a single organization, not a whole config
+	var org domain.Organization
+	if err := json.Unmarshal(out.Bytes(), &org); err != nil {
+		t.Fatalf("failed to unmarshal export output: %v", err)
+	}
+	if org.Name != "org1" || org.SSHKeyPath != "/path/to/key1" || !org.IsDefault {
+		t.Errorf("unexpected export output: %s", out.String())
+	}
+}
+
+func TestListOrganizationsFormat(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	conf := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: false},
+			{Name: "org2", SSHKeyPath: "/path/to/key2", IsDefault: true},
+		},
+	}
+	confBytes, err := conf.JSON()
+	if err != nil {
+		t.Fatalf("failed to marshal test config: %v", err)
+	}
+	utils.WriteConfigFileForTest(t, configPath, confBytes)
+	configfile.SetDefaultConfigPath(configPath)
+
+	// checkOrgs checks that the output holds both organizations
+	checkOrgs := func(t *testing.T, orgs []domain.Organization) {
+		t.Helper()
+		if len(orgs) != 2 || orgs[0].Name != "org1" || orgs[1].SSHKeyPath != "/path/to/key2" || !orgs[1].IsDefault {
+			t.Errorf("unexpected organizations: %+v", orgs)
+		}
+	}
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			format: "table",
+			check: func(t *testing.T, out string) {
+				if !strings.Contains(out, "Org Name") || !regexp.MustCompile(`org2\s+/path/to/key2\s+\*`).MatchString(out) {
+					t.Errorf("unexpected table output:\n%s", out)
+				}
+			},
+		},
+		{
+			format: "json",
+			check: func(t *testing.T, out string) {
+				var orgs []domain.Organization
+				if err := json.Unmarshal([]byte(out), &orgs); err != nil {
+					t.Fatalf("failed to parse json output: %v", err)
+				}
+				checkOrgs(t, orgs)
+			},
+		},
+		{
+			format: "yaml",
+			check: func(t *testing.T, out string) {
+				var orgs []domain.Organization
+				if err := yaml.Unmarshal([]byte(out), &orgs); err != nil {
+					t.Fatalf("failed to parse yaml output: %v", err)
+				}
+				checkOrgs(t, orgs)
+			},
+		},
+		{
+			format: "tsv",
+			check: func(t *testing.T, out string) {
+				expected := "name\tssh_key_path\tis_default\n" +
+					"org1\t/path/to/key1\tfalse\n" +
+					"org2\t/path/to/key2\ttrue\n"
+				if out != expected {
+					t.Errorf("expected %q, got %q", expected, out)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "list",
+				Action: listOrganizations,
+				Writer: &out,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "format", Value: "table"},
+				},
+			}
+			if err := cmd.Run(t.Context(), []string{"list", "--format", tt.format}); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			tt.check(t, out.String())
+		})
+	}
+
+	// unknown formats are rejected
+	cmd := &cli.Command{
+		Name:   "list",
+		Action: listOrganizations,
+		Writer: io.Discard,
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "format", Value: "table"},
+		},
+	}
+	if err := cmd.Run(t.Context(), []string{"list", "--format", "xml"}); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("expected %v, got %v", ErrInvalidFormat, err)
+	}
+}
+
+func TestListOrganizationsMissing(t *testing.T) {
+	healthyKey, _ := utils.GenerateTestSSHKey(t)
+	openKey, _ := utils.GenerateTestSSHKey(t)
+	if err := os.Chmod(openKey, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		orgs      []*domain.Organization
+		expectErr error
+		expected  string // tsv output
+	}{
+		{
+			name: "mix of healthy and broken",
+			orgs: []*domain.Organization{
+				{Name: "healthy", SSHKeyPath: healthyKey, IsDefault: true},
+				{Name: "missing", SSHKeyPath: "/does/not/exist"},
+				{Name: "open", SSHKeyPath: openKey},
+				{Name: "aliased", HostAlias: "github-work"},
+			},
+			expectErr: ErrBrokenOrganizations,
+			expected: "name\tssh_key_path\tis_default\n" +
+				"missing\t/does/not/exist\tfalse\n" +
+				"open\t" + openKey + "\tfalse\n",
+		},
+		{
+			name: "all healthy",
+			orgs: []*domain.Organization{
+				{Name: "healthy", SSHKeyPath: healthyKey, IsDefault: true},
+			},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			confBytes, err := (&domain.Config{Organizations: tt.orgs}).JSON()
+			if err != nil {
+				t.Fatalf("failed to marshal test config: %v", err)
+			}
+			utils.WriteConfigFileForTest(t, configPath, confBytes)
+			configfile.SetDefaultConfigPath(configPath)
+
+			var out, errOut bytes.Buffer
+			cmd := &cli.Command{
+				Name:      "list",
+				Action:    listOrganizations,
+				Writer:    &out,
+				ErrWriter: &errOut,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "format", Value: "table"},
+					&cli.BoolFlag{Name: "missing"},
+				},
+			}
+			err = cmd.Run(t.Context(), []string{"list", "--missing", "--format", "tsv"})
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out.String())
+			}
+			// each broken org is explained
+			for _, name := range []string{"missing:", "open:"} {
+				if strings.Contains(errOut.String(), name) != (tt.expectErr != nil) {
+					t.Errorf("expected reason for %s to be reported %v, got %q", name, tt.expectErr != nil, errOut.String())
+				}
+			}
+		})
+	}
+}
+
+func TestListOrganizationsFingerprint(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	expected, err := sshkey.Fingerprint(privateKey)
+	if err != nil {
+		t.Fatalf("failed to fingerprint test key: %v", err)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	conf := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "valid", SSHKeyPath: privateKey, IsDefault: true},
+			{Name: "missing", SSHKeyPath: "/does/not/exist"},
+		},
+	}
+	confBytes, err := conf.JSON()
+	if err != nil {
+		t.Fatalf("failed to marshal test config: %v", err)
+	}
+	utils.WriteConfigFileForTest(t, configPath, confBytes)
+	configfile.SetDefaultConfigPath(configPath)
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			format: "table",
+			check: func(t *testing.T, out string) {
+				if !strings.Contains(out, "Fingerprint") ||
+					!regexp.MustCompile(`valid\s+\S+\s+\*\s+`+regexp.QuoteMeta(expected)).MatchString(out) ||
+					!regexp.MustCompile(`missing\s+/does/not/exist\s+-`).MatchString(out) {
+					t.Errorf("unexpected table output:\n%s", out)
+				}
+			},
+		},
+		{
+			format: "tsv",
+			check: func(t *testing.T, out string) {
+				want := "name\tssh_key_path\tis_default\tfingerprint\n" +
+					"valid\t" + privateKey + "\ttrue\t" + expected + "\n" +
+					"missing\t/does/not/exist\tfalse\t-\n"
+				if out != want {
+					t.Errorf("expected %q, got %q", want, out)
+				}
+			},
+		},
+		{
+			format: "json",
+			check: func(t *testing.T, out string) {
+				var orgs []listedOrganization
+				if err := json.Unmarshal([]byte(out), &orgs); err != nil {
+					t.Fatalf("failed to parse json output: %v", err)
+				}
+				if len(orgs) != 2 || orgs[0].Name != "valid" || orgs[0].Fingerprint != expected || orgs[1].Fingerprint != "" {
+					t.Errorf("unexpected organizations: %+v", orgs)
+				}
+			},
+		},
+		{
+			format: "yaml",
+			check: func(t *testing.T, out string) {
+				var orgs []listedOrganization
+				if err := yaml.Unmarshal([]byte(out), &orgs); err != nil {
+					t.Fatalf("failed to parse yaml output: %v", err)
+				}
+				if len(orgs) != 2 || orgs[0].Name != "valid" || orgs[0].Fingerprint != expected || orgs[1].Fingerprint != "" {
+					t.Errorf("unexpected organizations: %+v", orgs)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "list",
+				Action: listOrganizations,
+				Writer: &out,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "format", Value: "table"},
+					&cli.BoolFlag{Name: "fingerprint"},
+				},
+			}
+			if err := cmd.Run(t.Context(), []string{"list", "--fingerprint", "--format", tt.format}); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			tt.check(t, out.String())
+		})
+	}
+}
+
+func TestCountOrganizations(t *testing.T) {
+	tests := []struct {
+		name     string
+		orgs     []*domain.Organization
+		absent   bool // no configuration file at all
+		expected string
+	}{
+		{
+			name: "two organizations",
+			orgs: []*domain.Organization{
+				{Name: "org1", SSHKeyPath: "/path/to/key1", IsDefault: true},
+				{Name: "org2", SSHKeyPath: "/path/to/key2"},
+			},
+			expected: "2\n",
+		},
+		{name: "empty config", expected: "0\n"},
+		{name: "absent config", absent: true, expected: "0\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			if !tt.absent {
+				confBytes, err := (&domain.Config{Organizations: tt.orgs}).JSON()
+				if err != nil {
+					t.Fatalf("failed to marshal test config: %v", err)
+				}
+				utils.WriteConfigFileForTest(t, configPath, confBytes)
+			}
+			configfile.SetDefaultConfigPath(configPath)
+
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:      "count",
+				Action:    countOrganizations,
+				Writer:    &out,
+				ErrWriter: io.Discard,
+			}
+			if err := cmd.Run(t.Context(), []string{"count"}); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestSetOrganizationReplaceKeyOnly(t *testing.T) {
+	oldKey, _ := utils.GenerateTestSSHKey(t)
+	newKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr error
+		expectKey string
+	}{
+		{
+			name:      "replace default org key",
+			args:      []string{"set", "--replace-key-only", "org1", newKey},
+			expectKey: newKey,
+		},
+		{
+			name:      "org not found",
+			args:      []string{"set", "--replace-key-only", "org3", newKey},
+			expectErr: domain.ErrOrganizationNotFound,
+			expectKey: oldKey,
+		},
+		{
+			name:      "conflicts with default",
+			args:      []string{"set", "--replace-key-only", "--default", "org1", newKey},
+			expectErr: ErrConflictingFlags,
+			expectKey: oldKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			conf := &domain.Config{
+				Organizations: []*domain.Organization{
+					{Name: "org1", SSHKeyPath: oldKey, IsDefault: true},
+					{Name: "org2", SSHKeyPath: oldKey, IsDefault: false},
+				},
+			}
+			confBytes, err := conf.JSON()
+			if err != nil {
+				t.Fatalf("failed to marshal test config: %v", err)
+			}
+			utils.WriteConfigFileForTest(t, configPath, confBytes)
+			configfile.SetDefaultConfigPath(configPath)
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "replace-key-only"},
+				},
+			}
+			err = cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			// the key changes, but org1 stays the default without --default
+			conf, err = configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			org, err := conf.GetOrganization("org1")
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if org.SSHKeyPath != tt.expectKey {
+				t.Errorf("expected key %s, got %s", tt.expectKey, org.SSHKeyPath)
+			}
+			if !org.IsDefault {
+				t.Errorf("expected org1 to remain the default")
+			}
+		})
+	}
+}
+
+func TestSetOrganizationReplace(t *testing.T) {
+	oldKey, _ := utils.GenerateTestSSHKey(t)
+	newKey, _ := utils.GenerateTestSSHKey(t)
+	original := domain.Organization{
+		Name:           "org1",
+		SSHKeyPath:     oldKey,
+		Keys:           map[string]string{"read": oldKey},
+		IsDefault:      true,
+		Host:           "ghe.example.com",
+		PostClone:      "make setup",
+		ConnectTimeout: 10,
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr error
+		expectOrg domain.Organization
+	}{
+		{
+			name:      "stale settings are cleared",
+			args:      []string{"set", "--replace", "org1", newKey},
+			expectOrg: domain.Organization{Name: "org1", SSHKeyPath: newKey, IsDefault: true},
+		},
+		{
+			name:      "given settings are kept",
+			args:      []string{"set", "--replace", "--host", "github.example.org", "org1", newKey},
+			expectOrg: domain.Organization{Name: "org1", SSHKeyPath: newKey, IsDefault: true, Host: "github.example.org"},
+		},
+		{
+			name: "without --replace, settings are merged",
+			args: []string{"set", "org1", newKey},
+			expectOrg: func() domain.Organization {
+				org := original
+				org.SSHKeyPath = newKey
+				return org
+			}(),
+		},
+		{
+			name:      "conflicts with replace-key-only",
+			args:      []string{"set", "--replace", "--replace-key-only", "org1", newKey},
+			expectErr: ErrConflictingFlags,
+			expectOrg: original,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			org := original
+			conf := &domain.Config{
+				Organizations: []*domain.Organization{&org, {Name: "org2", SSHKeyPath: oldKey}},
+			}
+			confBytes, err := conf.JSON()
+			if err != nil {
+				t.Fatalf("failed to marshal test config: %v", err)
+			}
+			utils.WriteConfigFileForTest(t, configPath, confBytes)
+			configfile.SetDefaultConfigPath(configPath)
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "yes"},
+					&cli.BoolFlag{Name: "replace"},
+					&cli.BoolFlag{Name: "replace-key-only"},
+					&cli.StringFlag{Name: "host"},
+				},
+			}
+			err = cmd.Run(t.Context(), append([]string{tt.args[0], "--yes"}, tt.args[1:]...))
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			conf, err = configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			// the organization keeps its place in the list
+			if got := conf.Organizations[0]; !got.Equal(&tt.expectOrg) {
+				t.Errorf("expected %+v, got %+v", tt.expectOrg, *got)
+			}
+		})
+	}
+}
+
+func TestSetOrganizationCanonicalize(t *testing.T) {
+	oldKey, _ := utils.GenerateTestSSHKey(t)
+	newKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr error
+		expectKey string
+	}{
+		{name: "mixed case updates the existing org", args: []string{"set", "--canonicalize", "MyOrg", newKey}, expectKey: newKey},
+		{name: "surrounding spaces are trimmed", args: []string{"set", "--canonicalize", " MYORG ", newKey}, expectKey: newKey},
+		{name: "without the flag the name is rejected", args: []string{"set", "MyOrg", newKey}, expectErr: domain.ErrInvalidOrgName, expectKey: oldKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			conf := &domain.Config{
+				Organizations: []*domain.Organization{{Name: "myorg", SSHKeyPath: oldKey, IsDefault: true}},
+			}
+			confBytes, err := conf.JSON()
+			if err != nil {
+				t.Fatalf("failed to marshal test config: %v", err)
+			}
+			utils.WriteConfigFileForTest(t, configPath, confBytes)
+			configfile.SetDefaultConfigPath(configPath)
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "yes"},
+					&cli.BoolFlag{Name: "canonicalize"},
+				},
+			}
+			err = cmd.Run(t.Context(), append([]string{tt.args[0], "--yes"}, tt.args[1:]...))
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			// the existing organization is updated, not duplicated
+			conf, err = configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			if names := conf.OrganizationNames(); len(names) != 1 || names[0] != "myorg" {
+				t.Fatalf("expected only myorg, got %v", names)
+			}
+			if key := conf.Organizations[0].SSHKeyPath; key != tt.expectKey {
+				t.Errorf("expected key %s, got %s", tt.expectKey, key)
+			}
+		})
+	}
+}
+
+func TestSetOrganizationNoChanges(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configfile.SetDefaultConfigPath(configPath)
+
+	var out bytes.Buffer
+	cmd := &cli.Command{
+		Name:   "set",
+		Action: setOrganization,
+		Writer: &out,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "default"},
+		},
+	}
+	if err := cmd.Run(t.Context(), []string{"set", "org1", privateKey}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output for a change, got %q", out.String())
+	}
+
+	// backdate the file, so a rewrite would be visible
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(configPath, old, old); err != nil {
+		t.Fatalf("failed to set file times: %v", err)
+	}
+
+	// setting the same key again must not rewrite the file
+	if err := cmd.Run(t.Context(), []string{"set", "org1", privateKey}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if !strings.Contains(out.String(), "No changes to organization 'org1'") {
+		t.Errorf("expected no changes to be reported, got %q", out.String())
+	}
+	info, err := os.Stat(configPath)
+	if err != nil {
+		t.Fatalf("failed to stat config: %v", err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("expected mtime %v, got %v", old, info.ModTime())
+	}
+}
+
+func TestSetOrganizationDefaultIfFirst(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name          string
+		args          []string
+		expectDefault bool
+	}{
+		{name: "not promoted by default", args: []string{"set", "org1", privateKey}, expectDefault: false},
+		{name: "promoted with flag", args: []string{"set", "--default-if-first", "org1", privateKey}, expectDefault: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "default-if-first"},
+				},
+			}
+			if err := cmd.Run(t.Context(), tt.args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			if conf.Organizations[0].IsDefault != tt.expectDefault {
+				t.Errorf("expected default %v, got %v", tt.expectDefault, conf.Organizations[0].IsDefault)
+			}
+		})
+	}
+}
+
+func TestSetOrganizationKeyPairMismatch(t *testing.T) {
+	privateKey, publicKey := utils.GenerateTestSSHKey(t)
+	_, otherPublicKey := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name       string
+		pub        string // public key copied next to the private key
+		expectWarn bool
+	}{
+		{name: "matching pair", pub: publicKey},
+		{name: "mismatched pair", pub: otherPublicKey, expectWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configfile.SetDefaultConfigPath(filepath.Join(dir, "config.json"))
+			keyData, err := os.ReadFile(privateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubData, err := os.ReadFile(tt.pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := filepath.Join(dir, "id_rsa")
+			if err := os.WriteFile(key, keyData, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(key+".pub", pubData, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var errOut bytes.Buffer
+			cmd := &cli.Command{
+				Name:      "set",
+				Action:    setOrganization,
+				Writer:    io.Discard,
+				ErrWriter: &errOut,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+				},
+			}
+			// the organization is saved either way
+			if err := cmd.Run(t.Context(), []string{"set", "--default", "org1", key}); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			warned := strings.Contains(errOut.String(), sshkey.ErrPairMismatch.Error())
+			if warned != tt.expectWarn {
+				t.Errorf("expected warning %v, got %q", tt.expectWarn, errOut.String())
+			}
+		})
+	}
+}
+
+func TestEnforceDefault(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		conf      *domain.Config
+		args      []string
+		expectErr error
+	}{
+		{
+			name: "set keeps the default",
+			conf: &domain.Config{EnforceDefault: true, Organizations: []*domain.Organization{{Name: "org1", SSHKeyPath: privateKey, IsDefault: true}}},
+			args: []string{"org", "set", "org2", privateKey},
+		},
+		{
+			name:      "set first org without a default",
+			conf:      &domain.Config{EnforceDefault: true},
+			args:      []string{"org", "set", "org1", privateKey},
+			expectErr: domain.ErrDefaultRequired,
+		},
+		{
+			name: "set first org as the default",
+			conf: &domain.Config{EnforceDefault: true},
+			args: []string{"org", "set", "--default-if-first", "org1", privateKey},
+		},
+		{
+			name:      "plan without a default",
+			conf:      &domain.Config{EnforceDefault: true},
+			args:      []string{"org", "set", "--plan", "org1", privateKey},
+			expectErr: domain.ErrDefaultRequired,
+		},
+		{
+			name: "not enforced",
+			conf: &domain.Config{},
+			args: []string{"org", "set", "org1", privateKey},
+		},
+		{
+			name: "remove leaves no default",
+			conf: &domain.Config{EnforceDefault: true, Organizations: []*domain.Organization{
+				{Name: "org1", SSHKeyPath: privateKey},
+				{Name: "org2", SSHKeyPath: privateKey},
+			}},
+			args:      []string{"org", "remove", "org2"},
+			expectErr: domain.ErrDefaultRequired,
+		},
+		{
+			name: "remove the last org",
+			conf: &domain.Config{EnforceDefault: true, Organizations: []*domain.Organization{{Name: "org1", SSHKeyPath: privateKey}}},
+			args: []string{"org", "remove", "org1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			if err := configfile.WriteConfigTo(tt.conf, configPath); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			configfile.SetDefaultConfigPath(configPath)
+			before, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := &cli.Command{
+				Name:   "org",
+				Writer: io.Discard,
+				Commands: []*cli.Command{
+					{
+						Name:   "set",
+						Action: setOrganization,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{Name: "default"},
+							&cli.BoolFlag{Name: "default-if-first"},
+							&cli.BoolFlag{Name: "plan"},
+						},
+					},
+					{
+						Name:   "remove",
+						Action: removeOrganization,
+					},
+				},
+			}
+			err = cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			// a rejected change writes nothing
+			if tt.expectErr != nil {
+				after, err := os.ReadFile(configPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(before, after) {
+					t.Errorf("expected the config to be unchanged, got %s", after)
+				}
+			}
+		})
+	}
+}
+
+func TestSetOrganizationHost(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name       string
+		args       []string
+		expectErr  error
+		expectHost string
+	}{
+		{name: "with host", args: []string{"set", "--host", "github.corp.example.com", "org1", privateKey}, expectHost: "github.corp.example.com"},
+		{name: "without host", args: []string{"set", "org1", privateKey}, expectHost: ""},
+		{name: "invalid host", args: []string{"set", "--host", "https://github.corp.example.com", "org1", privateKey}, expectErr: domain.ErrInvalidHost},
+		{name: "with replace-key-only", args: []string{"set", "--replace-key-only", "--host", "github.corp.example.com", "org1", privateKey}, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "replace-key-only"},
+					&cli.StringFlag{Name: "host"},
+				},
+			}
+			err := cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				return
+			}
+
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			if got := conf.Organizations[0].Host; got != tt.expectHost {
+				t.Errorf("expected host %q, got %q", tt.expectHost, got)
+			}
+		})
+	}
+}
+
+func TestSetOrganizationInsecureSkipKeyCheck(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	missingKey := filepath.Join(t.TempDir(), "id_ed25519")
+
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr error
+		expectKey string
+	}{
+		{name: "missing key", args: []string{"set", "--insecure-skip-key-check", "org1", missingKey}, expectKey: missingKey},
+		{name: "existing key", args: []string{"set", "--insecure-skip-key-check", "org1", privateKey}, expectKey: privateKey},
+		{name: "missing key without flag", args: []string{"set", "org1", missingKey}, expectErr: os.ErrNotExist},
+		{name: "name rules still apply", args: []string{"set", "--insecure-skip-key-check", "bad name", missingKey}, expectErr: domain.ErrInvalidOrgName},
+		{name: "with key-type", args: []string{"set", "--insecure-skip-key-check", "--key-type", "ed25519", "org1", missingKey}, expectErr: ErrConflictingFlags},
+		{name: "with replace-key-only", args: []string{"set", "--insecure-skip-key-check", "--replace-key-only", "org1", missingKey}, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+
+			var errOut bytes.Buffer
+			cmd := &cli.Command{
+				Name:      "set",
+				Action:    setOrganization,
+				Writer:    io.Discard,
+				ErrWriter: &errOut,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "replace-key-only"},
+					&cli.StringFlag{Name: "key-type"},
+					&cli.BoolFlag{Name: "insecure-skip-key-check"},
+				},
+			}
+			err := cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				return
+			}
+
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			if got := conf.Organizations[0].SSHKeyPath; got != tt.expectKey {
+				t.Errorf("expected key %q, got %q", tt.expectKey, got)
+			}
+			if !strings.Contains(errOut.String(), "WARNING") {
+				t.Errorf("expected a warning, got %q", errOut.String())
+			}
+		})
+	}
+}
+
+func TestSetOrganizationExpire(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		first     []string // run before args, if set
+		args      []string
+		expectErr error
+		expectIn  time.Duration
+	}{
+		{name: "days", args: []string{"set", "--expire", "30d", "org1", privateKey}, expectIn: 30 * 24 * time.Hour},
+		{name: "weeks", args: []string{"set", "--expire", "2w", "org1", privateKey}, expectIn: 14 * 24 * time.Hour},
+		{name: "hours", args: []string{"set", "--expire", "12h", "org1", privateKey}, expectIn: 12 * time.Hour},
+		{name: "without expire", args: []string{"set", "org1", privateKey}},
+		{name: "kept without expire", first: []string{"set", "--expire", "30d", "org1", privateKey}, args: []string{"set", "org1", privateKey}, expectIn: 30 * 24 * time.Hour},
+		{name: "never", first: []string{"set", "--expire", "30d", "org1", privateKey}, args: []string{"set", "--expire", "never", "org1", privateKey}},
+		{name: "zero", args: []string{"set", "--expire", "0d", "org1", privateKey}, expectErr: ErrInvalidExpiry},
+		{name: "negative", args: []string{"set", "--expire", "-1h", "org1", privateKey}, expectErr: ErrInvalidExpiry},
+		{name: "not a duration", args: []string{"set", "--expire", "soon", "org1", privateKey}, expectErr: ErrInvalidExpiry},
+		{name: "with replace-key-only", args: []string{"set", "--expire", "30d", "--replace-key-only", "org1", privateKey}, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+
+			newCmd := func() *cli.Command {
+				return &cli.Command{
+					Name:   "set",
+					Action: setOrganization,
+					Writer: io.Discard,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "default"},
+						&cli.BoolFlag{Name: "replace-key-only"},
+						&cli.StringFlag{Name: "expire"},
+					},
+				}
+			}
+			start := time.Now()
+			if tt.first != nil {
+				if err := newCmd().Run(t.Context(), tt.first); err != nil {
+					t.Fatalf("failed to set up: %v", err)
+				}
+			}
+			err := newCmd().Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				return
+			}
+
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			got := conf.Organizations[0].ExpiresAt
+			if tt.expectIn == 0 {
+				if got != nil {
+					t.Errorf("expected no expiry, got %v", got)
+				}
+				return
+			}
+			// the expiry is stored to the second
+			if earliest, latest := start.Add(tt.expectIn).Add(-time.Second), time.Now().Add(tt.expectIn); got == nil || got.Before(earliest) || got.After(latest) {
+				t.Errorf("expected an expiry between %v and %v, got %v", earliest, latest, got)
+			}
+		})
+	}
+}
+
+func TestListOrganizationsExpiry(t *testing.T) {
+	past := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	future := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	conf := &domain.Config{
+		Organizations: []*domain.Organization{
+			{Name: "expired", SSHKeyPath: "/path/to/key1", ExpiresAt: &past},
+			{Name: "forever", SSHKeyPath: "/path/to/key2", IsDefault: true},
+			{Name: "temporary", SSHKeyPath: "/path/to/key3", ExpiresAt: &future},
+		},
+	}
+	confBytes, err := conf.JSON()
+	if err != nil {
+		t.Fatalf("failed to marshal test config: %v", err)
+	}
+	utils.WriteConfigFileForTest(t, configPath, confBytes)
+	configfile.SetDefaultConfigPath(configPath)
+
+	run := func(format string) string {
+		t.Helper()
+		var out bytes.Buffer
+		cmd := &cli.Command{
+			Name:   "list",
+			Action: listOrganizations,
+			Writer: &out,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "format", Value: "table"},
+			},
+		}
+		if err := cmd.Run(t.Context(), []string{"list", "--format", format}); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		return out.String()
+	}
+
+	expected := "name\tssh_key_path\tis_default\texpires_at\n" +
+		"expired\t/path/to/key1\tfalse\t2020-01-02T03:04:05Z (expired)\n" +
+		"forever\t/path/to/key2\ttrue\t\n" +
+		"temporary\t/path/to/key3\tfalse\t" + future.Format(time.RFC3339) + "\n"
+	if got := run("tsv"); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if table := run("table"); !strings.Contains(table, "Expires") || !strings.Contains(table, "2020-01-02T03:04:05Z (expired)") {
+		t.Errorf("unexpected table output:\n%s", table)
+	}
+}
+
+func TestSetOrganizationHostAlias(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name      string
+		args      []string
+		expectErr error
+	}{
+		{name: "alias without a key", args: []string{"set", "--host-alias", "github-work", "org1"}},
+		{name: "alias with a key", args: []string{"set", "--host-alias", "github-work", "org1", privateKey}, expectErr: ErrNumArguments},
+		{name: "alias with host", args: []string{"set", "--host-alias", "github-work", "--host", "ghe.example.com", "org1"}, expectErr: ErrConflictingFlags},
+		{name: "invalid alias", args: []string{"set", "--host-alias", "github work", "org1"}, expectErr: domain.ErrInvalidHost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.StringFlag{Name: "host"},
+					&cli.StringFlag{Name: "host-alias"},
+				},
+			}
+			err := cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				return
+			}
+
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			org := conf.Organizations[0]
+			if org.HostAlias != "github-work" || org.SSHKeyPath != "" {
+				t.Errorf("expected alias github-work and no key, got %+v", org)
+			}
+		})
+	}
+}
+
+func TestSetOrganizationPlan(t *testing.T) {
+	key1, _ := utils.GenerateTestSSHKey(t)
+	key2, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{name: "add", args: []string{"set", "--plan", "org2", key2}, expected: "would add org org2\n"},
+		{name: "update key", args: []string{"set", "--plan", "org1", key2}, expected: fmt.Sprintf("would update key of org1: %q -> %q\n", key1, key2)},
+		{name: "change default", args: []string{"set", "--plan", "--default", "org2", key2}, expected: "would add org org2\nwould change default from org1 to org2\n"},
+		{name: "no changes", args: []string{"set", "--plan", "org1", key1}, expected: "No changes to organization 'org1'\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			configfile.SetDefaultConfigPath(configPath)
+			if err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+				return cfg.SetOrganization("org1", key1, true)
+			}); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			before, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: &out,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "plan"},
+				},
+			}
+			if err := cmd.Run(t.Context(), tt.args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out.String())
+			}
+
+			// nothing may be written
+			after, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(before, after) {
+				t.Errorf("expected the config to be unchanged, got %s", after)
+			}
+		})
+	}
+}
+
+func TestOrganizationDryRun(t *testing.T) {
+	key1, _ := utils.GenerateTestSSHKey(t)
+	key2, _ := utils.GenerateTestSSHKey(t)
+
+	tests := []struct {
+		name   string
+		args   []string
+		action cli.ActionFunc
+		apply  func(cfg *domain.Config) error
+	}{
+		{
+			name:   "set adds an org",
+			args:   []string{"set", "--dry-run", "--default", "org2", key2},
+			action: setOrganization,
+			apply:  func(cfg *domain.Config) error { return cfg.SetOrganization("org2", key2, true) },
+		},
+		{
+			name:   "set updates an org",
+			args:   []string{"set", "--dry-run", "org1", key2},
+			action: setOrganization,
+			apply:  func(cfg *domain.Config) error { return cfg.SetOrganization("org1", key2, false) },
+		},
+		{
+			name:   "remove",
+			args:   []string{"rm", "--dry-run", "org1"},
+			action: removeOrganization,
+			apply:  func(cfg *domain.Config) error { return cfg.RemoveOrganization("org1") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.json")
+			configfile.SetDefaultConfigPath(configPath)
+			if err := configfile.UpdateConfig(func(cfg *domain.Config) error {
+				return cfg.SetOrganization("org1", key1, true)
+			}); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			before, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// apply the same change to a copy, for the serialization to expect
+			expectedPath := filepath.Join(dir, "expected.json")
+			if err := os.WriteFile(expectedPath, before, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := configfile.UpdateConfigAt(expectedPath, tt.apply); err != nil {
+				t.Fatalf("failed to update expected config: %v", err)
+			}
+			expected, err := os.ReadFile(expectedPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   tt.args[0],
+				Action: tt.action,
+				Writer: &out,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "dry-run"},
+				},
+			}
+			if err := cmd.Run(t.Context(), tt.args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if out.String() != string(expected) {
+				t.Errorf("expected %s, got %s", expected, out.String())
+			}
+
+			// nothing may be written
+			after, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(before, after) {
+				t.Errorf("expected the config to be unchanged, got %s", after)
+			}
+		})
+	}
+
+	// a failing change prints nothing
+	configfile.SetDefaultConfigPath(filepath.Join(t.TempDir(), "config.json"))
+	var out bytes.Buffer
+	cmd := &cli.Command{
+		Name:   "rm",
+		Action: removeOrganization,
+		Writer: &out,
+		Flags:  []cli.Flag{&cli.BoolFlag{Name: "dry-run"}},
+	}
+	if err := cmd.Run(t.Context(), []string{"rm", "--dry-run", "missing"}); !errors.Is(err, domain.ErrOrganizationNotFound) {
+		t.Errorf("expected %v, got %v", domain.ErrOrganizationNotFound, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output, got %q", out.String())
+	}
+}
+
+func TestSetOrganizationValidateKeyRemote(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	rejected := errors.New("Permission denied (publickey)")
+
+	tests := []struct {
+		name       string
+		args       []string
+		accessErr  error
+		expectErr  error
+		expectHost string
+		expectSave bool
+	}{
+		{name: "accepted key is saved", args: []string{"set", "--validate-key-remote", "org1", privateKey}, expectSave: true},
+		{name: "rejected key is not saved", args: []string{"set", "--validate-key-remote", "org1", privateKey}, accessErr: rejected, expectErr: rejected},
+		{name: "checked on the org's host", args: []string{"set", "--validate-key-remote", "--host", "ghe.example.com", "org1", privateKey}, expectHost: "ghe.example.com", expectSave: true},
+		{name: "invalid key is never checked", args: []string{"set", "--validate-key-remote", "org1", "/does/not/exist"}, expectErr: os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			configfile.SetDefaultConfigPath(configPath)
+
+			var checked []string
+			oldCheck := checkKeyAccess
+			checkKeyAccess = func(host, sshKeyPath string, w io.Writer) error {
+				checked = append(checked, host+" "+sshKeyPath)
+
+				// the config lock must be free while GitHub is asked
+				locked := make(chan struct{})
+				go func() {
+					configfile.ApplyConfigAt(configPath, func(*domain.Config) error { return errors.New("only locking") })
+					close(locked)
+				}()
+				select {
+				case <-locked:
+				case <-time.After(5 * time.Second):
+					t.Error("expected the config lock to be free during the remote check")
+				}
+				return tt.accessErr
+			}
+			t.Cleanup(func() { checkKeyAccess = oldCheck })
+
+			cmd := &cli.Command{
+				Name:      "set",
+				Action:    setOrganization,
+				Writer:    io.Discard,
+				ErrWriter: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.StringFlag{Name: "host"},
+					&cli.BoolFlag{Name: "validate-key-remote"},
+				},
+			}
+			err := cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			// the key is only checked remotely once it passes the local checks
+			expectChecked := []string{tt.expectHost + " " + privateKey}
+			if errors.Is(tt.expectErr, os.ErrNotExist) {
+				expectChecked = nil
+			}
+			if !slices.Equal(checked, expectChecked) {
+				t.Errorf("expected checks %q, got %q", expectChecked, checked)
+			}
+
+			_, statErr := os.Stat(configPath)
+			if saved := statErr == nil; saved != tt.expectSave {
+				t.Errorf("expected saved %v, got %v", tt.expectSave, saved)
+			}
+		})
+	}
+}
+
+func TestConfigFlag(t *testing.T) {
+	privateKey, _ := utils.GenerateTestSSHKey(t)
+	dir := t.TempDir()
+	defaultPath := filepath.Join(dir, "default.json")
+	configfile.SetDefaultConfigPath(defaultPath)
+	customPath := filepath.Join(dir, "custom.json")
+
+	cmd := &cli.Command{
+		Name: "ghc",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "config"},
+		},
+		Commands: []*cli.Command{
+			{Name: "set", Action: setOrganization},
+		},
+	}
+	if err := cmd.Run(t.Context(), []string{"ghc", "--config", customPath, "set", "org1", privateKey}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	// the org must be written to the given config only
+	conf, err := configfile.LoadConfigFrom(customPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if _, err := conf.GetOrganization("org1"); err != nil {
+		t.Errorf("expected org1 in %s, got %v", customPath, err)
+	}
+	if _, err := os.Stat(defaultPath); !os.IsNotExist(err) {
+		t.Errorf("expected the default config not to be written, got %v", err)
+	}
+}
+
+func TestProfileFlag(t *testing.T) {
+	workKey, _ := utils.GenerateTestSSHKey(t)
+	personalKey, _ := utils.GenerateTestSSHKey(t)
+	dir := t.TempDir()
+	defaultPath := filepath.Join(dir, "ghc.conf")
+	configfile.SetDefaultConfigPath(defaultPath)
+
+	run := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		cmd := &cli.Command{
+			Name:   "ghc",
+			Writer: &out,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "config"},
+				&cli.StringFlag{Name: "profile"},
+			},
+			Commands: []*cli.Command{
+				{Name: "set", Action: setOrganization},
+				{Name: "remove", Action: removeOrganization},
+				{
+					Name:   "list",
+					Action: listOrganizations,
+					Flags:  []cli.Flag{&cli.StringFlag{Name: "format", Value: "table"}},
+				},
+			},
+		}
+		if err := cmd.Run(t.Context(), append([]string{"ghc"}, args...)); err != nil {
+			t.Fatalf("%v: expected nil, got %v", args, err)
+		}
+		return out.String()
+	}
+
+	// each profile gets its own organizations
+	run("--profile", "work", "set", "shared", workKey)
+	run("--profile", "work", "set", "employer", workKey)
+	run("--profile", "personal", "set", "shared", personalKey)
+
+	tsv := func(profile string) string {
+		return run("--profile", profile, "list", "--format", "tsv")
+	}
+	if expected := "name\tssh_key_path\tis_default\n" +
+		"employer\t" + workKey + "\tfalse\n" +
+		"shared\t" + workKey + "\tfalse\n"; tsv("work") != expected {
+		t.Errorf("expected work profile %q, got %q", expected, tsv("work"))
+	}
+	if expected := "name\tssh_key_path\tis_default\n" +
+		"shared\t" + personalKey + "\tfalse\n"; tsv("personal") != expected {
+		t.Errorf("expected personal profile %q, got %q", expected, tsv("personal"))
+	}
+
+	// removing from one profile leaves the other alone
+	run("--profile", "personal", "remove", "shared")
+	if !strings.Contains(tsv("work"), "shared\t"+workKey) {
+		t.Errorf("expected shared to remain in the work profile, got %q", tsv("work"))
+	}
+
+	// the profiles are separate files, and the default config is never written
+	for _, name := range []string{"work.conf", "personal.conf"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to exist, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(defaultPath); !os.IsNotExist(err) {
+		t.Errorf("expected the default config not to be written, got %v", err)
+	}
+}
+
+func TestSetOrganizationFromAgent(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configfile.SetDefaultConfigPath(configPath)
+
+	// load a fake agent with a couple of keys
+	keyring := agent.NewKeyring()
+	var pubKeys []ssh.PublicKey
+	for i := range 2 {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		if err := keyring.Add(agent.AddedKey{PrivateKey: priv, Comment: fmt.Sprintf("key%d", i)}); err != nil {
+			t.Fatalf("failed to add key to agent: %v", err)
+		}
+		signer, err := ssh.NewSignerFromKey(priv)
+		if err != nil {
+			t.Fatalf("failed to create signer: %v", err)
+		}
+		pubKeys = append(pubKeys, signer.PublicKey())
+	}
+
+	oldConnectAgent := connectAgent
+	connectAgent = func() (sshagent.Agent, func() error, error) {
+		return keyring, func() error { return nil }, nil
+	}
+	defer func() { connectAgent = oldConnectAgent }()
+
+	tests := []struct {
+		name      string
+		selection string
+		expectKey ssh.PublicKey
+		expected  error
+	}{
+		{
+			name:      "select second key",
+			selection: "2\n",
+			expectKey: pubKeys[1],
+		},
+		{
+			name:      "select out of range",
+			selection: "3\n",
+			expected:  ErrInvalidSelection,
+		},
+		{
+			name:      "select not a number",
+			selection: "foo\n",
+			expected:  ErrInvalidSelection,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Reader: strings.NewReader(tt.selection),
+				Writer: &out,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.StringFlag{Name: "from-pub"},
+					&cli.BoolFlag{Name: "from-agent"},
+				},
+			}
+			err := cmd.Run(t.Context(), []string{"set", "--from-agent", "org1"})
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, err)
+			}
+			if tt.expected != nil {
+				return
+			}
+
+			// the stored key must be the selected agent key
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			keyPath, err := conf.GetKeyPathForOrg("org1", "")
+			if err != nil {
+				t.Fatalf("failed to get key path: %v", err)
+			}
+			keyBytes, err := os.ReadFile(keyPath)
+			if err != nil {
+				t.Fatalf("failed to read stored key: %v", err)
+			}
+			storedKey, _, _, _, err := ssh.ParseAuthorizedKey(keyBytes)
+			if err != nil {
+				t.Fatalf("failed to parse stored key: %v", err)
+			}
+			if ssh.FingerprintSHA256(storedKey) != ssh.FingerprintSHA256(tt.expectKey) {
+				t.Errorf("expected key %s, got %s", ssh.FingerprintSHA256(tt.expectKey), ssh.FingerprintSHA256(storedKey))
+			}
+		})
+	}
+}
+
+func TestSetOrganizationFromAgentDeclined(t *testing.T) {
+	oldKey, _ := utils.GenerateTestSSHKey(t)
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	conf := &domain.Config{
+		Organizations: []*domain.Organization{{Name: "org1", SSHKeyPath: oldKey, IsDefault: true}},
+	}
+	confBytes, err := conf.JSON()
+	if err != nil {
+		t.Fatalf("failed to marshal test config: %v", err)
+	}
+	utils.WriteConfigFileForTest(t, configPath, confBytes)
+	configfile.SetDefaultConfigPath(configPath)
+
+	keyring := agent.NewKeyring()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatalf("failed to add key to agent: %v", err)
+	}
+	oldConnectAgent := connectAgent
+	connectAgent = func() (sshagent.Agent, func() error, error) {
+		return keyring, func() error { return nil }, nil
+	}
+	t.Cleanup(func() { connectAgent = oldConnectAgent })
+
+	fake := &fakeConfirmer{}
+	orig := newConfirmer
+	newConfirmer = func(*cli.Command) confirmer { return fake }
+	t.Cleanup(func() { newConfirmer = orig })
+
+	cmd := &cli.Command{
+		Name:   "set",
+		Action: setOrganization,
+		Writer: io.Discard,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "default"},
+			&cli.BoolFlag{Name: "from-agent"},
+			&cli.BoolFlag{Name: "yes"},
+		},
+	}
+	err = cmd.Run(t.Context(), []string{"set", "--from-agent", "org1"})
+	if !errors.Is(err, ErrKeyChangeDeclined) {
+		t.Fatalf("expected %v, got %v", ErrKeyChangeDeclined, err)
+	}
+
+	// the agent key is staged, so nothing is left behind when the change is declined
+	entries, _ := os.ReadDir(filepath.Join(filepath.Dir(configPath), "keys"))
+	if len(entries) != 0 {
+		t.Errorf("expected no managed keys, got %d", len(entries))
+	}
+}
+
+func TestSetOrganizationKeyURL(t *testing.T) {
+	privateKey, publicKey := utils.GenerateTestSSHKey(t)
+	privateData, err := os.ReadFile(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicData, err := os.ReadFile(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) { w.Write(privateData) })
+	mux.HandleFunc("/public", func(w http.ResponseWriter, r *http.Request) { w.Write(publicData) })
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>sign in</html>")) })
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(privateData) }))
+	defer plain.Close()
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL+"/private", http.StatusFound)
+	})
+	mux.HandleFunc("/downgrade", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/private", http.StatusFound)
+	})
+
+	oldClient := keyURLClient
+	client := server.Client()
+	client.CheckRedirect = httpsRedirectsOnly
+	keyURLClient = client
+	defer func() { keyURLClient = oldClient }()
+
+	tests := []struct {
+		name       string
+		url        string
+		expectErr  error
+		expectFile string // name of the managed key file
+		expectData []byte
+	}{
+		{name: "private key", url: server.URL + "/private", expectFile: "org1", expectData: privateData},
+		{name: "public key", url: server.URL + "/public", expectFile: "org1.pub", expectData: publicData},
+		{name: "not a key", url: server.URL + "/login", expectErr: sshkey.ErrUnreadableKey},
+		{name: "not found", url: server.URL + "/missing", expectErr: ErrKeyDownload},
+		{name: "plain http", url: strings.Replace(server.URL, "https://", "http://", 1) + "/private", expectErr: ErrInvalidKeyURL},
+		{name: "redirect to https", url: server.URL + "/moved", expectFile: "org1", expectData: privateData},
+		{name: "redirect to plain http", url: server.URL + "/downgrade", expectErr: ErrInvalidKeyURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.json")
+			configfile.SetDefaultConfigPath(configPath)
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.StringFlag{Name: "key-url"},
+				},
+			}
+			err := cmd.Run(t.Context(), []string{"set", "--key-url", tt.url, "org1"})
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				// nothing is saved for a bad key
+				if _, err := os.Stat(filepath.Join(dir, "keys")); !os.IsNotExist(err) {
+					t.Errorf("expected no managed keys, got %v", err)
+				}
+				if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+					t.Errorf("expected no config, got %v", err)
+				}
+				return
+			}
+
+			// the stored path is the managed key, with the downloaded content
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			keyPath, err := conf.GetKeyPathForOrg("org1", "")
+			if err != nil {
+				t.Fatalf("failed to get key path: %v", err)
+			}
+			if expected := filepath.Join(dir, "keys", tt.expectFile); keyPath != expected {
+				t.Errorf("expected key path %s, got %s", expected, keyPath)
+			}
+			info, err := os.Stat(keyPath)
+			if err != nil {
+				t.Fatalf("failed to stat stored key: %v", err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("expected mode 0600, got %04o", info.Mode().Perm())
+			}
+			if data, _ := os.ReadFile(keyPath); !bytes.Equal(data, tt.expectData) {
+				t.Errorf("expected the downloaded key, got %q", data)
+			}
+		})
+	}
+}
+
+func TestSetOrganizationKeyURLStaged(t *testing.T) {
+	oldKey, _ := utils.GenerateTestSSHKey(t)
+	oldData, err := os.ReadFile(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, _ := utils.GenerateTestSSHKey(t)
+	newData, err := os.ReadFile(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(newData) }))
+	defer server.Close()
+
+	oldClient := keyURLClient
+	keyURLClient = server.Client()
+	defer func() { keyURLClient = oldClient }()
+
+	tests := []struct {
+		name       string
+		args       []string
+		answer     bool
+		expectAsk  bool
+		expectErr  error
+		expectFile string // name of the managed key file checked afterwards
+		expectData []byte // nil if the managed key file must not exist
+	}{
+		{name: "confirmed", args: []string{"set", "--key-url", server.URL, "org1"}, answer: true, expectAsk: true, expectFile: "org1", expectData: newData},
+		{name: "declined", args: []string{"set", "--key-url", server.URL, "org1"}, expectAsk: true, expectErr: ErrKeyChangeDeclined, expectFile: "org1", expectData: oldData},
+		{name: "wrong key type", args: []string{"set", "--key-url", server.URL, "--key-type", "ed25519", "org1"}, answer: true, expectErr: sshkey.ErrKeyTypeMismatch, expectFile: "org1", expectData: oldData},
+		{name: "new organization fails", args: []string{"set", "--key-url", server.URL, "--key-type", "ed25519", "org2"}, expectErr: sshkey.ErrKeyTypeMismatch, expectFile: "org2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.json")
+			configfile.SetDefaultConfigPath(configPath)
+
+			// org1 already uses the managed key that is downloaded again
+			managedKey, err := configfile.WriteManagedKeyAt(configPath, "org1", oldData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := &domain.Config{
+				Organizations: []*domain.Organization{{Name: "org1", SSHKeyPath: managedKey, IsDefault: true}},
+			}
+			confBytes, err := conf.JSON()
+			if err != nil {
+				t.Fatalf("failed to marshal test config: %v", err)
+			}
+			utils.WriteConfigFileForTest(t, configPath, confBytes)
+
+			fake := &fakeConfirmer{answer: tt.answer}
+			orig := newConfirmer
+			newConfirmer = func(*cli.Command) confirmer { return fake }
+			t.Cleanup(func() { newConfirmer = orig })
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.StringFlag{Name: "key-url"},
+					&cli.StringFlag{Name: "key-type"},
+				},
+			}
+			err = cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if asked := len(fake.questions) == 1; asked != tt.expectAsk {
+				t.Errorf("expected asked %v, got questions %q", tt.expectAsk, fake.questions)
+			}
+
+			// the managed key only changes once the change is confirmed and saved
+			data, err := os.ReadFile(filepath.Join(dir, "keys", tt.expectFile))
+			if tt.expectData == nil {
+				if !os.IsNotExist(err) {
+					t.Errorf("expected no managed key, got %v", err)
+				}
+			} else if !bytes.Equal(data, tt.expectData) {
+				t.Errorf("expected the managed key to hold %d bytes, got %d bytes of other content", len(tt.expectData), len(data))
+			}
+
+			// nothing staged is left behind
+			entries, err := os.ReadDir(filepath.Join(dir, "keys"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".tmp-") {
+					t.Errorf("expected no staged keys, got %s", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestSetOrganizationKeyFromStdin(t *testing.T) {
+	privateKey, publicKey := utils.GenerateTestSSHKey(t)
+	privateData, err := os.ReadFile(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicData, err := os.ReadFile(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		stdin     []byte
+		expectErr error
+	}{
+		{name: "private key", args: []string{"set", "org1", "-"}, stdin: privateData},
+		{name: "public key", args: []string{"set", "org1", "-"}, stdin: publicData, expectErr: ErrNotPrivateKey},
+		{name: "not a key", args: []string{"set", "org1", "-"}, stdin: []byte("hunter2\n"), expectErr: sshkey.ErrUnreadableKey},
+		{name: "empty", args: []string{"set", "org1", "-"}, expectErr: sshkey.ErrUnreadableKey},
+		{name: "plan", args: []string{"set", "--plan", "org1", "-"}, stdin: privateData, expectErr: ErrConflictingFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.json")
+			configfile.SetDefaultConfigPath(configPath)
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Reader: bytes.NewReader(tt.stdin),
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.BoolFlag{Name: "plan"},
+				},
+			}
+			err := cmd.Run(t.Context(), tt.args)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if tt.expectErr != nil {
+				// nothing is saved for a bad key
+				if _, err := os.Stat(filepath.Join(dir, "keys")); !os.IsNotExist(err) {
+					t.Errorf("expected no managed keys, got %v", err)
+				}
+				if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+					t.Errorf("expected no config, got %v", err)
+				}
+				return
+			}
+
+			// the stored path is the managed key, with the piped content
+			conf, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			keyPath, err := conf.GetKeyPathForOrg("org1", "")
+			if err != nil {
+				t.Fatalf("failed to get key path: %v", err)
+			}
+			if expected := filepath.Join(dir, "keys", "org1"); keyPath != expected {
+				t.Errorf("expected key path %s, got %s", expected, keyPath)
+			}
+			info, err := os.Stat(keyPath)
+			if err != nil {
+				t.Fatalf("failed to stat stored key: %v", err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("expected mode 0600, got %04o", info.Mode().Perm())
+			}
+			if data, _ := os.ReadFile(keyPath); !bytes.Equal(data, privateData) {
+				t.Errorf("expected the piped key, got %q", data)
+			}
+		})
+	}
+}
+
+func TestSetOrganizationKeyFromStdinExisting(t *testing.T) {
+	oldKey, _ := utils.GenerateTestSSHKey(t)
+	oldData, err := os.ReadFile(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, _ := utils.GenerateTestSSHKey(t)
+	newData, err := os.ReadFile(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		stdin      []byte
+		answer     bool
+		expectAsk  bool
+		expectErr  error
+		expectData []byte
+	}{
+		{name: "confirmed", stdin: newData, answer: true, expectAsk: true, expectData: newData},
+		{name: "declined", stdin: newData, expectAsk: true, expectErr: ErrKeyChangeDeclined, expectData: oldData},
+		{name: "same key", stdin: oldData, expectData: oldData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.json")
+			configfile.SetDefaultConfigPath(configPath)
+
+			// org1 already uses the managed key the piped key is written to
+			managedKey, err := configfile.WriteManagedKeyAt(configPath, "org1", oldData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := &domain.Config{
+				Organizations: []*domain.Organization{{Name: "org1", SSHKeyPath: managedKey, IsDefault: true}},
+			}
+			confBytes, err := conf.JSON()
+			if err != nil {
+				t.Fatalf("failed to marshal test config: %v", err)
+			}
+			utils.WriteConfigFileForTest(t, configPath, confBytes)
+
+			fake := &fakeConfirmer{answer: tt.answer}
+			orig := newConfirmer
+			newConfirmer = func(*cli.Command) confirmer { return fake }
+			t.Cleanup(func() { newConfirmer = orig })
+
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Reader: bytes.NewReader(tt.stdin),
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+				},
+			}
+			err = cmd.Run(t.Context(), []string{"set", "org1", "-"})
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+			if asked := len(fake.questions) == 1; asked != tt.expectAsk {
+				t.Fatalf("expected asked %v, got questions %q", tt.expectAsk, fake.questions)
+			}
+			if tt.expectAsk && !strings.Contains(fake.questions[0], "new key: "+managedKey+" (new content)") {
+				t.Errorf("expected the question to show the key gets new content, got %q", fake.questions[0])
+			}
+
+			if data, _ := os.ReadFile(managedKey); !bytes.Equal(data, tt.expectData) {
+				t.Errorf("expected the managed key to hold %d bytes, got %d bytes of other content", len(tt.expectData), len(data))
+			}
+			entries, err := os.ReadDir(filepath.Join(dir, "keys"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("expected only the managed key, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestSetOrganizationMoveKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		minRSABits int  // rejects the moved key if larger than the test key
+		destExists bool // something is already at the destination
+		shared     bool // another organization uses the same key
+		expectErr  error
+	}{
+		{name: "moves the key and its public key"},
+		{name: "destination exists", destExists: true, expectErr: ErrKeyDestExists},
+		{name: "shared key", shared: true, expectErr: domain.ErrSharedSSHKey},
+		{name: "rejected key is moved back", minRSABits: 4096, expectErr: domain.ErrRSAKeyTooSmall},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privateKey, publicKey := utils.GenerateTestSSHKey(t)
+			privateData, _ := os.ReadFile(privateKey)
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.json")
+			configfile.SetDefaultConfigPath(configPath)
+
+			// write the config by hand, so it can hold settings that reject the key
+			conf := &domain.Config{
+				MinRSABits:    tt.minRSABits,
+				Organizations: []*domain.Organization{{Name: "org1", SSHKeyPath: privateKey, IsDefault: true}},
+			}
+			if tt.shared {
+				conf.Organizations = append(conf.Organizations, &domain.Organization{Name: "org2", SSHKeyPath: privateKey})
+			}
+			if err := configfile.WriteConfigTo(conf, configPath); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			dest := filepath.Join(dir, "keys", "org1")
+			if tt.destExists {
+				if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(dest+".pub", []byte("other"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:   "set",
+				Action: setOrganization,
+				Writer: &out,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "default"},
+					&cli.StringFlag{Name: "move-key"},
+				},
+			}
+			err := cmd.Run(t.Context(), []string{"set", "--move-key", dest, "org1"})
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("expected %v, got %v", tt.expectErr, err)
+			}
+
+			loaded, err := configfile.LoadConfig()
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			org, err := loaded.GetOrganization("org1")
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+
+			// on failure, the key and the config are left as they were
+			expectKey, gonePath := dest, privateKey
+			if tt.expectErr != nil {
+				expectKey, gonePath = privateKey, dest
+			}
+			if org.SSHKeyPath != expectKey || !org.IsDefault {
+				t.Errorf("expected default org1 with key %s, got %+v", expectKey, org)
+			}
+			info, err := os.Stat(expectKey)
+			if err != nil {
+				t.Fatalf("expected the key at %s, got %v", expectKey, err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("expected mode 0600, got %04o", info.Mode().Perm())
+			}
+			if data, _ := os.ReadFile(expectKey); !bytes.Equal(data, privateData) {
+				t.Errorf("expected the key's content at %s", expectKey)
+			}
+			if _, err := os.Stat(expectKey + ".pub"); err != nil {
+				t.Errorf("expected the public key next to %s, got %v", expectKey, err)
+			}
+			if _, err := os.Stat(gonePath); !os.IsNotExist(err) {
+				t.Errorf("expected nothing at %s, got %v", gonePath, err)
+			}
+			if tt.expectErr == nil {
+				if _, err := os.Stat(publicKey); !os.IsNotExist(err) {
+					t.Errorf("expected the public key to move, got %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"ghc/internal/selfupdate"
+
+	"github.com/urfave/cli/v3"
+)
+
+// newUpdater creates the updater used by selfUpdate.
+// This can be overridden in tests.
+var newUpdater = selfupdate.New
+
+// selfUpdate updates ghc to the latest GitHub release.
+//
+// This function compares the running version against the latest release.
+// If the "check" flag is set, it only reports whether an update is available.
+// Otherwise, it downloads the release binary for this platform and replaces
+// the running executable with it.
+//
+// Returns an error if the running version is a development build, the latest
+// release cannot be fetched, or the update cannot be installed.
+func selfUpdate(ctx context.Context, c *cli.Command) error {
+	current := c.Root().Version
+	updater := newUpdater()
+
+	release, newer, err := updater.Check(ctx, current)
+	if err != nil {
+		return err
+	}
+
+	if !newer {
+		fmt.Fprintf(c.Root().Writer, "ghc %s is up to date\n", current)
+		return nil
+	}
+
+	if c.Bool("check") {
+		fmt.Fprintf(c.Root().Writer, "ghc %s is available (current: %s)\n", release.TagName, current)
+		return nil
+	}
+
+	if err := updater.Update(ctx, release); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Root().Writer, "ghc updated from %s to %s\n", current, release.TagName)
+	return nil
+}
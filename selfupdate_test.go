@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ghc/internal/selfupdate"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestSelfUpdate(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/release", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name":"v1.2.0","assets":[
+			{"name":"ghc_linux_amd64","browser_download_url":"%s/binary"},
+			{"name":"ghc_linux_amd64.sha256","browser_download_url":"%s/checksum"}
+		]}`, server.URL, server.URL)
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new binary"))
+	})
+	mux.HandleFunc("/checksum", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte("new binary"))
+		fmt.Fprintf(w, "%x  ghc_linux_amd64\n", sum)
+	})
+
+	var replaced bytes.Buffer
+	oldNewUpdater := newUpdater
+	newUpdater = func() *selfupdate.Updater {
+		return &selfupdate.Updater{
+			Client:     server.Client(),
+			ReleaseURL: server.URL + "/release",
+			GOOS:       "linux",
+			GOARCH:     "amd64",
+			Replace: func(r io.Reader) error {
+				_, err := io.Copy(&replaced, r)
+				return err
+			},
+		}
+	}
+	defer func() { newUpdater = oldNewUpdater }()
+
+	tests := []struct {
+		name         string
+		version      string
+		args         []string
+		expectOutput string
+		expectUpdate bool
+	}{
+		{
+			name:         "check only",
+			version:      "v1.1.0",
+			args:         []string{"self-update", "--check"},
+			expectOutput: "ghc v1.2.0 is available (current: v1.1.0)",
+			expectUpdate: false,
+		},
+		{
+			name:         "up to date",
+			version:      "v1.2.0",
+			args:         []string{"self-update"},
+			expectOutput: "ghc v1.2.0 is up to date",
+			expectUpdate: false,
+		},
+		{
+			name:         "update",
+			version:      "v1.1.0",
+			args:         []string{"self-update"},
+			expectOutput: "ghc updated from v1.1.0 to v1.2.0",
+			expectUpdate: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replaced.Reset()
+			var out bytes.Buffer
+			cmd := &cli.Command{
+				Name:    "self-update",
+				Version: tt.version,
+				Action:  selfUpdate,
+				Writer:  &out,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "check"},
+				},
+			}
+			if err := cmd.Run(t.Context(), tt.args); err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if !strings.Contains(out.String(), tt.expectOutput) {
+				t.Errorf("expected output %q, got %q", tt.expectOutput, out.String())
+			}
+			if (replaced.Len() > 0) != tt.expectUpdate {
+				t.Errorf("expected update %v, got %q", tt.expectUpdate, replaced.String())
+			}
+		})
+	}
+}